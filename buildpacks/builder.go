@@ -10,18 +10,46 @@ import (
 
 	"github.com/buildpacks/pack"
 	"github.com/buildpacks/pack/logging"
+	"github.com/google/go-containerregistry/pkg/name"
 
 	"github.com/boson-project/faas"
 )
 
 type Builder struct {
 	Verbose bool
+
+	// client to use in place of a pack client constructed with the Builder's
+	// logger.
+	client PackClient
 }
 
-func NewBuilder() *Builder {
-	return &Builder{}
+// PackClient is the subset of the pack client used by the Builder.
+type PackClient interface {
+	Build(ctx context.Context, opts pack.BuildOptions) error
 }
 
+// BuilderOption configures a Builder.
+type BuilderOption func(*Builder)
+
+// WithPackClient provides the pack client to use, such as a mock for testing.
+func WithPackClient(c PackClient) BuilderOption {
+	return func(b *Builder) {
+		b.client = c
+	}
+}
+
+func NewBuilder(options ...BuilderOption) *Builder {
+	b := &Builder{}
+	for _, o := range options {
+		o(b)
+	}
+	return b
+}
+
+// DefaultBuilderName is that of the builder used when none is chosen, which
+// may be defined in the Function's BuilderMap in place of that of its runtime.
+const DefaultBuilderName = "default"
+
 var RuntimeToBuildpack = map[string]string{
 	"quarkus": "quay.io/boson/faas-quarkus-builder",
 	"node":    "quay.io/boson/faas-nodejs-builder",
@@ -31,20 +59,9 @@ var RuntimeToBuildpack = map[string]string{
 // Build the Function at path.
 func (builder *Builder) Build(f faas.Function) (err error) {
 
-	// Use the builder found in the Function configuration file
-	// If one isn't found, use the defaults
-	var packBuilder string
-	if f.Builder != "" {
-		packBuilder = f.Builder
-		pb, ok := f.BuilderMap[packBuilder]
-		if ok {
-			packBuilder = pb
-		}
-	} else {
-		packBuilder = RuntimeToBuildpack[f.Runtime]
-		if packBuilder == "" {
-			return errors.New(fmt.Sprint("unsupported runtime: ", f.Runtime))
-		}
+	packBuilder, err := builderImage(f)
+	if err != nil {
+		return
 	}
 
 	// Build options for the pack client.
@@ -52,6 +69,7 @@ func (builder *Builder) Build(f faas.Function) (err error) {
 		AppPath: f.Root,
 		Image:   f.Image,
 		Builder: packBuilder,
+		Env:     f.BuildEnvVars,
 	}
 
 	// log output is either STDOUt or kept in a buffer to be printed on error.
@@ -63,9 +81,11 @@ func (builder *Builder) Build(f faas.Function) (err error) {
 	}
 
 	// Client with a logger which is enabled if in Verbose mode.
-	packClient, err := pack.NewClient(pack.WithLogger(logging.New(logWriter)))
-	if err != nil {
-		return
+	var packClient PackClient = builder.client
+	if packClient == nil {
+		if packClient, err = pack.NewClient(pack.WithLogger(logging.New(logWriter))); err != nil {
+			return
+		}
 	}
 
 	// Build based using the given builder.
@@ -78,3 +98,29 @@ func (builder *Builder) Build(f faas.Function) (err error) {
 
 	return
 }
+
+// builderImage of the Function: the builder found in the Function
+// configuration file, either an image or the name of one in its BuilderMap.
+// If one isn't found, or is the default which is not mapped, the builder of
+// the Function's runtime is used.
+func builderImage(f faas.Function) (string, error) {
+	if image, ok := f.BuilderMap[f.Builder]; ok && f.Builder != "" {
+		return validBuilderImage(image)
+	}
+	if f.Builder != "" && f.Builder != DefaultBuilderName {
+		return validBuilderImage(f.Builder)
+	}
+	image := RuntimeToBuildpack[f.Runtime]
+	if image == "" {
+		return "", errors.New(fmt.Sprint("unsupported runtime: ", f.Runtime))
+	}
+	return image, nil
+}
+
+// validBuilderImage returns the image if it is a valid image reference.
+func validBuilderImage(image string) (string, error) {
+	if _, err := name.ParseReference(image); err != nil {
+		return "", fmt.Errorf("invalid builder image '%v': %v", image, err)
+	}
+	return image, nil
+}
@@ -0,0 +1,97 @@
+package buildpacks_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildpacks/pack"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/buildpacks"
+)
+
+// mockPackClient records the options of the build it is asked to perform.
+type mockPackClient struct {
+	opts  pack.BuildOptions
+	built bool
+}
+
+func (c *mockPackClient) Build(ctx context.Context, opts pack.BuildOptions) error {
+	c.opts = opts
+	c.built = true
+	return nil
+}
+
+// TestBuildBuilderImage ensures that the builder chosen for the Function is
+// passed to pack, falling back to that of its runtime.
+func TestBuildBuilderImage(t *testing.T) {
+	builderMap := map[string]string{"hardened": "registry.example.com/builders/node:hardened"}
+	cases := []struct {
+		name     string
+		function faas.Function
+		expected string
+	}{
+		{"unset", faas.Function{Runtime: "node"}, buildpacks.RuntimeToBuildpack["node"]},
+		{"default", faas.Function{Runtime: "go", Builder: buildpacks.DefaultBuilderName}, buildpacks.RuntimeToBuildpack["go"]},
+		{"image", faas.Function{Runtime: "node", Builder: "registry.example.com/builders/node:1.0"}, "registry.example.com/builders/node:1.0"},
+		{"mapped", faas.Function{Runtime: "node", Builder: "hardened", BuilderMap: builderMap}, builderMap["hardened"]},
+		{"mapped default", faas.Function{Runtime: "node", Builder: buildpacks.DefaultBuilderName, BuilderMap: map[string]string{"default": "example.com/default"}}, "example.com/default"},
+		{"unknown runtime", faas.Function{Runtime: "cobol", Builder: "example.com/cobol-builder"}, "example.com/cobol-builder"},
+	}
+	for _, c := range cases {
+		client := &mockPackClient{}
+		c.function.Root = "/fn"
+		c.function.Image = "example.com/alice/fn:latest"
+		if err := buildpacks.NewBuilder(buildpacks.WithPackClient(client)).Build(c.function); err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if client.opts.Builder != c.expected {
+			t.Fatalf("%v: expected builder '%v', got '%v'", c.name, c.expected, client.opts.Builder)
+		}
+		if client.opts.Image != c.function.Image || client.opts.AppPath != c.function.Root {
+			t.Fatalf("%v: expected the Function's image and root to be built, got %+v", c.name, client.opts)
+		}
+	}
+}
+
+// TestBuildBuilderImageInvalid ensures that an invalid builder, or the lack
+// of one for the runtime, fails the build before pack is invoked.
+func TestBuildBuilderImageInvalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		function faas.Function
+		expected string
+	}{
+		{"invalid image", faas.Function{Runtime: "node", Builder: "Example.com/UPPER:case"}, "invalid builder image"},
+		{"invalid mapped image", faas.Function{Runtime: "node", Builder: "custom", BuilderMap: map[string]string{"custom": "bad image"}}, "invalid builder image"},
+		{"unsupported runtime", faas.Function{Runtime: "cobol"}, "unsupported runtime"},
+	}
+	for _, c := range cases {
+		client := &mockPackClient{}
+		err := buildpacks.NewBuilder(buildpacks.WithPackClient(client)).Build(c.function)
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Fatalf("%v: expected error containing '%v', got '%v'", c.name, c.expected, err)
+		}
+		if client.built {
+			t.Fatalf("%v: expected pack not to be invoked", c.name)
+		}
+	}
+}
+
+// TestBuildEnvVars ensures that the Function's build env vars, and not those
+// of its runtime, are passed to pack.
+func TestBuildEnvVars(t *testing.T) {
+	client := &mockPackClient{}
+	f := faas.Function{
+		Runtime:      "go",
+		EnvVars:      map[string]string{"A": "1"},
+		BuildEnvVars: map[string]string{"GOPRIVATE_TOKEN": "s3cret"},
+	}
+	if err := buildpacks.NewBuilder(buildpacks.WithPackClient(client)).Build(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.opts.Env) != 1 || client.opts.Env["GOPRIVATE_TOKEN"] != "s3cret" {
+		t.Fatalf("expected only the build env vars to be passed to pack, got %v", client.opts.Env)
+	}
+}
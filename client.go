@@ -1,6 +1,7 @@
 package faas
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -47,8 +48,23 @@ type Pusher interface {
 
 // Deployer of Function source to running status.
 type Deployer interface {
-	// Deploy a Function of given name, using given backing image.
-	Deploy(Function) error
+	// Deploy a Function of given name, using given backing image, returning
+	// the result, including the URL at which it is available.
+	Deploy(Function) (DeploymentResult, error)
+}
+
+// DeploymentResult of deploying a Function, for recording what was deployed.
+type DeploymentResult struct {
+	// Name of the deployed service.
+	Name string
+	// Namespace into which the Function was deployed.
+	Namespace string
+	// URL at which the Function is available.  Empty where it has no
+	// external route, or was not waited upon.
+	URL string
+	// Revision of the service which became ready.  Empty where the Function
+	// was not waited upon.
+	Revision string
 }
 
 // Runner runs the Function locally.
@@ -66,7 +82,26 @@ type Remover interface {
 // Lister of deployed services.
 type Lister interface {
 	// List the Functions currently deployed.
-	List() ([]string, error)
+	List() ([]ListItem, error)
+}
+
+// ListItem describes a deployed Function in brief.  Its JSON field names are
+// stable, for scripting, with the URL a string which is empty if the Function
+// has none.
+type ListItem struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	URL       string `json:"url" yaml:"url"`
+	Ready     bool   `json:"ready" yaml:"ready"`
+}
+
+// MarshalList serializes the listed Functions as a JSON array, which is empty
+// rather than null if there are none.
+func MarshalList(items []ListItem) ([]byte, error) {
+	if items == nil {
+		items = []ListItem{}
+	}
+	return json.Marshal(items)
 }
 
 // ProgressListener is notified of task progress.
@@ -91,16 +126,36 @@ type Describer interface {
 	Describe(name string) (description Description, err error)
 }
 
+// Description of a deployed Function.  Its JSON field names are stable, for
+// scripting, with lists empty rather than null.
 type Description struct {
-	Name          string         `json:"name" yaml:"name"`
-	Routes        []string       `json:"routes" yaml:"routes"`
-	Subscriptions []Subscription `json:"subscriptions" yaml:"subscriptions"`
-}
-
+	Name           string         `json:"name" yaml:"name"`
+	URL            string         `json:"url" yaml:"url"`
+	Ready          bool           `json:"ready" yaml:"ready"`
+	Message        string         `json:"message,omitempty" yaml:"message,omitempty"` // reason not ready, if any
+	LatestRevision string         `json:"latestRevision" yaml:"latestRevision"`
+	ReadyRevision  string         `json:"readyRevision" yaml:"readyRevision"`
+	EnvVars        []EnvVar       `json:"envVars" yaml:"envVars"`
+	Routes         []string       `json:"routes" yaml:"routes"`
+	Subscriptions  []Subscription `json:"subscriptions" yaml:"subscriptions"`
+}
+
+// EnvVar of a deployed Function.  Values referencing Secrets or ConfigMaps
+// are not included.
+type EnvVar struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// Subscription to the CloudEvents of a Broker, filtered by their type and
+// source, and by any other of their attributes, such as an extension "region".
+// Empty filters match all events, and an empty Broker the default.  Being a
+// map, the Attributes are not encoded as XML.
 type Subscription struct {
-	Source string `json:"source" yaml:"source"`
-	Type   string `json:"type" yaml:"type"`
-	Broker string `json:"broker" yaml:"broker"`
+	Source     string            `json:"source" yaml:"source"`
+	Type       string            `json:"type" yaml:"type"`
+	Broker     string            `json:"broker" yaml:"broker"`
+	Attributes map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty" xml:"-"`
 }
 
 // DNSProvider exposes DNS services necessary for serving the Function.
@@ -268,7 +323,8 @@ func (c *Client) Create(cfg Function) (err error) {
 	// Deploy the initialized Function, returning its publicly
 	// addressible name for possible registration.
 	c.progressListener.Increment("Deploying Function to cluster")
-	if err = c.Deploy(f.Root); err != nil {
+	result, err := c.Deploy(f.Root)
+	if err != nil {
 		return
 	}
 
@@ -280,11 +336,10 @@ func (c *Client) Create(cfg Function) (err error) {
 
 	c.progressListener.Complete("Create complete")
 
-	// TODO: use the knative client during deployment such that the actual final
-	// route can be returned from the deployment step, passed to the DNS Router
-	// for routing actual traffic, and returned here.
+	// TODO: pass the final route returned from the deployment step to the DNS
+	// Router for routing actual traffic, and return it here.
 	if c.verbose {
-		fmt.Printf("https://%v/\n", f.Name)
+		fmt.Println(result.URL)
 	}
 	return
 }
@@ -376,10 +431,15 @@ func (c *Client) Build(path string) (err error) {
 		return
 	}
 
-	// Derive Image from the path (precedence is given to extant config)
+	// Derive Image from the path (precedence is given to an explicit image),
+	// recording the registry from which it was derived, if any.
+	derived := f.Image == "" || f.imageDerived()
 	if f.Image, err = DerivedImage(path, c.registry); err != nil {
 		return
 	}
+	if derived && c.registry != "" {
+		f.Registry = c.registry
+	}
 
 	if err = c.builder.Build(f); err != nil {
 		return
@@ -399,9 +459,10 @@ func (c *Client) Build(path string) (err error) {
 	return
 }
 
-// Deploy the Function at path.  Errors if the Function has not been
-// initialized with an image tag.
-func (c *Client) Deploy(path string) (err error) {
+// Deploy the Function at path, returning the result, including the URL at
+// which it is available.  Errors if the Function has not been initialized with
+// an image tag.
+func (c *Client) Deploy(path string) (result DeploymentResult, err error) {
 
 	f, err := NewFunction(path)
 	if err != nil {
@@ -413,6 +474,11 @@ func (c *Client) Deploy(path string) (err error) {
 		return
 	}
 
+	// Reload the Function, whose image may have been derived by the build.
+	if f, err = NewFunction(f.Root); err != nil {
+		return
+	}
+
 	// Push the image for the named service to the configured registry
 	if err = c.pusher.Push(f); err != nil {
 		return
@@ -456,7 +522,7 @@ func (c *Client) Run(root string) error {
 }
 
 // List currently deployed Functions.
-func (c *Client) List() ([]string, error) {
+func (c *Client) List() ([]ListItem, error) {
 	// delegate to concrete implementation of lister entirely.
 	return c.lister.List()
 }
@@ -517,7 +583,9 @@ func (n *noopPusher) Push(_ Function) error { return nil }
 
 type noopDeployer struct{ output io.Writer }
 
-func (n *noopDeployer) Deploy(_ Function) error { return nil }
+func (n *noopDeployer) Deploy(_ Function) (DeploymentResult, error) {
+	return DeploymentResult{}, nil
+}
 
 type noopRunner struct{ output io.Writer }
 
@@ -529,7 +597,7 @@ func (n *noopRemover) Remove(string) error { return nil }
 
 type noopLister struct{ output io.Writer }
 
-func (n *noopLister) List() ([]string, error) { return []string{}, nil }
+func (n *noopLister) List() ([]ListItem, error) { return []ListItem{}, nil }
 
 type noopDNSProvider struct{ output io.Writer }
 
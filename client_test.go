@@ -1,6 +1,7 @@
 package faas_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -394,14 +395,14 @@ func TestCreateDelegates(t *testing.T) {
 		return nil
 	}
 
-	deployer.DeployFn = func(f faas.Function) error {
+	deployer.DeployFn = func(f faas.Function) (faas.DeploymentResult, error) {
 		if f.Name != expectedName {
 			t.Fatalf("deployer expected name '%v', got '%v'", expectedName, f.Name)
 		}
 		if f.Image != expectedImage {
 			t.Fatalf("deployer expected image '%v', got '%v'", expectedImage, f.Image)
 		}
-		return nil
+		return faas.DeploymentResult{}, nil
 	}
 
 	// Invocation
@@ -511,19 +512,19 @@ func TestUpdate(t *testing.T) {
 	}
 
 	// Update whose implementaiton verifed the expected name and image
-	deployer.DeployFn = func(f faas.Function) error {
+	deployer.DeployFn = func(f faas.Function) (faas.DeploymentResult, error) {
 		if f.Name != expectedName {
 			t.Fatalf("updater expected name '%v', got '%v'", expectedName, f.Name)
 		}
 		if f.Image != expectedImage {
 			t.Fatalf("updater expected image '%v', got '%v'", expectedImage, f.Image)
 		}
-		return nil
+		return faas.DeploymentResult{}, nil
 	}
 
 	// Invoke the creation, triggering the Function delegates, and
 	// perform follow-up assertions that the Functions were indeed invoked.
-	if err := client.Deploy(root); err != nil {
+	if _, err := client.Deploy(root); err != nil {
 		t.Fatal(err)
 	}
 
@@ -690,3 +691,104 @@ func TestListOutsideRoot(t *testing.T) {
 		t.Fatal("list did not invoke lister implementation")
 	}
 }
+
+// TestDeriveImagePrecedence ensures that an explicit image takes precedence
+// over that derived from the registry and name, and that a derived image is
+// derived anew when built for another registry.
+func TestDeriveImagePrecedence(t *testing.T) {
+	root := "testdata/example.com/testDeriveImagePrecedence"
+	if err := os.MkdirAll(root, 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// Only a registry is set, so the image is derived from it and the name.
+	if err := faas.New(faas.WithRegistry("quay.io/dev")).Create(faas.Function{Root: root}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := faas.NewFunction(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "quay.io/dev/" + f.Name + ":latest"; f.Image != expected {
+		t.Fatalf("expected image '%v' got '%v'", expected, f.Image)
+	}
+
+	// Built for another registry, the derived image is retargeted.
+	if err = faas.New(faas.WithRegistry("quay.io/prod")).Build(root); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = faas.NewFunction(root); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "quay.io/prod/" + f.Name + ":latest"; f.Image != expected {
+		t.Fatalf("expected image '%v' got '%v'", expected, f.Image)
+	}
+
+	// Built with no registry, the image remains that of the last registry.
+	if err = faas.New().Build(root); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = faas.NewFunction(root); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "quay.io/prod/" + f.Name + ":latest"; f.Image != expected {
+		t.Fatalf("expected image '%v' got '%v'", expected, f.Image)
+	}
+
+	// A fully explicit image wins over the registry.
+	f.Image = "registry.example.com/team/custom:v1"
+	if err = f.WriteConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err = faas.New(faas.WithRegistry("quay.io/dev")).Build(root); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = faas.NewFunction(root); err != nil {
+		t.Fatal(err)
+	}
+	if f.Image != "registry.example.com/team/custom:v1" {
+		t.Fatalf("expected the explicit image to be retained, got '%v'", f.Image)
+	}
+}
+
+// TestListJSON ensures that the JSON of the listed Functions is of stable
+// field names, and an empty array when none are listed.
+func TestListJSON(t *testing.T) {
+	b, err := faas.MarshalList([]faas.ListItem{{Name: "f.example.com", Namespace: "ns", URL: "http://f.example.com", Ready: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"name":"f.example.com","namespace":"ns","url":"http://f.example.com","ready":true}]`
+	if string(b) != expected {
+		t.Fatalf("expected JSON %v, got %v", expected, string(b))
+	}
+	if b, err = faas.MarshalList(nil); err != nil || string(b) != "[]" {
+		t.Fatalf("expected an empty array, got %v (%v)", string(b), err)
+	}
+}
+
+// TestDescriptionJSON ensures that the JSON of a Function's description is
+// of stable field names, with its URL a string.
+func TestDescriptionJSON(t *testing.T) {
+	b, err := json.Marshal(faas.Description{
+		Name:           "f.example.com",
+		URL:            "http://f.example.com",
+		Ready:          true,
+		LatestRevision: "f-example-com-00002",
+		ReadyRevision:  "f-example-com-00002",
+		EnvVars:        []faas.EnvVar{{Name: "A", Value: "1"}},
+		Routes:         []string{"http://f.example.com"},
+		Subscriptions:  []faas.Subscription{{Source: "s", Type: "t", Broker: "default"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"name":"f.example.com","url":"http://f.example.com","ready":true,` +
+		`"latestRevision":"f-example-com-00002","readyRevision":"f-example-com-00002",` +
+		`"envVars":[{"name":"A","value":"1"}],"routes":["http://f.example.com"],` +
+		`"subscriptions":[{"source":"s","type":"t","broker":"default"}]}`
+	if string(b) != expected {
+		t.Fatalf("expected JSON %v, got %v", expected, string(b))
+	}
+}
@@ -40,6 +40,10 @@ Any value provided for the --image flag will be persisted in the faas.yaml
 configuration file. On subsequent invocations of the "build" command
 these values will be read from the configuration file.
 
+An explicit --image takes precedence over the registry.  An image name derived
+from a registry is derived anew when a different --registry is provided, such
+that the Function may be built for another registry without being renamed.
+
 It's possible to use a custom Buildpack builder with the --builder flag.
 The value may be image name e.g. "cnbs/sample-builder:bionic",
 or reference to builderMaps in the config file e.g. "default".
@@ -74,9 +78,8 @@ func runBuild(cmd *cobra.Command, _ []string) (err error) {
 			}
 		}
 
-		// We have the registry, so let's use it to derive the Function image name
-		config.Image = deriveImage(config.Image, config.Registry, config.Path)
-		function.Image = config.Image
+		// We have the registry, from which the client derives the Function
+		// image name, recording the registry for when it is next built.
 	}
 
 	// All set, let's write changes in the config to the disk
@@ -18,12 +18,14 @@ func CompleteFunctionList(cmd *cobra.Command, args []string, toComplete string)
 		directive = cobra.ShellCompDirectiveError
 		return
 	}
-	s, err := lister.List()
+	items, err := lister.List()
 	if err != nil {
 		directive = cobra.ShellCompDirectiveError
 		return
 	}
-	strings = s
+	for _, item := range items {
+		strings = append(strings, item.Name)
+	}
 	directive = cobra.ShellCompDirectiveDefault
 	return
 }
@@ -87,11 +87,10 @@ func runCreate(cmd *cobra.Command, args []string) (err error) {
 	pusher := docker.NewPusher()
 	pusher.Verbose = verbose
 
-	deployer, err := knative.NewDeployer(config.Namespace)
+	deployer, err := knative.NewDeployer(knative.WithNamespace(config.Namespace), knative.WithVerbose(verbose))
 	if err != nil {
 		return
 	}
-	deployer.Verbose = verbose
 
 	listener := progress.New()
 	listener.Verbose = verbose
@@ -16,6 +16,7 @@ import (
 func init() {
 	root.AddCommand(deployCmd)
 	deployCmd.Flags().BoolP("confirm", "c", false, "Prompt to confirm all configuration options - $FAAS_CONFIRM")
+	deployCmd.Flags().Bool("adopt", false, "Update an existing service of the same name which was not deployed as a Function - $FAAS_ADOPT")
 	deployCmd.Flags().StringArrayP("env", "e", []string{}, "Sets environment variables for the Function.")
 	deployCmd.Flags().StringP("image", "i", "", "Optional full image name, in form [registry]/[namespace]/[name]:[tag] for example quay.io/myrepo/project.name:latest (overrides --registry) - $FAAS_IMAGE")
 	deployCmd.Flags().StringP("namespace", "n", "", "Override namespace into which the Function is deployed (on supported platforms).  Default is to use currently active underlying platform setting - $FAAS_NAMESPACE")
@@ -36,6 +37,8 @@ the --image or -i and --registry or -r flag.
 
 If the Function is already deployed, it is updated with a new container image
 that is pushed to an image registry, and the Knative Service is updated.
+A Knative Service of the same name which was not deployed as a Function is
+left untouched unless --adopt is provided.
 
 The namespace into which the project is deployed defaults to the value in the
 faas.yaml configuration file. If NAMESPACE is not set in the configuration,
@@ -46,7 +49,7 @@ or -n flag, and if so this will overwrite the value in the faas.yaml file.
 
 `,
 	SuggestFor: []string{"delpoy", "deplyo"},
-	PreRunE:    bindEnv("image", "namespace", "path", "registry", "confirm"),
+	PreRunE:    bindEnv("image", "namespace", "path", "registry", "confirm", "adopt"),
 	RunE:       runDeploy,
 }
 
@@ -78,9 +81,8 @@ func runDeploy(cmd *cobra.Command, _ []string) (err error) {
 			}
 		}
 
-		// We have the registry, so let's use it to derive the Function image name
-		config.Image = deriveImage(config.Image, config.Registry, config.Path)
-		function.Image = config.Image
+		// We have the registry, from which the client derives the Function
+		// image name, recording the registry for when it is next built.
 	}
 
 	// All set, let's write changes in the config to the disk
@@ -95,15 +97,19 @@ func runDeploy(cmd *cobra.Command, _ []string) (err error) {
 	pusher := docker.NewPusher()
 	pusher.Verbose = config.Verbose
 
-	deployer, err := knative.NewDeployer(config.Namespace)
+	// Each deployment follows a build of the image, which is run only by a new
+	// revision, so the update of an otherwise unchanged Service is not skipped.
+	deployer, err := knative.NewDeployer(
+		knative.WithNamespace(config.Namespace),
+		knative.WithVerbose(config.Verbose),
+		knative.WithForce(true),
+		knative.WithAdopt(config.Adopt))
 	if err != nil {
 		return
 	}
 
 	listener := progress.New()
 
-	deployer.Verbose = config.Verbose
-
 	client := faas.New(
 		faas.WithVerbose(config.Verbose),
 		faas.WithRegistry(config.Registry), // for deriving image name when --image not provided explicitly.
@@ -112,7 +118,17 @@ func runDeploy(cmd *cobra.Command, _ []string) (err error) {
 		faas.WithDeployer(deployer),
 		faas.WithProgressListener(listener))
 
-	return client.Deploy(config.Path)
+	result, err := client.Deploy(config.Path)
+	if err != nil {
+		return
+	}
+	// Functions which are cluster-local, or not waited upon, have no URL.
+	if result.URL == "" {
+		fmt.Println("Function deployed")
+		return
+	}
+	fmt.Println("Function deployed on: " + result.URL)
+	return
 
 	// NOTE: Namespace is optional, default is that used by k8s client
 	// (for example kubectl usually uses ~/.kube/config)
@@ -140,6 +156,10 @@ type deployConfig struct {
 	// with interactive prompting (only applicable when attached to a TTY).
 	Confirm bool
 
+	// Adopt an existing service of the Function's name which was not
+	// deployed as a Function.
+	Adopt bool
+
 	EnvVars map[string]string
 }
 
@@ -152,6 +172,7 @@ func newDeployConfig(cmd *cobra.Command) deployConfig {
 		Path:        viper.GetString("path"),
 		Verbose:     viper.GetBool("verbose"), // defined on root
 		Confirm:     viper.GetBool("confirm"),
+		Adopt:       viper.GetBool("adopt"),
 		EnvVars:     envVarsFromCmd(cmd),
 	}
 }
@@ -170,6 +191,7 @@ func (c deployConfig) Prompt() deployConfig {
 		Namespace: prompt.ForString("Namespace", c.Namespace),
 		Path:      prompt.ForString("Project path", c.Path),
 		Verbose:   c.Verbose,
+		Adopt:     c.Adopt,
 	}
 
 	dc.Image = deriveImage(dc.Image, dc.Registry, dc.Path)
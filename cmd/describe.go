@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/ory/viper"
 	"github.com/spf13/cobra"
@@ -110,28 +112,60 @@ type description faas.Description
 
 func (d description) Human(w io.Writer) error {
 	fmt.Fprintln(w, d.Name)
+	fmt.Fprintf(w, "URL: %v\n", d.URL)
+	if d.Ready {
+		fmt.Fprintln(w, "Ready: true")
+	} else {
+		fmt.Fprintf(w, "Ready: false %v\n", d.Message)
+	}
+	fmt.Fprintf(w, "Revisions (Latest, Ready): %v %v\n", d.LatestRevision, d.ReadyRevision)
+	fmt.Fprintln(w, "Environment:")
+	for _, e := range d.EnvVars {
+		fmt.Fprintf(w, "  %v=%v\n", e.Name, e.Value)
+	}
 	fmt.Fprintln(w, "Routes:")
 	for _, route := range d.Routes {
 		fmt.Fprintf(w, "  %v\n", route)
 	}
 	fmt.Fprintln(w, "Subscriptions (Source, Type, Broker):")
 	for _, s := range d.Subscriptions {
-		fmt.Fprintf(w, "  %v %v %v\n", s.Source, s.Type, s.Broker)
+		fmt.Fprintf(w, "  %v %v %v%v\n", s.Source, s.Type, s.Broker, attributes(s))
 	}
 	return d.Plain(w)
 }
 
 func (d description) Plain(w io.Writer) error {
 	fmt.Fprintf(w, "NAME %v\n", d.Name)
+	fmt.Fprintf(w, "URL %v\n", d.URL)
+	fmt.Fprintf(w, "READY %v\n", d.Ready)
+	fmt.Fprintf(w, "REVISION %v %v\n", d.LatestRevision, d.ReadyRevision)
+	for _, e := range d.EnvVars {
+		fmt.Fprintf(w, "ENV %v=%v\n", e.Name, e.Value)
+	}
 	for _, route := range d.Routes {
 		fmt.Fprintf(w, "ROUTE %v\n", route)
 	}
 	for _, s := range d.Subscriptions {
-		fmt.Fprintf(w, "SUBSCRIPTION %v %v %v\n", s.Source, s.Type, s.Broker)
+		fmt.Fprintf(w, "SUBSCRIPTION %v %v %v%v\n", s.Source, s.Type, s.Broker, attributes(s))
 	}
 	return nil
 }
 
+// attributes filtered upon by the Subscription other than its type and
+// source, sorted, as a space-prefixed list of name=value, or empty if none.
+func attributes(s faas.Subscription) string {
+	names := make([]string, 0, len(s.Attributes))
+	for name := range s.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, " %v=%v", name, s.Attributes[name])
+	}
+	return b.String()
+}
+
 func (d description) JSON(w io.Writer) error {
 	return json.NewEncoder(w).Encode(d)
 }
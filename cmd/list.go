@@ -1,11 +1,11 @@
 package cmd
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"os"
+	"text/tabwriter"
 
 	"github.com/ory/viper"
 	"github.com/spf13/cobra"
@@ -18,6 +18,7 @@ import (
 func init() {
 	root.AddCommand(listCmd)
 	listCmd.Flags().StringP("namespace", "n", "", "Override namespace in which to search for Functions.  Default is to use currently active underlying platform setting - $FAAS_NAMESPACE")
+	listCmd.Flags().BoolP("all-namespaces", "A", false, "List Functions in all namespaces.  Overrides --namespace")
 	listCmd.Flags().StringP("format", "f", "human", "optionally specify output format (human|plain|json|xml|yaml) $FAAS_FORMAT")
 
 	err := listCmd.RegisterFlagCompletionFunc("format", CompleteOutputFormatList)
@@ -37,7 +38,7 @@ namespace may be specified on the command line using the --namespace or -n flag.
 If specified this will overwrite the value in faas.yaml.
 `,
 	SuggestFor: []string{"ls", "lsit"},
-	PreRunE:    bindEnv("namespace", "format"),
+	PreRunE:    bindEnv("namespace", "all-namespaces", "format"),
 	RunE:       runList,
 }
 
@@ -49,17 +50,18 @@ func runList(cmd *cobra.Command, args []string) (err error) {
 		return
 	}
 	lister.Verbose = config.Verbose
+	lister.AllNamespaces = config.AllNamespaces
 
 	client := faas.New(
 		faas.WithVerbose(config.Verbose),
 		faas.WithLister(lister))
 
-	nn, err := client.List()
+	ii, err := client.List()
 	if err != nil {
 		return
 	}
 
-	write(os.Stdout, names(nn), config.Format)
+	write(os.Stdout, items(ii), config.Format)
 	return
 }
 
@@ -67,46 +69,55 @@ func runList(cmd *cobra.Command, args []string) (err error) {
 // ------------------------------
 
 type listConfig struct {
-	Namespace string
-	Format    string
-	Verbose   bool
+	Namespace     string
+	AllNamespaces bool
+	Format        string
+	Verbose       bool
 }
 
 func newListConfig() listConfig {
 	return listConfig{
-		Namespace: viper.GetString("namespace"),
-		Format:    viper.GetString("format"),
-		Verbose:   viper.GetBool("verbose"),
+		Namespace:     viper.GetString("namespace"),
+		AllNamespaces: viper.GetBool("all-namespaces"),
+		Format:        viper.GetString("format"),
+		Verbose:       viper.GetBool("verbose"),
 	}
 }
 
 // Output Formatting (serializers)
 // -------------------------------
 
-type names []string
+type items []faas.ListItem
 
-func (nn names) Human(w io.Writer) error {
-	return nn.Plain(w)
+func (ii items) Human(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tNAMESPACE\tREADY\tURL")
+	for _, i := range ii {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", i.Name, i.Namespace, i.Ready, i.URL)
+	}
+	return tw.Flush()
 }
 
-func (nn names) Plain(w io.Writer) error {
-	for _, name := range nn {
-		fmt.Fprintln(w, name)
+func (ii items) Plain(w io.Writer) error {
+	for _, i := range ii {
+		fmt.Fprintln(w, i.Name)
 	}
 	return nil
 }
 
-func (nn names) JSON(w io.Writer) error {
-	return json.NewEncoder(w).Encode(nn)
+func (ii items) JSON(w io.Writer) error {
+	b, err := faas.MarshalList(ii)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
 }
 
-func (nn names) XML(w io.Writer) error {
-	return xml.NewEncoder(w).Encode(nn)
+func (ii items) XML(w io.Writer) error {
+	return xml.NewEncoder(w).Encode(ii)
 }
 
-func (nn names) YAML(w io.Writer) error {
-	// the yaml.v2 package refuses to directly serialize a []string unless
-	// exposed as a public struct member; so an inline anonymous is used.
-	ff := struct{ Names []string }{nn}
-	return yaml.NewEncoder(w).Encode(ff.Names)
+func (ii items) YAML(w io.Writer) error {
+	return yaml.NewEncoder(w).Encode([]faas.ListItem(ii))
 }
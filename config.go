@@ -14,14 +14,71 @@ const ConfigFile = "faas.yaml"
 // Config represents the serialized state of a Function's metadata.
 // See the Function struct for attribute documentation.
 type config struct {
-	Name       string            `yaml:"name"`
-	Namespace  string            `yaml:"namespace"`
-	Runtime    string            `yaml:"runtime"`
-	Image      string            `yaml:"image"`
-	Trigger    string            `yaml:"trigger"`
-	Builder    string            `yaml:"builder"`
-	BuilderMap map[string]string `yaml:"builderMap"`
-	EnvVars    map[string]string `yaml:"envVars"`
+	Name                   string            `yaml:"name"`
+	Namespace              string            `yaml:"namespace"`
+	Runtime                string            `yaml:"runtime"`
+	Image                  string            `yaml:"image"`
+	Registry               string            `yaml:"registry,omitempty"`
+	Trigger                string            `yaml:"trigger"`
+	Schedule               string            `yaml:"schedule,omitempty"`
+	Sink                   string            `yaml:"sink,omitempty"`
+	Subscriptions          []Subscription    `yaml:"subscriptions,omitempty"`
+	Builder                string            `yaml:"builder"`
+	BuilderMap             map[string]string `yaml:"builderMap"`
+	BuildEnvVars           map[string]string `yaml:"buildEnvVars,omitempty"`
+	EnvVars                map[string]string `yaml:"envVars"`
+	EnvFrom                []string          `yaml:"envFrom,omitempty"`
+	MinScale               int               `yaml:"minScale,omitempty"`
+	MaxScale               int               `yaml:"maxScale,omitempty"`
+	InitialScale           *int              `yaml:"initialScale,omitempty"`
+	ScaleDownDelay         string            `yaml:"scaleDownDelay,omitempty"`
+	ScaleWindow            string            `yaml:"scaleWindow,omitempty"`
+	ScaleMetric            string            `yaml:"scaleMetric,omitempty"`
+	ScaleTarget            float64           `yaml:"scaleTarget,omitempty"`
+	TargetRPS              float64           `yaml:"targetRPS,omitempty"`
+	TargetUtilization      int               `yaml:"targetUtilization,omitempty"`
+	ScaleProfile           string            `yaml:"scaleProfile,omitempty"`
+	ScaleClass             string            `yaml:"scaleClass,omitempty"`
+	Concurrency            int64             `yaml:"concurrency,omitempty"`
+	Timeout                int64             `yaml:"timeout,omitempty"`
+	ProgressDeadline       string            `yaml:"progressDeadline,omitempty"`
+	TerminationGracePeriod *int64            `yaml:"terminationGracePeriod,omitempty"`
+	Resources              Resources         `yaml:"resources,omitempty"`
+	Labels                 map[string]string `yaml:"labels,omitempty"`
+	Annotations            map[string]string `yaml:"annotations,omitempty"`
+	ServiceLabels          map[string]string `yaml:"serviceLabels,omitempty"`
+	ServiceAnnotations     map[string]string `yaml:"serviceAnnotations,omitempty"`
+	TemplateLabels         map[string]string `yaml:"templateLabels,omitempty"`
+	TemplateAnnotations    map[string]string `yaml:"templateAnnotations,omitempty"`
+	Traffic                Traffic           `yaml:"traffic,omitempty"`
+	Port                   int32             `yaml:"port,omitempty"`
+	Protocol               string            `yaml:"protocol,omitempty"`
+	Command                []string          `yaml:"command,omitempty"`
+	Args                   []string          `yaml:"args,omitempty"`
+	WorkingDir             string            `yaml:"workingDir,omitempty"`
+	MetricsPort            int32             `yaml:"metricsPort,omitempty"`
+	MetricsPath            string            `yaml:"metricsPath,omitempty"`
+	LivenessProbe          *Probe            `yaml:"livenessProbe,omitempty"`
+	ReadinessProbe         *Probe            `yaml:"readinessProbe,omitempty"`
+	Volumes                []Volume          `yaml:"volumes,omitempty"`
+	InitContainers         []InitContainer   `yaml:"initContainers,omitempty"`
+	Sidecars               []Sidecar         `yaml:"sidecars,omitempty"`
+	NodeSelector           map[string]string `yaml:"nodeSelector,omitempty"`
+	Tolerations            []Toleration      `yaml:"tolerations,omitempty"`
+	NodeAffinity           []NodeRequirement `yaml:"nodeAffinity,omitempty"`
+	ServiceAccountName     string            `yaml:"serviceAccountName,omitempty"`
+	SecurityContext        *SecurityContext  `yaml:"securityContext,omitempty"`
+	ImagePullSecrets       []string          `yaml:"imagePullSecrets,omitempty"`
+	ImagePullPolicy        string            `yaml:"imagePullPolicy,omitempty"`
+	PinImageDigest         bool              `yaml:"pinImageDigest,omitempty"`
+	IngressClass           string            `yaml:"ingressClass,omitempty"`
+	RoutePath              string            `yaml:"routePath,omitempty"`
+	MaxBodySize            string            `yaml:"maxBodySize,omitempty"`
+	ClusterLocal           bool              `yaml:"clusterLocal,omitempty"`
+	Domain                 string            `yaml:"domain,omitempty"`
+	DomainTLSSecret        string            `yaml:"domainTLSSecret,omitempty"`
+	RevisionName           string            `yaml:"revisionName,omitempty"`
+	Features               []string          `yaml:"features,omitempty"`
 	// Add new values to the toConfig/fromConfig functions.
 }
 
@@ -51,28 +108,142 @@ func newConfig(root string) (c config, err error) {
 // Note that config does not include ancillary fields not serialized, such as Root.
 func fromConfig(c config) (f Function) {
 	return Function{
-		Name:       c.Name,
-		Namespace:  c.Namespace,
-		Runtime:    c.Runtime,
-		Image:      c.Image,
-		Trigger:    c.Trigger,
-		Builder:    c.Builder,
-		BuilderMap: c.BuilderMap,
-		EnvVars:    c.EnvVars,
+		Name:                   c.Name,
+		Namespace:              c.Namespace,
+		Runtime:                c.Runtime,
+		Image:                  c.Image,
+		Registry:               c.Registry,
+		Trigger:                c.Trigger,
+		Schedule:               c.Schedule,
+		Sink:                   c.Sink,
+		Subscriptions:          c.Subscriptions,
+		Builder:                c.Builder,
+		BuilderMap:             c.BuilderMap,
+		BuildEnvVars:           c.BuildEnvVars,
+		EnvVars:                c.EnvVars,
+		EnvFrom:                c.EnvFrom,
+		MinScale:               c.MinScale,
+		MaxScale:               c.MaxScale,
+		InitialScale:           c.InitialScale,
+		ScaleDownDelay:         c.ScaleDownDelay,
+		ScaleWindow:            c.ScaleWindow,
+		ScaleMetric:            c.ScaleMetric,
+		ScaleTarget:            c.ScaleTarget,
+		TargetRPS:              c.TargetRPS,
+		TargetUtilization:      c.TargetUtilization,
+		ScaleProfile:           c.ScaleProfile,
+		ScaleClass:             c.ScaleClass,
+		Concurrency:            c.Concurrency,
+		Timeout:                c.Timeout,
+		ProgressDeadline:       c.ProgressDeadline,
+		TerminationGracePeriod: c.TerminationGracePeriod,
+		Resources:              c.Resources,
+		Labels:                 c.Labels,
+		Annotations:            c.Annotations,
+		ServiceLabels:          c.ServiceLabels,
+		ServiceAnnotations:     c.ServiceAnnotations,
+		TemplateLabels:         c.TemplateLabels,
+		TemplateAnnotations:    c.TemplateAnnotations,
+		Traffic:                c.Traffic,
+		Port:                   c.Port,
+		Protocol:               c.Protocol,
+		Command:                c.Command,
+		Args:                   c.Args,
+		WorkingDir:             c.WorkingDir,
+		MetricsPort:            c.MetricsPort,
+		MetricsPath:            c.MetricsPath,
+		LivenessProbe:          c.LivenessProbe,
+		ReadinessProbe:         c.ReadinessProbe,
+		Volumes:                c.Volumes,
+		InitContainers:         c.InitContainers,
+		Sidecars:               c.Sidecars,
+		NodeSelector:           c.NodeSelector,
+		Tolerations:            c.Tolerations,
+		NodeAffinity:           c.NodeAffinity,
+		ServiceAccountName:     c.ServiceAccountName,
+		SecurityContext:        c.SecurityContext,
+		ImagePullSecrets:       c.ImagePullSecrets,
+		ImagePullPolicy:        c.ImagePullPolicy,
+		PinImageDigest:         c.PinImageDigest,
+		IngressClass:           c.IngressClass,
+		RoutePath:              c.RoutePath,
+		MaxBodySize:            c.MaxBodySize,
+		ClusterLocal:           c.ClusterLocal,
+		Domain:                 c.Domain,
+		DomainTLSSecret:        c.DomainTLSSecret,
+		RevisionName:           c.RevisionName,
+		Features:               c.Features,
 	}
 }
 
 // toConfig serializes a Function to a config object.
 func toConfig(f Function) config {
 	return config{
-		Name:       f.Name,
-		Namespace:  f.Namespace,
-		Runtime:    f.Runtime,
-		Image:      f.Image,
-		Trigger:    f.Trigger,
-		Builder:    f.Builder,
-		BuilderMap: f.BuilderMap,
-		EnvVars:    f.EnvVars,
+		Name:                   f.Name,
+		Namespace:              f.Namespace,
+		Runtime:                f.Runtime,
+		Image:                  f.Image,
+		Registry:               f.Registry,
+		Trigger:                f.Trigger,
+		Schedule:               f.Schedule,
+		Sink:                   f.Sink,
+		Subscriptions:          f.Subscriptions,
+		Builder:                f.Builder,
+		BuilderMap:             f.BuilderMap,
+		BuildEnvVars:           f.BuildEnvVars,
+		EnvVars:                f.EnvVars,
+		EnvFrom:                f.EnvFrom,
+		MinScale:               f.MinScale,
+		MaxScale:               f.MaxScale,
+		InitialScale:           f.InitialScale,
+		ScaleDownDelay:         f.ScaleDownDelay,
+		ScaleWindow:            f.ScaleWindow,
+		ScaleMetric:            f.ScaleMetric,
+		ScaleTarget:            f.ScaleTarget,
+		TargetRPS:              f.TargetRPS,
+		TargetUtilization:      f.TargetUtilization,
+		ScaleProfile:           f.ScaleProfile,
+		ScaleClass:             f.ScaleClass,
+		Concurrency:            f.Concurrency,
+		Timeout:                f.Timeout,
+		ProgressDeadline:       f.ProgressDeadline,
+		TerminationGracePeriod: f.TerminationGracePeriod,
+		Resources:              f.Resources,
+		Labels:                 f.Labels,
+		Annotations:            f.Annotations,
+		ServiceLabels:          f.ServiceLabels,
+		ServiceAnnotations:     f.ServiceAnnotations,
+		TemplateLabels:         f.TemplateLabels,
+		TemplateAnnotations:    f.TemplateAnnotations,
+		Traffic:                f.Traffic,
+		Port:                   f.Port,
+		Protocol:               f.Protocol,
+		Command:                f.Command,
+		Args:                   f.Args,
+		WorkingDir:             f.WorkingDir,
+		MetricsPort:            f.MetricsPort,
+		MetricsPath:            f.MetricsPath,
+		LivenessProbe:          f.LivenessProbe,
+		ReadinessProbe:         f.ReadinessProbe,
+		Volumes:                f.Volumes,
+		InitContainers:         f.InitContainers,
+		Sidecars:               f.Sidecars,
+		NodeSelector:           f.NodeSelector,
+		Tolerations:            f.Tolerations,
+		NodeAffinity:           f.NodeAffinity,
+		ServiceAccountName:     f.ServiceAccountName,
+		SecurityContext:        f.SecurityContext,
+		ImagePullSecrets:       f.ImagePullSecrets,
+		ImagePullPolicy:        f.ImagePullPolicy,
+		PinImageDigest:         f.PinImageDigest,
+		IngressClass:           f.IngressClass,
+		RoutePath:              f.RoutePath,
+		MaxBodySize:            f.MaxBodySize,
+		ClusterLocal:           f.ClusterLocal,
+		Domain:                 f.Domain,
+		DomainTLSSecret:        f.DomainTLSSecret,
+		RevisionName:           f.RevisionName,
+		Features:               f.Features,
 	}
 }
 
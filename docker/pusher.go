@@ -4,9 +4,17 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
 	"github.com/boson-project/faas"
 )
 
@@ -14,15 +22,60 @@ import (
 type Pusher struct {
 	// Verbose logging.
 	Verbose bool
+	// Credentials with which to authenticate to the registry, in place of
+	// those with which the docker daemon is logged in.
+	Credentials *Credentials
+	// Keychain resolving the credentials of the registry, such as one backed
+	// by a credentials helper, in place of those with which the docker daemon
+	// is logged in.  Credentials take precedence.
+	Keychain authn.Keychain
+
+	// image reads the image of the reference from the local daemon.
+	image func(name.Reference) (v1.Image, error)
+}
+
+// Credentials of a registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// PusherOption configures a Pusher.
+type PusherOption func(*Pusher)
+
+// WithCredentials authenticates to the registry with the username and
+// password, such as those of a CI robot account.
+func WithCredentials(username, password string) PusherOption {
+	return func(n *Pusher) {
+		n.Credentials = &Credentials{Username: username, Password: password}
+	}
+}
+
+// WithKeychain resolves the credentials of the registry with the keychain.
+func WithKeychain(keychain authn.Keychain) PusherOption {
+	return func(n *Pusher) {
+		n.Keychain = keychain
+	}
 }
 
 // NewPusher creates an instance of a docker-based image pusher.
-func NewPusher() *Pusher {
-	return &Pusher{}
+func NewPusher(options ...PusherOption) *Pusher {
+	n := &Pusher{}
+	for _, o := range options {
+		o(n)
+	}
+	return n
 }
 
-// Push the image of the Function.
+// Push the image of the Function.  With Credentials or a Keychain, the image
+// is read from the docker daemon and pushed directly to the registry, such
+// that the daemon need not be logged in.  Otherwise it is pushed by the
+// docker command using the daemon's login.
 func (n *Pusher) Push(f faas.Function) (err error) {
+	if n.Credentials != nil || n.Keychain != nil {
+		return n.pushDirect(f)
+	}
+
 	// Check for the docker binary explicitly so that we can return
 	// an extra-friendly error message.
 	_, err = exec.LookPath("docker")
@@ -57,3 +110,75 @@ func (n *Pusher) Push(f faas.Function) (err error) {
 	}
 	return
 }
+
+// pushDirect the Function's image from the docker daemon to its registry,
+// authenticating first such that rejected credentials are reported before
+// any layer is uploaded.
+func (n *Pusher) pushDirect(f faas.Function) error {
+	if f.Image == "" {
+		return errors.New("Function has no associated image.  Has it been built?")
+	}
+	ref, err := name.ParseReference(f.Image)
+	if err != nil {
+		return fmt.Errorf("invalid image reference '%v': %v", f.Image, err)
+	}
+	auth, err := n.authenticator(ref.Context().Registry)
+	if err != nil {
+		return err
+	}
+	if err = authenticate(ref, auth); err != nil {
+		return err
+	}
+
+	read := n.image
+	if read == nil {
+		read = func(ref name.Reference) (v1.Image, error) { return daemon.Image(ref) }
+	}
+	img, err := read(ref)
+	if err != nil {
+		return fmt.Errorf("unable to read image '%v' from the docker daemon: %v", f.Image, err)
+	}
+	if n.Verbose {
+		fmt.Printf("Pushing %v\n", f.Image)
+	}
+	if err = remote.Write(ref, img, remote.WithAuth(auth)); err != nil {
+		return fmt.Errorf("unable to push image '%v': %v", f.Image, err)
+	}
+	return nil
+}
+
+// authenticator of the registry, being that of the Credentials if provided,
+// or otherwise resolved by the Keychain.
+func (n *Pusher) authenticator(registry name.Registry) (authn.Authenticator, error) {
+	if n.Credentials != nil {
+		return &authn.Basic{Username: n.Credentials.Username, Password: n.Credentials.Password}, nil
+	}
+	auth, err := n.Keychain.Resolve(registry)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve the credentials of registry '%v': %v", registry, err)
+	}
+	return auth, nil
+}
+
+// authenticate to the registry of the reference with permission to push,
+// erroring if the credentials are rejected.
+func authenticate(ref name.Reference, auth authn.Authenticator) error {
+	registry := ref.Context().Registry
+	scopes := []string{ref.Context().Scope(transport.PushScope)}
+	t, err := transport.New(registry, auth, http.DefaultTransport, scopes)
+	if err != nil {
+		return fmt.Errorf("unable to authenticate to registry '%v': %v", registry, err)
+	}
+
+	// Registries challenging for basic auth accept any credentials until a
+	// request is made with them.
+	resp, err := (&http.Client{Transport: t}).Get(fmt.Sprintf("%v://%v/v2/", registry.Scheme(), registry.RegistryStr()))
+	if err != nil {
+		return fmt.Errorf("unable to authenticate to registry '%v': %v", registry, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("registry '%v' rejected the credentials: %v", registry, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package docker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/boson-project/faas"
+)
+
+// basicAuthRegistry is an in-memory registry accepting only the given
+// credentials, counting the uploads attempted.
+func basicAuthRegistry(username, password string, uploads *int) *httptest.Server {
+	handler := registry.New()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.Contains(r.URL.Path, "/blobs/uploads") {
+			*uploads++
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// TestPushWithCredentials ensures that the image is pushed to a registry
+// with the configured credentials, and that rejected credentials are
+// reported before any layer is uploaded.
+func TestPushWithCredentials(t *testing.T) {
+	uploads := 0
+	server := basicAuthRegistry("robot", "s3cret", &uploads)
+	defer server.Close()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := strings.TrimPrefix(server.URL, "http://") + "/alice/f:latest"
+	f := faas.Function{Image: image}
+
+	pusher := NewPusher(WithCredentials("robot", "s3cret"))
+	pusher.image = func(name.Reference) (v1.Image, error) { return img, nil }
+	if err = pusher.Push(f); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := remote.Image(ref, remote.WithAuth(&authn.Basic{Username: "robot", Password: "s3cret"}))
+	if err != nil {
+		t.Fatalf("expected the image to have been pushed, got '%v'", err)
+	}
+	if expected, _ := img.Digest(); func() v1.Hash { d, _ := pushed.Digest(); return d }() != expected {
+		t.Fatal("expected the pushed image to be that of the daemon")
+	}
+
+	uploads = 0
+	pusher = NewPusher(WithCredentials("robot", "wrong"))
+	pusher.image = func(name.Reference) (v1.Image, error) { return img, nil }
+	err = pusher.Push(f)
+	if err == nil || !strings.Contains(err.Error(), "rejected the credentials") {
+		t.Fatalf("expected the credentials to be rejected, got '%v'", err)
+	}
+	if uploads != 0 {
+		t.Fatalf("expected no upload to be attempted, got %v", uploads)
+	}
+}
+
+// staticKeychain resolves the same authenticator for every registry.
+type staticKeychain struct{ auth authn.Authenticator }
+
+func (k staticKeychain) Resolve(authn.Resource) (authn.Authenticator, error) { return k.auth, nil }
+
+// TestPushWithKeychain ensures that credentials are resolved by a keychain,
+// such as that of a credentials helper.
+func TestPushWithKeychain(t *testing.T) {
+	uploads := 0
+	server := basicAuthRegistry("robot", "s3cret", &uploads)
+	defer server.Close()
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pusher := NewPusher(WithKeychain(staticKeychain{&authn.Basic{Username: "robot", Password: "s3cret"}}))
+	pusher.image = func(name.Reference) (v1.Image, error) { return img, nil }
+	if err = pusher.Push(faas.Function{Image: strings.TrimPrefix(server.URL, "http://") + "/alice/f:latest"}); err != nil {
+		t.Fatal(err)
+	}
+	if uploads == 0 {
+		t.Fatal("expected the image's layers to be uploaded")
+	}
+}
@@ -1,73 +1,269 @@
 package docker
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
 
 	"github.com/boson-project/faas"
 )
 
-// Runner of functions using the docker command.
+const (
+	// DefaultRunPort on which a Function is served, both within its
+	// container and on the host, unless otherwise configured.
+	DefaultRunPort = 8080
+
+	// stopTimeout is the time a stopped Function is given to exit before it
+	// is killed.
+	stopTimeout = 10 * time.Second
+)
+
+// ContainerClient is the subset of the Docker API used by the Runner.
+type ContainerClient interface {
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error)
+	ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error
+	ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error)
+	ContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions) (io.ReadCloser, error)
+	ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error
+	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
+}
+
+// Runner of Functions as local containers, using the Docker API.
 type Runner struct {
 	// Verbose logging flag.
 	Verbose bool
+	// Port on the host at which the Function is reachable.  Zero uses the
+	// port of the Function.
+	Port int
+	// HostIP is the address of the host's interface on which the Function is
+	// reachable, such as 127.0.0.1 to restrict it to the local host.  Empty
+	// binds all interfaces, as does docker run -p.
+	HostIP string
+	// SecretsDir holds the values of the Secrets and ConfigMaps to which the
+	// Function's environment variables refer, as files of the form
+	// <SecretsDir>/<name>/<key>.  If not provided, the .secrets directory of
+	// the Function's root is used.
+	SecretsDir string
+	// Output to which the Function's logs are streamed.  Defaults to
+	// os.Stdout.
+	Output io.Writer
+
+	// client to use in place of one constructed from the environment.
+	client ContainerClient
+}
+
+// RunnerOption configures a Runner.
+type RunnerOption func(*Runner)
+
+// WithContainerClient provides the client of the Docker API to use, such as
+// a mock for testing, in place of one constructed from the environment.
+func WithContainerClient(c ContainerClient) RunnerOption {
+	return func(n *Runner) {
+		n.client = c
+	}
 }
 
 // NewRunner creates an instance of a docker-backed runner.
-func NewRunner() *Runner {
-	return &Runner{}
+func NewRunner(options ...RunnerOption) *Runner {
+	n := &Runner{}
+	for _, o := range options {
+		o(n)
+	}
+	return n
 }
 
-// Run the function at path
+// Run the Function until interrupted, streaming its logs.
 func (n *Runner) Run(f faas.Function) error {
-	// Check for the docker binary explicitly so that we can return
-	// an extra-friendly error message.
-	_, err := exec.LookPath("docker")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	url, done, err := n.Start(ctx, f)
 	if err != nil {
-		return errors.New("please install 'docker'")
+		return err
 	}
+	fmt.Fprintf(n.output(), "Function running on %v\n", url)
+	return <-done
+}
 
+// Start the Function's container, returning the URL at which it is
+// reachable.  Its logs are streamed to the Output until it exits or the
+// context is canceled, upon which it is stopped.  Either way the container is
+// then removed, and the result sent on the returned channel: nil if stopped,
+// or an error if it failed.
+func (n *Runner) Start(ctx context.Context, f faas.Function) (url string, done <-chan error, err error) {
 	if f.Image == "" {
-		return errors.New("Function has no associated image.  Has it been built?")
+		return "", nil, errors.New("Function has no associated image.  Has it been built?")
+	}
+	cli, err := n.containerClient()
+	if err != nil {
+		return
+	}
+	config, hostConfig, err := n.containerConfig(f)
+	if err != nil {
+		return
 	}
 
-	// Extra arguments to docker
-	args := []string{"run", "--rm", "-t", "-p=8080:8080"}
+	created, err := cli.ContainerCreate(ctx, config, hostConfig, nil, "")
+	if err != nil {
+		return "", nil, fmt.Errorf("docker runner failed to create the container: %v", err)
+	}
+	id := created.ID
+	remove := func() {
+		_ = cli.ContainerRemove(context.Background(), id, types.ContainerRemoveOptions{Force: true})
+	}
 
-	for name, value := range f.EnvVars {
-		if !strings.HasSuffix(name,"-") {
-			args = append(args, fmt.Sprintf("-e%s=%s", name, value))
-		}
+	// Waiting prior to starting ensures an immediate exit is not missed.
+	exited, waitErrs := cli.ContainerWait(context.Background(), id, container.WaitConditionNextExit)
+	if err = cli.ContainerStart(ctx, id, types.ContainerStartOptions{}); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("docker runner failed to start the container: %v", err)
 	}
 
-	// If verbosity is enabled, pass along as an environment variable to the Function.
-	if n.Verbose {
-		args = append(args, "-e VERBOSE=true")
+	logs, err := cli.ContainerLogs(context.Background(), id, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if err != nil {
+		_ = cli.ContainerStop(context.Background(), id, nil)
+		remove()
+		return "", nil, fmt.Errorf("docker runner failed to stream the container's logs: %v", err)
 	}
-	args = append(args, f.Image)
+	streamed := make(chan struct{})
+	go func() {
+		defer close(streamed)
+		defer logs.Close()
+		_, _ = stdcopy.StdCopy(n.output(), n.output(), logs)
+	}()
 
-	// Set up the command with extra arguments and to run rooted at path
-	cmd := exec.Command("docker", args...)
-	cmd.Dir = f.Root
+	result := make(chan error, 1)
+	go func() {
+		var err error
+		select {
+		case <-ctx.Done():
+			timeout := stopTimeout
+			if err = cli.ContainerStop(context.Background(), id, &timeout); err != nil {
+				err = fmt.Errorf("docker runner failed to stop the container: %v", err)
+			}
+		case status := <-exited:
+			if status.StatusCode != 0 {
+				err = fmt.Errorf("Function exited with status %v", status.StatusCode)
+			}
+		case err = <-waitErrs:
+			err = fmt.Errorf("docker runner failed waiting for the container: %v", err)
+		}
+		<-streamed
+		remove()
+		result <- err
+	}()
+
+	return fmt.Sprintf("http://localhost:%v/", hostConfig.PortBindings[n.containerPort(f)][0].HostPort), result, nil
+}
+
+// containerConfig of the Function, exposing its port on the host and with
+// its environment variables.
+func (n *Runner) containerConfig(f faas.Function) (*container.Config, *container.HostConfig, error) {
+	env, err := n.environment(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	port := n.containerPort(f)
+	hostPort := n.Port
+	if hostPort == 0 {
+		hostPort = port.Int()
+	}
+	config := &container.Config{
+		Image:        f.Image,
+		Env:          env,
+		ExposedPorts: nat.PortSet{port: struct{}{}},
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings: nat.PortMap{port: []nat.PortBinding{{HostIP: n.HostIP, HostPort: strconv.Itoa(hostPort)}}},
+	}
+	return config, hostConfig, nil
+}
+
+// containerPort on which the Function is served.
+func (n *Runner) containerPort(f faas.Function) nat.Port {
+	port := int(f.Port)
+	if port == 0 {
+		port = DefaultRunPort
+	}
+	return nat.Port(fmt.Sprintf("%v/tcp", port))
+}
 
-	// If verbose logging is enabled, echo command
-	if n.Verbose {
-		fmt.Println(cmd)
+// environment of the Function's container, sorted by name.  References to
+// Secrets and ConfigMaps are resolved from the files of the SecretsDir, less
+// any trailing newline, and variables to be removed (NAME-) are omitted.
+func (n *Runner) environment(f faas.Function) (env []string, err error) {
+	dir := n.SecretsDir
+	if dir == "" {
+		dir = filepath.Join(f.Root, ".secrets")
 	}
+	for name, value := range f.EnvVars {
+		if strings.HasSuffix(name, "-") {
+			continue
+		}
+		ref, err := faas.ParseEnvVarReference(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for env var '%v': %v", name, err)
+		}
+		if ref != nil {
+			path := filepath.Join(dir, ref.Name, ref.Key)
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve the value of env var '%v' for local use from %v: %v", name, path, err)
+			}
+			value = strings.TrimSuffix(string(data), "\n")
+		}
+		env = append(env, name+"="+value)
+	}
+	// If verbosity is enabled, pass along as an environment variable to the Function.
+	if _, ok := f.EnvVars["VERBOSE"]; n.Verbose && !ok {
+		env = append(env, "VERBOSE=true")
+	}
+	sort.Strings(env)
+	return
+}
 
-	// We need to show the user all output, so a method to squelch
-	// docker's chattiness is not immediately apparent.
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func (n *Runner) containerClient() (ContainerClient, error) {
+	if n.client != nil {
+		return n.client, nil
+	}
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("docker runner failed to connect to docker: %v", err)
+	}
+	return cli, nil
+}
 
-	// Run the command, echoing captured stderr as well ass the cmd internal error.
-	// Will run until explicitly canceled.
-	// TODO: this runner is current stubbed pending an architectural discussion
-	// on how closely we would like to emulate the previous funcitonality, and
-	// if we can use Grid as a localhost integraiton events fabric.
-	fmt.Println(cmd)
-	return cmd.Run()
+func (n *Runner) output() io.Writer {
+	if n.Output == nil {
+		return os.Stdout
+	}
+	return n.Output
 }
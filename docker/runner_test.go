@@ -1,10 +1,23 @@
 package docker_test
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
 
 	"github.com/boson-project/faas"
 	"github.com/boson-project/faas/docker"
@@ -42,3 +55,151 @@ func TestDockerRun(t *testing.T) {
 	*/
 
 }
+
+// mockContainerClient records the container created, whose logs are those
+// given, and which exits with the given status or when stopped.
+type mockContainerClient struct {
+	config     *container.Config
+	hostConfig *container.HostConfig
+	logs       string
+	status     int64
+	exited     chan container.ContainerWaitOKBody
+	started    bool
+	stopped    bool
+	removed    bool
+}
+
+func newMockContainerClient() *mockContainerClient {
+	return &mockContainerClient{exited: make(chan container.ContainerWaitOKBody, 1)}
+}
+
+func (c *mockContainerClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, _ *network.NetworkingConfig, _ string) (container.ContainerCreateCreatedBody, error) {
+	c.config, c.hostConfig = config, hostConfig
+	return container.ContainerCreateCreatedBody{ID: "f"}, nil
+}
+
+func (c *mockContainerClient) ContainerStart(ctx context.Context, id string, _ types.ContainerStartOptions) error {
+	c.started = true
+	if c.status != 0 {
+		c.exited <- container.ContainerWaitOKBody{StatusCode: c.status}
+	}
+	return nil
+}
+
+func (c *mockContainerClient) ContainerWait(ctx context.Context, id string, _ container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error) {
+	return c.exited, make(chan error)
+}
+
+func (c *mockContainerClient) ContainerLogs(ctx context.Context, id string, _ types.ContainerLogsOptions) (io.ReadCloser, error) {
+	var b bytes.Buffer
+	fmt.Fprint(stdcopy.NewStdWriter(&b, stdcopy.Stdout), c.logs)
+	return ioutil.NopCloser(&b), nil
+}
+
+func (c *mockContainerClient) ContainerStop(ctx context.Context, id string, _ *time.Duration) error {
+	c.stopped = true
+	c.exited <- container.ContainerWaitOKBody{}
+	return nil
+}
+
+func (c *mockContainerClient) ContainerRemove(ctx context.Context, id string, _ types.ContainerRemoveOptions) error {
+	c.removed = true
+	return nil
+}
+
+// TestRunnerStart ensures that the container is configured as the Function:
+// its image, port and env vars, including those resolved from Secrets for
+// local use, and that it is stopped and removed once canceled.
+func TestRunnerStart(t *testing.T) {
+	secrets, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(secrets)
+	if err = os.MkdirAll(filepath.Join(secrets, "db"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(secrets, "db", "password"), []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newMockContainerClient()
+	client.logs = "listening\n"
+	var output bytes.Buffer
+	runner := docker.NewRunner(docker.WithContainerClient(client))
+	runner.SecretsDir = secrets
+	runner.Output = &output
+	runner.Verbose = true
+
+	f := faas.Function{
+		Image:   "quay.io/alice/f:latest",
+		Port:    9000,
+		EnvVars: map[string]string{"B": "2", "PASSWORD": "{{ secret:db:password }}", "A-": ""},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done, err := runner.Start(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "http://localhost:9000/" {
+		t.Fatalf("expected the function at http://localhost:9000/, got '%v'", url)
+	}
+	if client.config.Image != f.Image {
+		t.Fatalf("expected image '%v', got '%v'", f.Image, client.config.Image)
+	}
+	if !reflect.DeepEqual(client.config.Env, []string{"B=2", "PASSWORD=s3cret", "VERBOSE=true"}) {
+		t.Fatalf("unexpected env %v", client.config.Env)
+	}
+	if _, ok := client.config.ExposedPorts["9000/tcp"]; !ok {
+		t.Fatalf("expected port 9000 to be exposed, got %v", client.config.ExposedPorts)
+	}
+	if bindings := client.hostConfig.PortBindings["9000/tcp"]; len(bindings) != 1 || bindings[0].HostPort != "9000" || bindings[0].HostIP != "" {
+		t.Fatalf("expected port 9000 to be bound on all interfaces of the host, got %v", bindings)
+	}
+
+	cancel()
+	if err = <-done; err != nil {
+		t.Fatalf("expected a canceled function to stop cleanly, got '%v'", err)
+	}
+	if !client.started || !client.stopped || !client.removed {
+		t.Fatalf("expected the container to be started, stopped and removed, got %+v", client)
+	}
+	if output.String() != "listening\n" {
+		t.Fatalf("expected the function's logs to be streamed, got '%v'", output.String())
+	}
+}
+
+// TestRunnerStartErrors ensures that a Function which exits in failure, or
+// whose Secrets are malformed or not available locally, errors.
+func TestRunnerStartErrors(t *testing.T) {
+	client := newMockContainerClient()
+	client.status = 1
+	runner := docker.NewRunner(docker.WithContainerClient(client))
+	runner.Output = ioutil.Discard
+
+	f := faas.Function{Image: "quay.io/alice/f:latest"}
+	url, done, err := runner.Start(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "http://localhost:8080/" {
+		t.Fatalf("expected the function at the default port, got '%v'", url)
+	}
+	if err = <-done; err == nil || !strings.Contains(err.Error(), "status 1") {
+		t.Fatalf("expected the exit status to be reported, got '%v'", err)
+	}
+	if !client.removed {
+		t.Fatal("expected the exited container to be removed")
+	}
+
+	runner.SecretsDir = "/nonexistent"
+	f.EnvVars = map[string]string{"PASSWORD": "{{ secret:db:password }}"}
+	if _, _, err = runner.Start(context.Background(), f); err == nil {
+		t.Fatal("expected a secret not available locally to error")
+	}
+
+	f.EnvVars = map[string]string{"PASSWORD": "{{ secret:db }}"}
+	if _, _, err = runner.Start(context.Background(), f); err == nil {
+		t.Fatal("expected a malformed secret reference to error")
+	}
+}
@@ -26,8 +26,24 @@ type Function struct {
 	// Trigger of the Function.  http|events etc.
 	Trigger string
 
+	// Schedule on which the Function is invoked, in standard cron format, such
+	// as "*/5 * * * *".  It is realized on deploy as a PingSource.
+	Schedule string
+
+	// Sink to which the Function sends the CloudEvents it emits, as provided
+	// to it in K_SINK: a Broker or Service of the form 'broker:name' or
+	// 'service:name', or an absolute URL.
+	Sink string
+
+	// Subscriptions of the Function to the CloudEvents of Knative Eventing
+	// Brokers, each realized on deploy as a Trigger of which the Function is
+	// the subscriber.
+	Subscriptions []Subscription
+
 	// Registry at which to store interstitial containers, in the form
-	// [registry]/[user]. If omitted, "Image" must be provided.
+	// [registry]/[user]. If omitted, "Image" must be provided.  It is that
+	// from which the Image was last derived, such that an Image derived from
+	// it is derived anew when built for another registry.
 	Registry string
 
 	// Optional full OCI image tag in form:
@@ -38,7 +54,8 @@ type Function struct {
 	// example:
 	//   alice/my.function.name
 	// If Image is provided, it overrides the default of concatenating
-	// "Registry+Name:latest" to derive the Image.
+	// "Registry+Name:latest" to derive the Image, regardless of the registry.
+	// An Image which was itself derived from the Registry is not explicit.
 	Image string
 
 	// Builder represents the CNCF Buildpack builder image for a function,
@@ -49,7 +66,384 @@ type Function struct {
 	// e.g. { "jvm": "docker.io/example/quarkus-jvm-builder" }
 	BuilderMap map[string]string
 
+	// BuildEnvVars are provided to the build alone, such as tokens for
+	// fetching private modules, and are never set on the deployed Function.
+	BuildEnvVars map[string]string
+
 	EnvVars map[string]string
+
+	// EnvFrom imports all keys of the given Secrets or ConfigMaps as
+	// environment variables, with each entry in the form 'secret:name' or
+	// 'configMap:name'.  Explicitly set EnvVars take precedence.
+	EnvFrom []string
+
+	// MinScale is the minimum number of instances of the Function kept
+	// running.  Zero leaves the platform default (scale to zero) in effect.
+	MinScale int
+
+	// MaxScale is the maximum number of instances to which the Function may
+	// be scaled.  Zero leaves the platform default (unbounded) in effect.
+	MaxScale int
+
+	// InitialScale is the number of instances of the Function started when it
+	// is first deployed, before the autoscaler takes over, such that a minimum
+	// scale is reached without waiting on it.  Zero requires the cluster to
+	// permit a zero initial scale.  If not provided, the platform default
+	// (usually 1) is in effect.
+	InitialScale *int
+
+	// ScaleDownDelay is the duration, such as "15m", for which the Function
+	// is kept running after it is last needed before scaling down, avoiding
+	// cold starts.  Empty leaves the platform default (no delay) in effect.
+	ScaleDownDelay string
+
+	// ScaleWindow is the duration, such as "120s", over which requests are
+	// averaged when deciding to scale.  Empty leaves the platform default.
+	ScaleWindow string
+
+	// ScaleMetric on which the Function is scaled: "concurrency", the number
+	// of requests in flight, or "rps", requests per second.  Empty leaves the
+	// platform default (concurrency) in effect.
+	ScaleMetric string
+
+	// ScaleTarget is the value of the ScaleMetric per instance which the
+	// autoscaler aims to maintain.  Zero leaves the platform default in
+	// effect.
+	ScaleTarget float64
+
+	// TargetRPS is the requests per second which each instance aims to
+	// handle, scaling on the rps metric.  It is shorthand for a ScaleMetric
+	// of "rps" with the ScaleTarget, so excludes their use.  Zero leaves them
+	// in effect.
+	TargetRPS float64
+
+	// TargetUtilization is the percentage (1-100) of the ScaleTarget at which
+	// the autoscaler aims to keep each instance, scaling up before it is
+	// reached.  Lower values reduce cold starts at the cost of provisioning
+	// more instances.  Zero leaves the platform default (70) in effect.
+	TargetUtilization int
+
+	// ScaleProfile expands into a coherent set of scaling settings for those
+	// not otherwise configured: "latency" keeps an instance warm and each
+	// lightly loaded, "throughput" packs each densely and scales down slowly,
+	// and "balanced" makes the platform defaults explicit.  Supported by the
+	// Knative Pod Autoscaler alone.
+	ScaleProfile string
+
+	// ScaleClass of the autoscaler by which the Function is scaled: the
+	// Knative Pod Autoscaler (kpa.autoscaling.knative.dev, the default), the
+	// Kubernetes Horizontal Pod Autoscaler (hpa.autoscaling.knative.dev), or
+	// that of another installed autoscaler.
+	ScaleClass string
+
+	// Concurrency is the maximum number of concurrent requests handled by a
+	// single instance of the Function.  Zero is unlimited.
+	Concurrency int64
+
+	// Timeout is the maximum duration in seconds for which a request to the
+	// Function may run.  Zero leaves the platform default (usually 300) in
+	// effect.
+	Timeout int64
+
+	// ProgressDeadline is the duration, such as "15m", within which a new
+	// revision of the Function must become ready before it is marked failed,
+	// allowing slow-starting Functions more time.  Empty leaves the platform
+	// default (usually 10m) in effect.
+	ProgressDeadline string
+
+	// TerminationGracePeriod is the duration in seconds a stopping instance
+	// of the Function is given to finish in-flight work, such as flushing
+	// buffers, before it is killed.  If not provided, the platform default
+	// (usually 30) is in effect.
+	TerminationGracePeriod *int64
+
+	// Resources requested by and limits imposed upon the Function when
+	// running on a cluster.
+	Resources Resources
+
+	// Labels applied to the deployed Function.
+	Labels map[string]string
+
+	// Annotations applied to the deployed Function.  Those which take effect
+	// only on pods, such as sidecar.istio.io/inject, are applied to its
+	// pods alone.
+	Annotations map[string]string
+
+	// ServiceLabels and ServiceAnnotations are applied to the deployed
+	// Function's Service alone, taking precedence over Labels and Annotations.
+	ServiceLabels      map[string]string
+	ServiceAnnotations map[string]string
+
+	// TemplateLabels and TemplateAnnotations are applied to the deployed
+	// Function's revisions, and so its pods, alone, taking precedence over
+	// Labels and Annotations.
+	TemplateLabels      map[string]string
+	TemplateAnnotations map[string]string
+
+	// Traffic split between the newly deployed revision and that which
+	// preceded it, for gradual rollouts.  If unset, all traffic is routed to
+	// the latest revision.
+	Traffic Traffic
+
+	// Port on which the Function listens.  If not provided, the platform
+	// default (8080) is assumed.
+	Port int32
+
+	// Protocol with which the Function serves requests: http1 (HTTP/1.1), or
+	// h2c (HTTP/2 without TLS), as required by gRPC.  If not provided, HTTP/1.1
+	// is assumed.
+	Protocol string
+
+	// Command of the Function's container, replacing the image's entrypoint.
+	// If not provided, that of the image is used.
+	Command []string
+
+	// Args of the Function's container, replacing those of the image.  If not
+	// provided, those of the image are used.
+	Args []string
+
+	// WorkingDir of the Function's container, an absolute path, replacing
+	// that of the image.  If not provided, that of the image is used.
+	WorkingDir string
+
+	// MetricsPort on which the Function serves Prometheus metrics, when
+	// scraping is enabled on deploy.  If not provided, the Function's Port
+	// is assumed.
+	MetricsPort int32
+
+	// MetricsPath at which the Function serves Prometheus metrics.  If not
+	// provided, /metrics is assumed.
+	MetricsPath string
+
+	// LivenessProbe optionally checks that the Function is alive, with the
+	// Function restarted if it fails.
+	LivenessProbe *Probe
+
+	// ReadinessProbe optionally checks that the Function is ready to receive
+	// requests, such as after a slow warm-up.
+	ReadinessProbe *Probe
+
+	// Volumes mounted into the Function's filesystem.
+	Volumes []Volume
+
+	// InitContainers run to completion, in order, before the Function is
+	// started, such as to download a model file.  Support requires that the
+	// init containers feature of Knative Serving be enabled.
+	InitContainers []InitContainer
+
+	// Sidecars run alongside the Function, such as a proxy or agent.  When
+	// provided, exactly one of the Function and its sidecars must declare the
+	// port on which requests are served.  Support requires that the
+	// multi-container feature of Knative Serving be enabled.
+	Sidecars []Sidecar
+
+	// NodeSelector restricts the Function to the nodes bearing each of the
+	// labels, such as those of a GPU node pool.  Support requires that the
+	// node selector feature of Knative Serving be enabled.
+	NodeSelector map[string]string
+
+	// Tolerations permit the Function to be scheduled on nodes with matching
+	// taints, such as those of dedicated nodes.  Support requires that the
+	// tolerations feature of Knative Serving be enabled.
+	Tolerations []Toleration
+
+	// NodeAffinity restricts the Function to the nodes whose labels meet each
+	// of the requirements, permitting expressions beyond those of a
+	// NodeSelector.  Support requires that the affinity feature of Knative
+	// Serving be enabled.
+	NodeAffinity []NodeRequirement
+
+	// ServiceAccountName under which the Function runs.  If not provided,
+	// the default of the namespace applies.
+	ServiceAccountName string
+
+	// SecurityContext of the Function's container, such as to run it as a
+	// non-root user with a read-only root filesystem.  Knative Serving
+	// permits some of its fields only when the security context feature is
+	// enabled, and others not at all prior to later versions.
+	SecurityContext *SecurityContext
+
+	// ImagePullSecrets are the names of Secrets holding credentials for
+	// pulling the Function's image from a private registry.  The Secrets are
+	// expected to already exist in the namespace to which it is deployed.
+	ImagePullSecrets []string
+
+	// ImagePullPolicy of the Function's container: Always, IfNotPresent or
+	// Never.  IfNotPresent or Never permit running an image already loaded
+	// onto the cluster's nodes, such as in air-gapped clusters.  If not
+	// provided, the Kubernetes default applies.
+	ImagePullPolicy string
+
+	// PinImageDigest resolves the Image to the digest to which it refers at
+	// the time of deployment, such that the deployed revision is immutable.
+	PinImageDigest bool
+
+	// IngressClass of the Knative networking layer through which the Function
+	// is exposed, such as kourier.ingress.networking.knative.dev, placing it
+	// for example on an internal rather than external gateway.  If not
+	// provided the cluster default applies.
+	IngressClass string
+
+	// RoutePath under which the Function is exposed on a host shared with
+	// other Functions, such as /api/greeter, rather than on a host of its own.
+	// It is applied by the annotation of the IngressClass, so requires one
+	// known to route by path, those of Knative routing by host alone.
+	RoutePath string
+
+	// MaxBodySize of requests to the Function, a quantity such as "8Mi",
+	// beyond which they are rejected by the ingress.  It is applied by the
+	// annotation of the IngressClass where it has one, and otherwise as a
+	// hint, so requires an ingress class which limits the size of requests.
+	MaxBodySize string
+
+	// ClusterLocal Functions are reachable only from within the cluster, and
+	// are not exposed by a public route.
+	ClusterLocal bool
+
+	// Domain is a custom hostname, such as api.example.com, at which the
+	// Function is additionally reachable, mapped to its Service by a Knative
+	// DomainMapping.  DNS for the hostname must resolve to the cluster's
+	// ingress.
+	Domain string
+
+	// DomainTLSSecret is the name of the Secret, of type kubernetes.io/tls,
+	// holding the certificate and key with which the Domain is served over
+	// TLS.  It must exist in the Function's namespace.
+	DomainTLSSecret string
+
+	// RevisionName of the revision created by the next deployment, such as a
+	// build ID or git SHA.  It is prefixed with the name of the Service if
+	// not already, and must change with each deployment.  If not provided,
+	// revision names are generated.
+	RevisionName string
+
+	// Features of Knative Serving enabled for the Function alone, by name, such
+	// as "podspec-dryrun".  Each is applied as the annotation enabling it per
+	// resource, which takes effect where the cluster's flag for the feature is
+	// "Allowed".
+	Features []string
+}
+
+// Volume mounted at a path within a Function's filesystem.
+type Volume struct {
+	// Source of the volume in the form 'secret:name' or 'configMap:name', or
+	// 'emptyDir' for writable scratch space which lasts only as long as the
+	// instance.  Support for emptyDir volumes requires that the emptyDir
+	// feature of Knative Serving be enabled.
+	Source string `yaml:"source"`
+	// Path at which the volume is mounted.
+	Path string `yaml:"path"`
+	// Size limit of an emptyDir volume, such as 500Mi.  If not provided, it
+	// is limited only by the ephemeral storage of the node.
+	Size string `yaml:"size,omitempty"`
+}
+
+// InitContainer run prior to the Function.
+type InitContainer struct {
+	// Name of the container.  If not provided, init-<index> is used.
+	Name string `yaml:"name,omitempty"`
+	// Image of the container.
+	Image string `yaml:"image"`
+	// Command of the container, replacing the image's entrypoint.
+	Command []string `yaml:"command,omitempty"`
+	// EnvVars of the container, which may refer to Secrets and ConfigMaps as
+	// do those of the Function.
+	EnvVars map[string]string `yaml:"envVars,omitempty"`
+	// Volumes of the Function, by path, which are also mounted into the
+	// container at the same path.
+	Volumes []string `yaml:"volumes,omitempty"`
+}
+
+// Toleration of the taints of a node.
+type Toleration struct {
+	// Key of the taint tolerated.  If empty, with the Exists operator, all
+	// taints are tolerated.
+	Key string `yaml:"key,omitempty"`
+	// Operator is Equal (the default), in which case the Value must match,
+	// or Exists.
+	Operator string `yaml:"operator,omitempty"`
+	// Value of the taint tolerated.
+	Value string `yaml:"value,omitempty"`
+	// Effect of the taint tolerated: NoSchedule, PreferNoSchedule or
+	// NoExecute.  If empty, all effects are tolerated.
+	Effect string `yaml:"effect,omitempty"`
+	// Seconds for which a taint with the NoExecute effect is tolerated
+	// before the Function is evicted.  If not provided, indefinitely.
+	Seconds *int64 `yaml:"seconds,omitempty"`
+}
+
+// NodeRequirement on the labels of a node.
+type NodeRequirement struct {
+	// Key of the label.
+	Key string `yaml:"key"`
+	// Operator is one of In, NotIn, Exists, DoesNotExist, Gt or Lt.
+	Operator string `yaml:"operator"`
+	// Values of the label for In and NotIn, none for Exists and DoesNotExist,
+	// and a single integer for Gt and Lt.
+	Values []string `yaml:"values,omitempty"`
+}
+
+// SecurityContext with which the Function's container runs.
+type SecurityContext struct {
+	// RunAsNonRoot requires that the container run as a user other than
+	// root, failing to start otherwise.
+	RunAsNonRoot *bool `yaml:"runAsNonRoot,omitempty"`
+	// RunAsUser is the UID with which the container runs, in place of that
+	// of the image.
+	RunAsUser *int64 `yaml:"runAsUser,omitempty"`
+	// ReadOnlyRootFilesystem mounts the root filesystem of the container
+	// read-only, such that only its volumes are writable.
+	ReadOnlyRootFilesystem *bool `yaml:"readOnlyRootFilesystem,omitempty"`
+	// DropCapabilities of the container, such as NET_RAW, or ALL.
+	DropCapabilities []string `yaml:"dropCapabilities,omitempty"`
+}
+
+// Sidecar container run alongside the Function.
+type Sidecar struct {
+	// Name of the container.  If not provided, sidecar-<index> is used.
+	Name string `yaml:"name,omitempty"`
+	// Image of the container.
+	Image string `yaml:"image"`
+	// EnvVars of the container, which may refer to Secrets and ConfigMaps as
+	// do those of the Function.
+	EnvVars map[string]string `yaml:"envVars,omitempty"`
+	// Port on which the container serves requests, if it rather than the
+	// Function receives them, such as a proxy.
+	Port int32 `yaml:"port,omitempty"`
+}
+
+// Probe of a Function's health via an HTTP GET request.
+type Probe struct {
+	// Path of the request.  ex: /health/readiness
+	Path string `yaml:"path"`
+	// Port of the request.  If not provided, the Function's port is used.
+	Port int32 `yaml:"port,omitempty"`
+	// InitialDelaySeconds before the first probe.
+	InitialDelaySeconds int32 `yaml:"initialDelaySeconds,omitempty"`
+	// PeriodSeconds between probes.
+	PeriodSeconds int32 `yaml:"periodSeconds,omitempty"`
+}
+
+// Traffic split, in percent, between the latest revision and the previous.
+// ex: a canary deployment of Latest 10, Previous 90.  When set, the two must
+// sum to 100.
+type Traffic struct {
+	Latest   int64 `yaml:"latest"`
+	Previous int64 `yaml:"previous"`
+}
+
+// Resources are the compute resources requested by and limits imposed upon
+// a Function instance.
+type Resources struct {
+	Requests ResourceList `yaml:"requests,omitempty"`
+	Limits   ResourceList `yaml:"limits,omitempty"`
+}
+
+// ResourceList of compute resources, expressed in Kubernetes quantity
+// notation.  ex: cpu "250m", memory "64Mi".  Empty values are not set.
+type ResourceList struct {
+	CPU    string `yaml:"cpu,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
 }
 
 // NewFunction loads a Function from a path on disk. use .Initialized() to determine if
@@ -111,6 +505,11 @@ func (f Function) Initialized() bool {
 // example docker.io/alice/my.example.func:latest
 // Default if not provided is --registry (a required global setting)
 // followed by the provided (or derived) image name.
+//
+// Precedence is given to an explicit image, being one set on the Function
+// which was not derived from its Registry.  Otherwise the image is derived
+// from the given registry, or the Function's Registry if none is given, such
+// that a Function is retargeted to another registry without being renamed.
 func DerivedImage(root, registry string) (image string, err error) {
 	f, err := NewFunction(root)
 	if err != nil {
@@ -122,12 +521,18 @@ func DerivedImage(root, registry string) (image string, err error) {
 		return
 	}
 
-	// If the Function has already had image populated, use this pre-calculated value.
-	if f.Image != "" {
+	// If the Function has an explicitly provided image, use this value.
+	if f.Image != "" && !f.imageDerived() {
 		image = f.Image
 		return
 	}
 
+	// Lacking a registry, a previously-derived image is that of the
+	// Function's Registry.
+	if registry == "" {
+		registry = f.Registry
+	}
+
 	// registry is currently required until such time as we support
 	// pushing to an implicitly-available in-cluster registry by default.
 	if registry == "" {
@@ -135,19 +540,35 @@ func DerivedImage(root, registry string) (image string, err error) {
 		return
 	}
 
-	// If the Function loaded, and there is not yet an Image set, then this is
-	// the first build and no explicit image override was specified.  We should
-	// therefore derive the image tag from the defined registry and name.
-	// form:    [registry]/[user]/[function]:latest
-	// example: quay.io/alice/my.function.name:latest
+	// There is not yet an Image set, or it was derived, and no explicit image
+	// override was specified.  We should therefore derive the image tag from
+	// the defined registry and name.
+	return imageOf(registry, f.Name)
+}
+
+// imageDerived returns whether the Function's Image is that derived from its
+// Registry, rather than explicitly provided.
+func (f Function) imageDerived() bool {
+	if f.Registry == "" {
+		return false
+	}
+	image, err := imageOf(f.Registry, f.Name)
+	return err == nil && image == f.Image
+}
+
+// imageOf the named Function at the registry.
+// form:    [registry]/[user]/[function]:latest
+// example: quay.io/alice/my.function.name:latest
+func imageOf(registry, name string) (image string, err error) {
 	registry = strings.Trim(registry, "/") // too defensive?
 	registryTokens := strings.Split(registry, "/")
 	if len(registryTokens) == 1 {
-		image = DefaultRegistry + "/" + registry + "/" + f.Name
+		image = DefaultRegistry + "/" + registry + "/" + name
 	} else if len(registryTokens) == 2 {
-		image = registry + "/" + f.Name
+		image = registry + "/" + name
 	} else {
 		err = fmt.Errorf("registry should be either 'namespace' or 'registry/namespace'")
+		return
 	}
 
 	// Explicitly append :latest.  We currently expect source control to drive
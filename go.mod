@@ -4,9 +4,13 @@ go 1.14
 
 require (
 	github.com/buildpacks/pack v0.14.0
+	github.com/docker/docker v1.4.2-0.20200221181110-62bd5a33f707
+	github.com/docker/go-connections v0.4.0
+	github.com/google/go-containerregistry v0.1.2
 	github.com/markbates/pkger v0.17.1
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/ory/viper v1.7.4
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/spf13/cobra v1.0.1-0.20201006035406-b97b5ead31f7
 	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f // indirect
 	gopkg.in/yaml.v2 v2.3.0
@@ -15,7 +19,10 @@ require (
 	k8s.io/client-go v11.0.1-0.20190805182717-6502b5e7b1b5+incompatible
 	knative.dev/client v0.17.2
 	knative.dev/eventing v0.17.5
+	knative.dev/networking v0.0.0-20200831172815-5f2e0ad6215f
+	knative.dev/pkg v0.0.0-20200831162708-14fb2347fb77
 	knative.dev/serving v0.17.3
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/util/validation"
@@ -15,6 +16,10 @@ import (
 // start with an alphabetic character, and end with an alphanumeric character"
 func ToK8sAllowedName(in string) (string, error) {
 
+	if err := ValidateFunctionName(in); err != nil {
+		return "", err
+	}
+
 	out := []rune{}
 	for _, c := range in {
 		// convert dots to hyphens
@@ -30,6 +35,9 @@ func ToK8sAllowedName(in string) (string, error) {
 
 	result := string(out)
 
+	if len(result) > validation.DNS1035LabelMaxLength {
+		return "", fmt.Errorf("name '%v' is %v characters when encoded as '%v', exceeding the maximum of %v", in, len(result), result, validation.DNS1035LabelMaxLength)
+	}
 	if errs := validation.IsDNS1035Label(result); len(errs) > 0 {
 		return "", errors.New(strings.Join(errs, ","))
 	}
@@ -37,10 +45,40 @@ func ToK8sAllowedName(in string) (string, error) {
 	return result, nil
 }
 
+// ValidateFunctionName ensures the name is an RFC 1123 subdomain which begins
+// with a letter, such that it may be encoded by ToK8sAllowedName, returning
+// an error describing the first offending character if not.
+func ValidateFunctionName(name string) error {
+	if name == "" {
+		return errors.New("name is required")
+	}
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return fmt.Errorf("invalid character '%c' at position %v of name '%v': only lower case alphanumeric characters, '-' and '.' are allowed", c, i, name)
+		}
+	}
+	if c := name[0]; c < 'a' || c > 'z' {
+		return fmt.Errorf("invalid character '%c' at position 0 of name '%v': must begin with a letter", c, name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("invalid name '%v': each dot-separated part must begin and end with an alphanumeric character", name)
+		}
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid name '%v': %v", name, strings.Join(errs, ","))
+	}
+	return nil
+}
+
 // FromK8sAllowedName converts a name which has been encoded as
 // an allowed k8s name using the algorithm of ToK8sAllowedName back to the original.
 // www-my--domain-com -> www.my-domain.com
-// Input errors if not a 1035 label.
+// Input errors if not a 1035 label, as such a name could not have been
+// produced by ToK8sAllowedName.  Every valid label decodes to exactly one
+// name: a doubled dash is an escaped dash, and any other dash an encoded dot.
+// Labels with an odd run of three or more dashes decode to names which are
+// not themselves valid (a---b -> a-.b), and so do not encode back.
 func FromK8sAllowedName(in string) (string, error) {
 
 	if errs := validation.IsDNS1035Label(in); len(errs) > 0 {
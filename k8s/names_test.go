@@ -1,6 +1,9 @@
 package k8s
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 // TestToK8sAllowedName ensures that a valid name is
 // encoded into k8s allowed name.
@@ -54,3 +57,93 @@ func TestFromK8sAllowedName(t *testing.T) {
 	}
 
 }
+
+// TestK8sAllowedNameRoundTrip ensures that names with dots, hyphens and
+// consecutive hyphens survive encoding and decoding, and that valid labels
+// decode to names which encode back to them.
+func TestK8sAllowedNameRoundTrip(t *testing.T) {
+	names := []string{
+		"example.com",
+		"www.my-domain.com",
+		"my--domain.com",
+		"a-b-c.d",
+		"cdn--1.my-domain.com",
+	}
+	for _, name := range names {
+		encoded, err := ToK8sAllowedName(name)
+		if err != nil {
+			t.Fatalf("unexpected error encoding '%v': %v", name, err)
+		}
+		decoded, err := FromK8sAllowedName(encoded)
+		if err != nil {
+			t.Fatalf("unexpected error decoding '%v': %v", encoded, err)
+		}
+		if decoded != name {
+			t.Fatalf("expected '%v' to round-trip, got '%v' via '%v'", name, decoded, encoded)
+		}
+	}
+
+	labels := []string{"example", "a-b", "a--b", "a----b"}
+	for _, label := range labels {
+		decoded, err := FromK8sAllowedName(label)
+		if err != nil {
+			t.Fatalf("unexpected error decoding '%v': %v", label, err)
+		}
+		encoded, err := ToK8sAllowedName(decoded)
+		if err != nil {
+			t.Fatalf("unexpected error encoding '%v': %v", decoded, err)
+		}
+		if encoded != label {
+			t.Fatalf("expected '%v' to round-trip, got '%v' via '%v'", label, encoded, decoded)
+		}
+	}
+
+	// Names which could not have been produced by encoding can not be decoded.
+	for _, label := range []string{"Example", "-a", "a-", "1a", "a_b", "a.b"} {
+		if _, err := FromK8sAllowedName(label); err == nil {
+			t.Fatalf("expected '%v' not to be decodable", label)
+		}
+	}
+}
+
+// TestValidateFunctionName ensures that names which are not RFC 1123
+// subdomains beginning with a letter, or which are too long once encoded, are
+// rejected with an error identifying the problem.
+func TestValidateFunctionName(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Valid    bool
+		Contains string // expected within the error
+	}{
+		{"example.com", true, ""},
+		{"my-domain.com", true, ""},
+		{"a1", true, ""},
+		{"", false, "required"},
+		{"Example.com", false, "'E' at position 0"},
+		{"exAmple", false, "'A' at position 2"},
+		{"my_function", false, "'_' at position 2"},
+		{"1example", false, "'1' at position 0"},
+		{"-example", false, "'-' at position 0"},
+		{"example-.com", false, "alphanumeric"},
+		{"example..com", false, "alphanumeric"},
+		{"example.", false, "alphanumeric"},
+		{strings.Repeat("a", 63), true, ""},
+		{strings.Repeat("a", 64), false, "64 characters"},
+		{strings.Repeat("a-", 31) + "a", false, "exceeding the maximum"}, // 63 long, 94 encoded
+	}
+
+	for _, c := range cases {
+		_, err := ToK8sAllowedName(c.Name)
+		if c.Valid && err != nil {
+			t.Fatalf("expected '%v' to be valid, got %v", c.Name, err)
+		}
+		if !c.Valid {
+			if err == nil {
+				t.Fatalf("expected '%v' to be invalid", c.Name)
+			}
+			if !strings.Contains(err.Error(), c.Contains) {
+				t.Fatalf("expected error for '%v' to contain '%v', got '%v'", c.Name, c.Contains, err)
+			}
+		}
+	}
+}
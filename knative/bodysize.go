@@ -0,0 +1,97 @@
+package knative
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+)
+
+// maxBodySizeAnnotation of the Service is the maximum size in bytes of the
+// body of a request to the Function, a hint honoured by ingress classes
+// without an annotation of their own.
+const maxBodySizeAnnotation = "boson.dev/max-body-size"
+
+// bodySizeAnnotations are those by which the ingress classes of third party
+// networking layers known to do so limit the size of request bodies, by
+// class.  Each accepts a size in bytes.
+var bodySizeAnnotations = map[string]string{
+	"haproxy": "haproxy-ingress.github.io/proxy-body-size",
+	"nginx":   "nginx.ingress.kubernetes.io/proxy-body-size",
+}
+
+// maxBodySize parses the size, a quantity such as "8Mi", in bytes, being zero
+// if empty.  It must be positive.
+func maxBodySize(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max body size '%v': %v", size, err)
+	}
+	if q.Sign() <= 0 {
+		return 0, fmt.Errorf("max body size '%v' must be positive", size)
+	}
+	return q.Value(), nil
+}
+
+// updateMaxBodySize annotates the Service with the Function's max body size
+// by the annotation of the given ingress class, or otherwise by the hint
+// annotation, removing those of other classes unless configured as
+// annotations of the Function.
+func updateMaxBodySize(service *servingv1.Service, class string, f faas.Function) error {
+	size, err := maxBodySize(f.MaxBodySize)
+	if err != nil {
+		return err
+	}
+	desired := ""
+	if size > 0 {
+		if desired = bodySizeAnnotations[class]; desired == "" {
+			desired = maxBodySizeAnnotation
+		}
+	}
+	configured := scopedMetadata(f.Annotations, f.ServiceAnnotations)
+	for _, key := range append([]string{maxBodySizeAnnotation}, bodySizeAnnotationKeys()...) {
+		if _, ok := configured[key]; key != desired && !ok {
+			delete(service.Annotations, key)
+		}
+	}
+	if desired == "" {
+		return nil
+	}
+	if service.Annotations == nil {
+		service.Annotations = map[string]string{}
+	}
+	service.Annotations[desired] = strconv.FormatInt(size, 10)
+	return nil
+}
+
+// bodySizeAnnotationKeys of all ingress classes.
+func bodySizeAnnotationKeys() (keys []string) {
+	for _, key := range bodySizeAnnotations {
+		keys = append(keys, key)
+	}
+	return
+}
+
+// checkMaxBodySize ensures the ingress class through which a Function with a
+// max body size is exposed is not one of those of Knative, which do not limit
+// the size of requests.  Other classes not known to are passed through with a
+// warning, as the hint annotation may be honoured.  An unknown class, being
+// empty, is not checked.
+func (d *Deployer) checkMaxBodySize(serviceName string, f faas.Function, class string) error {
+	if f.MaxBodySize == "" || class == "" {
+		return nil
+	}
+	if knownIngressClasses[class] {
+		return &DeployError{Kind: ErrServiceInvalid, Err: fmt.Errorf("max body size '%v' is not supported by the ingress '%v', which does not limit the size of requests; an ingress class which limits them by annotation is required", f.MaxBodySize, class)}
+	}
+	if _, ok := bodySizeAnnotations[class]; !ok {
+		d.warn(serviceName, "ingress class '%v' is not known to limit the size of requests, so may not honour the %v annotation", class, maxBodySizeAnnotation)
+	}
+	return nil
+}
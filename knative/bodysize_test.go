@@ -0,0 +1,89 @@
+package knative
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	network "knative.dev/networking/pkg"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestGenerateNewServiceMaxBodySize ensures that the max body size is applied
+// in bytes by the annotation of the ingress class, or otherwise as a hint,
+// that that of a previous class is removed, and that it is validated.
+func TestGenerateNewServiceMaxBodySize(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", MaxBodySize: "8Mi"}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, maxBodySizeAnnotation, "8388608")
+
+	f.IngressClass = "nginx"
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, bodySizeAnnotations["nginx"], "8388608")
+	assertAnnotation(t, service.Annotations, maxBodySizeAnnotation, "")
+
+	f.MaxBodySize = ""
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, bodySizeAnnotations["nginx"], "")
+
+	for _, size := range []string{"lots", "0", "-1Mi"} {
+		f.MaxBodySize = size
+		if _, err := generateNewService("f", f, nil); err == nil {
+			t.Fatalf("expected max body size '%v' to be rejected", size)
+		}
+	}
+}
+
+// TestDeployMaxBodySize ensures that the max body size is rejected prior to
+// deployment where the Function is exposed through an ingress of Knative,
+// whether configured or the cluster default, and is otherwise annotated.
+func TestDeployMaxBodySize(t *testing.T) {
+	networkConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: servingSystemNamespace, Name: network.ConfigName},
+		Data:       map[string]string{network.DefaultIngressClassKey: "nginx"},
+	}
+	cases := []struct {
+		name       string
+		class      string
+		coreClient *fake.Clientset
+		annotation string
+	}{
+		{"cluster default", "", fake.NewSimpleClientset(networkConfig), bodySizeAnnotations["nginx"]},
+		{"configured", "haproxy", fake.NewSimpleClientset(networkConfig), bodySizeAnnotations["haproxy"]},
+		{"unknown ingress", "gateway.example.com", fake.NewSimpleClientset(), maxBodySizeAnnotation},
+		{"unreadable default", "", fake.NewSimpleClientset(), maxBodySizeAnnotation},
+		{"knative ingress", "kourier.ingress.networking.knative.dev", fake.NewSimpleClientset(), ""},
+		{"implicit default", "", fake.NewSimpleClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: servingSystemNamespace, Name: network.ConfigName},
+		}), ""},
+	}
+	for _, c := range cases {
+		client := knativetest.NewServingClient()
+		deployer := &Deployer{client: client, coreClient: c.coreClient}
+		_, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", IngressClass: c.class, MaxBodySize: "1M"})
+		if c.annotation == "" {
+			if !errors.Is(err, ErrServiceInvalid) {
+				t.Fatalf("%v: expected the max body size to be unsupported, got '%v'", c.name, err)
+			}
+			if client.Creates != 0 {
+				t.Fatalf("%v: expected no service to be created", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		assertAnnotation(t, client.Services["f"].Annotations, c.annotation, "1000000")
+	}
+}
@@ -1,13 +1,20 @@
 package knative
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	clienteventingv1beta1 "knative.dev/client/pkg/eventing/v1beta1"
 	clientservingv1 "knative.dev/client/pkg/serving/v1"
+	clientsourcesv1alpha2 "knative.dev/client/pkg/sources/v1alpha2"
 	eventingv1beta1 "knative.dev/eventing/pkg/client/clientset/versioned/typed/eventing/v1beta1"
+	sourcesv1alpha2 "knative.dev/eventing/pkg/client/clientset/versioned/typed/sources/v1alpha2"
+	"knative.dev/serving/pkg/apis/serving"
 	servingv1 "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1"
 )
 
@@ -15,9 +22,34 @@ const (
 	DefaultWaitingTimeout = 60 * time.Second
 )
 
-func NewServingClient(namespace string) (clientservingv1.KnServingClient, error) {
+// ClientOption configures how clients connect to the cluster.
+type ClientOption func(*clientOptions)
 
-	restConfig, err := getClientConfig().ClientConfig()
+type clientOptions struct {
+	kubeconfig string
+	context    string
+}
+
+// WithKubeconfigPath loads the cluster configuration from the given
+// kubeconfig file rather than from the default locations (KUBECONFIG or
+// ~/.kube/config).
+func WithKubeconfigPath(path string) ClientOption {
+	return func(o *clientOptions) {
+		o.kubeconfig = path
+	}
+}
+
+// WithKubeContext selects the named context of the kubeconfig rather than its
+// current context.
+func WithKubeContext(name string) ClientOption {
+	return func(o *clientOptions) {
+		o.context = name
+	}
+}
+
+func NewServingClient(namespace string, options ...ClientOption) (clientservingv1.KnServingClient, error) {
+
+	restConfig, err := getClientConfig(options...).ClientConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new serving client: %v", err)
 	}
@@ -32,9 +64,9 @@ func NewServingClient(namespace string) (clientservingv1.KnServingClient, error)
 	return client, nil
 }
 
-func NewEventingClient(namespace string) (clienteventingv1beta1.KnEventingClient, error) {
+func NewEventingClient(namespace string, options ...ClientOption) (clienteventingv1beta1.KnEventingClient, error) {
 
-	restConfig, err := getClientConfig().ClientConfig()
+	restConfig, err := getClientConfig(options...).ClientConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new serving client: %v", err)
 	}
@@ -49,20 +81,124 @@ func NewEventingClient(namespace string) (clienteventingv1beta1.KnEventingClient
 	return client, nil
 }
 
-func GetNamespace(defaultNamespace string) (namespace string, err error) {
-	namespace = defaultNamespace
+func NewSourcesClient(namespace string, options ...ClientOption) (clientsourcesv1alpha2.KnSourcesClient, error) {
+
+	restConfig, err := getClientConfig(options...).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new sources client: %v", err)
+	}
+
+	sourcesClient, err := sourcesv1alpha2.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new sources client: %v", err)
+	}
+
+	client := clientsourcesv1alpha2.NewKnSourcesClient(sourcesClient, namespace)
+
+	return client, nil
+}
+
+func NewKubernetesClient(options ...ClientOption) (kubernetes.Interface, error) {
+
+	restConfig, err := getClientConfig(options...).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new kubernetes client: %v", err)
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new kubernetes client: %v", err)
+	}
+
+	return client, nil
+}
+
+// NewDynamicClient of resources for which there is no typed client.
+func NewDynamicClient(options ...ClientOption) (dynamic.Interface, error) {
 
-	if defaultNamespace == "" {
-		namespace, _, err = getClientConfig().Namespace()
-		if err != nil {
-			return
+	restConfig, err := getClientConfig(options...).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new dynamic client: %v", err)
+	}
+
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new dynamic client: %v", err)
+	}
+
+	return client, nil
+}
+
+// ErrServingNotInstalled indicates that the cluster does not serve the
+// Knative Serving API.
+var ErrServingNotInstalled = errors.New("Knative Serving is not installed on the cluster (API group serving.knative.dev not found)")
+
+// CheckServing ensures the cluster is reachable and serves the Knative Serving
+// API group, returning ErrServingNotInstalled if it does not.
+func CheckServing(client discovery.DiscoveryInterface) error {
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return &DeployError{Kind: ErrClusterUnreachable, Op: "unable to connect to the cluster", Err: err}
+	}
+	for _, group := range groups.Groups {
+		if group.Name == serving.GroupName {
+			return nil
 		}
 	}
+	return ErrServingNotInstalled
+}
+
+// DefaultNamespace is that used when neither provided explicitly nor by the
+// selected context of the kubeconfig.
+const DefaultNamespace = "default"
+
+// KubeconfigError indicates that the kubeconfig could not be loaded, for
+// example because it is malformed.
+type KubeconfigError struct {
+	Err error
+}
+
+func (e *KubeconfigError) Error() string {
+	return fmt.Sprintf("invalid kubeconfig: %v", e.Err)
+}
+
+func (e *KubeconfigError) Unwrap() error {
+	return e.Err
+}
+
+// GetNamespace returns the namespace to use, in order of precedence:
+// the given namespace, that of the selected context of the kubeconfig, or
+// DefaultNamespace.  A kubeconfig which is present but can not be loaded
+// results in a KubeconfigError.
+func GetNamespace(defaultNamespace string, options ...ClientOption) (namespace string, err error) {
+	if defaultNamespace != "" {
+		return defaultNamespace, nil
+	}
+
+	namespace, _, err = getClientConfig(options...).Namespace()
+	if clientcmd.IsEmptyConfig(err) {
+		return DefaultNamespace, nil
+	}
+	if err != nil {
+		return "", &KubeconfigError{Err: err}
+	}
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
 	return
 }
 
-func getClientConfig() clientcmd.ClientConfig {
+func getClientConfig(options ...ClientOption) clientcmd.ClientConfig {
+	o := clientOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if o.kubeconfig != "" {
+		rules.ExplicitPath = o.kubeconfig
+	}
 	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		clientcmd.NewDefaultClientConfigLoadingRules(),
-		&clientcmd.ConfigOverrides{})
+		rules,
+		&clientcmd.ConfigOverrides{CurrentContext: o.context})
 }
@@ -0,0 +1,166 @@
+package knative
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com:6443
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    namespace: dev-ns
+- name: prod
+  context:
+    cluster: prod
+    namespace: prod-ns
+current-context: dev
+`
+
+// TestClientKubeconfig ensures that clients and the default namespace are
+// derived from an explicit kubeconfig path and context.
+func TestClientKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+	if err = ioutil.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		context   string
+		namespace string
+		host      string
+	}{
+		{"", "dev-ns", "https://dev.example.com:6443"},
+		{"prod", "prod-ns", "https://prod.example.com:6443"},
+	}
+	for _, c := range cases {
+		options := []ClientOption{WithKubeconfigPath(path), WithKubeContext(c.context)}
+
+		namespace, err := GetNamespace("", options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if namespace != c.namespace {
+			t.Fatalf("expected namespace '%v' for context '%v', got '%v'", c.namespace, c.context, namespace)
+		}
+
+		restConfig, err := getClientConfig(options...).ClientConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restConfig.Host != c.host {
+			t.Fatalf("expected host '%v' for context '%v', got '%v'", c.host, c.context, restConfig.Host)
+		}
+
+		if _, err = NewServingClient(namespace, options...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deployer, err := NewDeployer(WithKubeconfig(path, "prod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployer.Namespace != "prod-ns" {
+		t.Fatalf("expected deployer namespace 'prod-ns', got '%v'", deployer.Namespace)
+	}
+	remover, err := NewRemover("", WithRemoverKubeconfig(path, "prod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remover.Namespace != "prod-ns" {
+		t.Fatalf("expected remover namespace 'prod-ns', got '%v'", remover.Namespace)
+	}
+	if namespace, _ := GetNamespace("override", WithKubeconfigPath(path)); namespace != "override" {
+		t.Fatalf("expected explicit namespace to take precedence, got '%v'", namespace)
+	}
+}
+
+// TestGetNamespace ensures the precedence of an explicit namespace, that of
+// the kubeconfig's context, and the default, and that a malformed kubeconfig
+// results in a KubeconfigError.
+func TestGetNamespace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	withNamespace := filepath.Join(dir, "with-namespace")
+	withoutNamespace := filepath.Join(dir, "without-namespace")
+	malformed := filepath.Join(dir, "malformed")
+	files := map[string]string{
+		withNamespace:    testKubeconfig,
+		withoutNamespace: strings.Replace(testKubeconfig, "namespace: dev-ns", "", 1),
+		malformed:        "{{ not a kubeconfig",
+	}
+	for path, content := range files {
+		if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(v string) { os.Setenv("KUBECONFIG", v) }(os.Getenv("KUBECONFIG"))
+
+	cases := []struct {
+		name       string
+		override   string
+		kubeconfig string
+		expected   string
+	}{
+		{"override", "explicit", withNamespace, "explicit"},
+		{"context", "", withNamespace, "dev-ns"},
+		{"context without namespace", "", withoutNamespace, DefaultNamespace},
+		{"no kubeconfig", "", filepath.Join(dir, "missing"), DefaultNamespace},
+	}
+	for _, c := range cases {
+		os.Setenv("KUBECONFIG", c.kubeconfig)
+		namespace, err := GetNamespace(c.override)
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if namespace != c.expected {
+			t.Fatalf("%v: expected namespace '%v', got '%v'", c.name, c.expected, namespace)
+		}
+	}
+
+	os.Setenv("KUBECONFIG", malformed)
+	_, err = GetNamespace("")
+	if _, ok := err.(*KubeconfigError); !ok {
+		t.Fatalf("expected a KubeconfigError for a malformed kubeconfig, got %v", err)
+	}
+}
+
+// TestCheckServing ensures that a cluster lacking the Knative Serving API
+// group results in ErrServingNotInstalled.
+func TestCheckServing(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if err := CheckServing(client.Discovery()); err != ErrServingNotInstalled {
+		t.Fatalf("expected ErrServingNotInstalled, got %v", err)
+	}
+
+	client.Resources = []*metav1.APIResourceList{{GroupVersion: "serving.knative.dev/v1"}}
+	if err := CheckServing(client.Discovery()); err != nil {
+		t.Fatalf("expected Knative Serving to be found, got %v", err)
+	}
+}
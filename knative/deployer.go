@@ -1,15 +1,33 @@
 package knative
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	k8swait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 	servinglib "knative.dev/client/pkg/serving"
+	clientservingv1 "knative.dev/client/pkg/serving/v1"
 	"knative.dev/client/pkg/wait"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/pkg/apis"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	apisconfig "knative.dev/serving/pkg/apis/config"
+	"knative.dev/serving/pkg/apis/serving"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 
@@ -17,17 +35,510 @@ import (
 	"github.com/boson-project/faas/k8s"
 )
 
+// verboseEnvVarName is the environment variable which enables verbose
+// logging within a deployed Function.
+const verboseEnvVarName = "VERBOSE"
+
+// DefaultCreateRetries is the number of times creating a Service is retried
+// upon a transient error.
+const DefaultCreateRetries = 3
+
+// now returns the current time, and is replaced in tests.
+var now = time.Now
+
+// createBackoff is the backoff between attempts to create a Service.
+var createBackoff = k8swait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+}
+
 type Deployer struct {
 	// Namespace with which to override that set on the default configuration (such as the ~/.kube/config).
 	// If left blank, deployment will commence to the configured namespace.
 	Namespace string
 	// Verbose logging enablement flag.
 	Verbose bool
+	// BaselineEnv seeds the environment of the Function's container, such as
+	// with the defaults of its runtime, before the Function's own environment
+	// variables are applied.  If not provided, VERBOSE is set when Verbose.
+	BaselineEnv BaselineEnvFunc
+	// WaitTimeout is the maximum time to wait for a created or updated
+	// Service to become ready.  Zero uses DefaultWaitingTimeout.
+	WaitTimeout time.Duration
+	// NoWait returns as soon as the Service is created or updated, without
+	// waiting for it to become ready.  Failures of the new revision to become
+	// ready then go unreported, and no URL is returned as the route may not
+	// yet exist.
+	NoWait bool
+	// SkipPreflight skips verifying that the cluster is reachable and has
+	// Knative Serving installed prior to deploying.
+	SkipPreflight bool
+	// EnsureNamespace creates the Namespace prior to deploying if it does not
+	// already exist.
+	EnsureNamespace bool
+	// CreateRetries is the number of times creating the Service is retried
+	// upon a transient error.  Zero uses DefaultCreateRetries.
+	CreateRetries int
+	// LabelPrefix overrides the domain of the label identifying the Service
+	// as a Function, for example "example.com" for "example.com/function".
+	LabelPrefix string
+	// ManifestDir, if set, is the directory to which the manifests of the
+	// Function are written in place of applying them to the cluster.
+	ManifestDir string
+	// Kubeconfig is the path of the kubeconfig file from which to load the
+	// cluster configuration.  If empty the default locations apply.
+	Kubeconfig string
+	// Context of the kubeconfig to use.  If empty its current context applies.
+	Context string
+	// Metrics enables scraping of the Function's metrics by Prometheus,
+	// annotating its revisions with the port and path at which they are
+	// served.
+	Metrics bool
+	// OnEvent, if provided, is invoked with the progress of each deployment.
+	OnEvent func(Event)
+	// Warnings of each deployment, such as of an unrecognized ingress class,
+	// are written to this writer, os.Stderr if not set.  They are emitted as
+	// events regardless, so may be suppressed with ioutil.Discard.
+	Warnings io.Writer
+	// OnMessage, if provided, receives the human-readable progress messages
+	// reported by Knative while waiting for the Service to become ready, such
+	// as for display beside a spinner.
+	OnMessage wait.MessageCallback
+	// Force an update of an existing Service, and thus a new revision, even
+	// when it is already as the Function configures.  By default such an
+	// update is skipped, avoiding revision churn when deploying repeatedly.
+	// An image referred to by tag is pulled anew only by a new revision, so
+	// deploying a rebuilt image of the same tag requires Force.
+	Force bool
+	// Adopt an existing Service of the Function's name which is not labelled
+	// as a Function, such as one created by other means, updating it as the
+	// Function configures.  By default such a Service is left untouched.
+	Adopt bool
+	// Resolver of image digests for Functions which pin their image by
+	// digest.  If not provided the image's registry is queried.
+	Resolver ImageResolver
+	// RegistryMirrors rewrite the registry host of each Function's image, from
+	// that of each key to its value, such that the image is pulled through a
+	// mirror.  The image of the Function itself is unchanged.
+	RegistryMirrors map[string]string
+	// PreDeploy, if provided, is invoked prior to creating or updating the
+	// Service, an error preventing it from being applied.  It is not invoked
+	// when writing manifests to a ManifestDir.
+	PreDeploy DeployHook
+	// PostDeploy, if provided, is invoked once the Service is ready, or once
+	// it is applied if NoWait, with the result of the deployment.  An error
+	// fails the deployment, the Service remaining deployed.
+	PostDeploy DeployHook
+	// ReportHookErrors reports the errors of the PreDeploy and PostDeploy
+	// hooks as warnings, in place of failing the deployment.
+	ReportHookErrors bool
+
+	// client to use in place of one constructed for the Namespace.
+	client ServingClient
+	// coreClient to use in place of one constructed from the default
+	// configuration.
+	coreClient kubernetes.Interface
+	// eventing client to use in place of one constructed for the Namespace.
+	eventing eventingClient
+	// pingSources client to use in place of one constructed for the
+	// Namespace.
+	pingSources pingSourceClient
+	// domainMappings client to use in place of one constructed for the
+	// Namespace.
+	domainMappings domainMappingClient
+	// namespaceClients by namespace, to use in place of those constructed for
+	// each namespace deployed to by DeployAll.
+	namespaceClients map[string]ServingClient
+}
+
+// ServingClient is the subset of the Knative serving client used by the
+// Deployer.  Those of its methods taking a context return the context's error
+// once it is done, such as when canceled.  A creation or update abandoned so
+// may yet be applied, for which an error of Kind ErrOutcomeUnknown is returned.
+type ServingClient interface {
+	GetService(ctx context.Context, name string) (*servingv1.Service, error)
+	CreateService(ctx context.Context, service *servingv1.Service) error
+	UpdateServiceWithRetry(ctx context.Context, name string, updateFunc func(*servingv1.Service) (*servingv1.Service, error), nrRetries int) error
+	WaitForService(name string, timeout time.Duration, msgCallback wait.MessageCallback) (error, time.Duration)
+	GetRoute(ctx context.Context, name string) (*servingv1.Route, error)
+	GetRevision(name string) (*servingv1.Revision, error)
+	DeleteService(name string, timeout time.Duration) error
+	WaitForDeletion(name string, timeout time.Duration) error
+	WaitForRevision(serviceName, revisionName string, timeout time.Duration) error
+	ListServicesWithLabel(key, value string) (*servingv1.ServiceList, error)
+	ListRevisionsOfService(serviceName string) (*servingv1.RevisionList, error)
+	DeleteRevision(name string, timeout time.Duration) error
+}
+
+// knServingClient adapts the Knative serving client to a ServingClient.
+type knServingClient struct {
+	clientservingv1.KnServingClient
+}
+
+func (c knServingClient) GetService(ctx context.Context, name string) (*servingv1.Service, error) {
+	service, _, err := withContext(ctx, func() (interface{}, error) {
+		return c.KnServingClient.GetService(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return service.(*servingv1.Service), nil
+}
+
+func (c knServingClient) CreateService(ctx context.Context, service *servingv1.Service) error {
+	_, abandoned, err := withContext(ctx, func() (interface{}, error) {
+		return nil, c.KnServingClient.CreateService(service)
+	})
+	if abandoned {
+		return outcomeUnknown("creation", service.Name, err)
+	}
+	return err
+}
+
+func (c knServingClient) UpdateServiceWithRetry(ctx context.Context, name string, updateFunc func(*servingv1.Service) (*servingv1.Service, error), nrRetries int) error {
+	_, abandoned, err := withContext(ctx, func() (interface{}, error) {
+		return nil, c.KnServingClient.UpdateServiceWithRetry(name, updateFunc, nrRetries)
+	})
+	if abandoned {
+		return outcomeUnknown("update", name, err)
+	}
+	return err
+}
+
+func (c knServingClient) GetRoute(ctx context.Context, name string) (*servingv1.Route, error) {
+	route, _, err := withContext(ctx, func() (interface{}, error) {
+		return c.KnServingClient.GetRoute(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return route.(*servingv1.Route), nil
+}
+
+// withContext invokes the call, returning early with the context's error
+// should it be done first, in which case the call is abandoned.  The Knative
+// client accepts no context, so an abandoned call runs to completion in the
+// background, leaking its goroutine until then, and its result is discarded.
+// The result is passed only over a channel, such that an abandoned call
+// shares no variables with the caller.
+func withContext(ctx context.Context, call func() (interface{}, error)) (value interface{}, abandoned bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	type outcome struct {
+		value interface{}
+		err   error
+	}
+	result := make(chan outcome, 1)
+	go func() {
+		value, err := call()
+		result <- outcome{value, err}
+	}()
+	select {
+	case r := <-result:
+		return r.value, false, r.err
+	case <-ctx.Done():
+		return nil, true, ctx.Err()
+	}
+}
+
+// outcomeUnknown is the error of an abandoned change to the named Service,
+// which may yet be applied.
+func outcomeUnknown(change, name string, err error) error {
+	return &DeployError{Kind: ErrOutcomeUnknown, Op: fmt.Sprintf("knative deployer abandoned the %v of service '%v', which may yet be applied", change, name), Err: err}
+}
+
+func (c knServingClient) ListServicesWithLabel(key, value string) (*servingv1.ServiceList, error) {
+	return c.KnServingClient.ListServices(clientservingv1.WithLabel(key, value))
+}
+
+func (c knServingClient) ListRevisionsOfService(serviceName string) (*servingv1.RevisionList, error) {
+	return c.KnServingClient.ListRevisions(clientservingv1.WithService(serviceName))
+}
+
+// WaitForDeletion of the named Service, polling until it is not found.
+func (c knServingClient) WaitForDeletion(name string, timeout time.Duration) error {
+	return waitForDeletion(c.KnServingClient.GetService, name, timeout)
+}
+
+// deletionPollInterval at which a deleted Service is polled until gone.
+var deletionPollInterval = time.Second
+
+// waitForDeletion polls until the named Service is not found, or the timeout
+// elapses, in which case the error reports the Service's last observed
+// status.  Like those of WaitForService, its timeouts are prefixed
+// "timeout:".
+func waitForDeletion(getService func(string) (*servingv1.Service, error), name string, timeout time.Duration) error {
+	var last *servingv1.Service
+	err := k8swait.PollImmediate(deletionPollInterval, timeout, func() (bool, error) {
+		service, err := getService(name)
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		last = service
+		return false, nil
+	})
+	if err == k8swait.ErrWaitTimeout {
+		return fmt.Errorf("timeout: service '%v' not deleted after %v: %v", name, timeout, deletionStatus(last))
+	}
+	return err
+}
+
+// deletionStatus describes the state of a Service pending deletion, such as
+// the finalizers by which it is held.
+func deletionStatus(service *servingv1.Service) string {
+	if service == nil {
+		return "not observed"
+	}
+	if service.DeletionTimestamp == nil {
+		return "not marked for deletion"
+	}
+	status := fmt.Sprintf("marked for deletion at %v", service.DeletionTimestamp.UTC().Format(time.RFC3339))
+	if len(service.Finalizers) > 0 {
+		status += fmt.Sprintf(", awaiting finalizers %v", strings.Join(service.Finalizers, ", "))
+	}
+	return status
+}
+
+// WaitForRevision of the named Service to become ready, polling its Ready
+// condition.
+func (c knServingClient) WaitForRevision(serviceName, revisionName string, timeout time.Duration) error {
+	return waitForRevision(c.GetRevision, serviceName, revisionName, timeout)
+}
+
+// revisionPollInterval at which a revision is polled until ready.
+var revisionPollInterval = time.Second
+
+// waitForRevision polls until the named revision of the Service is ready, such
+// as before shifting traffic to it.  A revision not yet created is awaited.
+// If it fails, or the timeout elapses, the error reports its failure
+// condition, with timeouts prefixed "timeout:" like those of WaitForService.
+func waitForRevision(getRevision func(string) (*servingv1.Revision, error), serviceName, revisionName string, timeout time.Duration) error {
+	var last *servingv1.Revision
+	err := k8swait.PollImmediate(revisionPollInterval, timeout, func() (bool, error) {
+		revision, err := getRevision(revisionName)
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if owner := revision.Labels[serving.ServiceLabelKey]; owner != serviceName {
+			return false, fmt.Errorf("revision '%v' is not of service '%v'", revisionName, serviceName)
+		}
+		last = revision
+		ready := revision.Status.GetCondition(apis.ConditionReady)
+		if ready == nil || revision.Status.ObservedGeneration != revision.Generation {
+			return false, nil
+		}
+		if ready.IsFalse() {
+			return false, fmt.Errorf("revision '%v' failed to become ready: %v", revisionName, revisionStatus(revision))
+		}
+		return ready.IsTrue(), nil
+	})
+	if err == k8swait.ErrWaitTimeout {
+		return fmt.Errorf("timeout: revision '%v' not ready after %v: %v", revisionName, timeout, revisionStatus(last))
+	}
+	return err
+}
+
+// revisionStatus describes why a revision is not ready, being its failure
+// condition.
+func revisionStatus(revision *servingv1.Revision) string {
+	if revision == nil {
+		return "not created"
+	}
+	if reason := conditionFailure(revision.Status.Conditions); reason != "" {
+		return reason
+	}
+	return "no failure reported"
+}
+
+// DeployerOption configures a Deployer at construction.
+type DeployerOption func(*Deployer)
+
+// WithNamespace to which Functions are deployed, overriding that of the
+// selected context.  Empty uses that of the context.
+func WithNamespace(namespace string) DeployerOption {
+	return func(d *Deployer) {
+		d.Namespace = namespace
+	}
+}
+
+// WithVerbose enables verbose logging, both of the Deployer and, unless a
+// BaselineEnv is provided, within deployed Functions.
+func WithVerbose(verbose bool) DeployerOption {
+	return func(d *Deployer) {
+		d.Verbose = verbose
+	}
+}
+
+// WithWaitTimeout sets the maximum time to wait for the deployed Service to
+// become ready.
+func WithWaitTimeout(timeout time.Duration) DeployerOption {
+	return func(d *Deployer) {
+		d.WaitTimeout = timeout
+	}
+}
+
+// WithEventCallback sets the function invoked with the progress of each
+// deployment.
+func WithEventCallback(fn func(Event)) DeployerOption {
+	return func(d *Deployer) {
+		d.OnEvent = fn
+	}
+}
+
+// WithWarnings sets the writer to which the warnings of each deployment are
+// written, in place of os.Stderr.
+func WithWarnings(w io.Writer) DeployerOption {
+	return func(d *Deployer) {
+		d.Warnings = w
+	}
+}
+
+// BaselineEnvFunc returns the environment variables with which the container
+// of the Function is seeded.  Those of the Function take precedence.
+type BaselineEnvFunc func(f faas.Function) []corev1.EnvVar
+
+// WithBaselineEnv sets the function seeding the environment of each
+// Function's container, in place of that setting VERBOSE.
+func WithBaselineEnv(fn BaselineEnvFunc) DeployerOption {
+	return func(d *Deployer) {
+		d.BaselineEnv = fn
+	}
+}
+
+// baselineEnv of the Function's container.
+func (d *Deployer) baselineEnv(f faas.Function) []corev1.EnvVar {
+	if d.BaselineEnv != nil {
+		return d.BaselineEnv(f)
+	}
+	return verboseEnv(d.Verbose)
+}
+
+// verboseEnv is the baseline environment enabling verbose logging within the
+// Function's container when verbose.
+func verboseEnv(verbose bool) []corev1.EnvVar {
+	if !verbose {
+		return nil
+	}
+	return []corev1.EnvVar{{Name: verboseEnvVarName, Value: "true"}}
+}
+
+// WithMessageCallback sets the callback receiving the progress messages
+// reported while waiting for the deployed Service to become ready.
+func WithMessageCallback(fn wait.MessageCallback) DeployerOption {
+	return func(d *Deployer) {
+		d.OnMessage = fn
+	}
+}
+
+// WithMetrics enables annotating revisions for scraping by Prometheus.
+func WithMetrics(enabled bool) DeployerOption {
+	return func(d *Deployer) {
+		d.Metrics = enabled
+	}
+}
+
+// WithForce updates an existing Service even when it is unchanged.
+func WithForce(force bool) DeployerOption {
+	return func(d *Deployer) {
+		d.Force = force
+	}
+}
+
+// WithAdopt updates an existing Service even when it is not labelled as a
+// Function.
+func WithAdopt(adopt bool) DeployerOption {
+	return func(d *Deployer) {
+		d.Adopt = adopt
+	}
+}
+
+// WithSkipPreflight skips verifying that Knative Serving is installed.
+func WithSkipPreflight(skip bool) DeployerOption {
+	return func(d *Deployer) {
+		d.SkipPreflight = skip
+	}
+}
+
+// WithEnsureNamespace creates the target namespace if it does not exist.
+func WithEnsureNamespace(ensure bool) DeployerOption {
+	return func(d *Deployer) {
+		d.EnsureNamespace = ensure
+	}
+}
+
+// WithCreateRetries sets the number of times creating the Service is retried
+// upon a transient error.
+func WithCreateRetries(retries int) DeployerOption {
+	return func(d *Deployer) {
+		d.CreateRetries = retries
+	}
+}
+
+// WithNoWait skips waiting for the deployed Service to become ready.
+func WithNoWait(noWait bool) DeployerOption {
+	return func(d *Deployer) {
+		d.NoWait = noWait
+	}
+}
+
+// WithLabelPrefix overrides the domain of the label identifying deployed
+// Functions.
+func WithLabelPrefix(prefix string) DeployerOption {
+	return func(d *Deployer) {
+		d.LabelPrefix = prefix
+	}
+}
+
+// WithManifestDir writes the manifests of deployed Functions to the given
+// directory rather than applying them to the cluster.
+func WithManifestDir(dir string) DeployerOption {
+	return func(d *Deployer) {
+		d.ManifestDir = dir
+	}
+}
+
+// WithImageResolver sets the resolver of image digests.
+func WithImageResolver(resolver ImageResolver) DeployerOption {
+	return func(d *Deployer) {
+		d.Resolver = resolver
+	}
+}
+
+// WithKubeconfig selects the kubeconfig file and context of the cluster to
+// which to deploy.  Either may be empty to use the default.
+func WithKubeconfig(path, context string) DeployerOption {
+	return func(d *Deployer) {
+		d.Kubeconfig = path
+		d.Context = context
+	}
+}
+
+// WithServingClient sets the client with which Services are deployed, in
+// place of one constructed for the Deployer's Namespace.  Knative Serving is
+// then assumed to be installed.
+func WithServingClient(client ServingClient) DeployerOption {
+	return func(d *Deployer) {
+		d.client = client
+	}
 }
 
-func NewDeployer(namespaceOverride string) (deployer *Deployer, err error) {
+// NewDeployer configured by the given options.  Unless provided by
+// WithNamespace, the namespace is that of the selected context.
+func NewDeployer(options ...DeployerOption) (deployer *Deployer, err error) {
 	deployer = &Deployer{}
-	namespace, err := GetNamespace(namespaceOverride)
+	for _, o := range options {
+		o(deployer)
+	}
+
+	namespace, err := GetNamespace(deployer.Namespace, deployer.clientOptions()...)
 	if err != nil {
 		return
 	}
@@ -35,111 +546,2132 @@ func NewDeployer(namespaceOverride string) (deployer *Deployer, err error) {
 	return
 }
 
-func (d *Deployer) Deploy(f faas.Function) (err error) {
+// Deploy the Function, creating the Service if it does not already exist or
+// updating it otherwise, returning the result, including the URL at which it
+// is available and the revision which became ready.  If a ManifestDir is
+// configured the manifests are instead written to it, and neither URL nor
+// revision is returned.  Nor are they if NoWait is set, and no URL is returned
+// for ClusterLocal Functions, which have no external route.
+func (d *Deployer) Deploy(f faas.Function) (faas.DeploymentResult, error) {
+	return d.DeployContext(context.Background(), f)
+}
+
+// DeployContext deploys the Function as does Deploy, abandoning the deployment
+// once the context is done, such as when canceled or its deadline passes.  The
+// wait for the Service to become ready is also limited to the deadline.  The
+// creation or update of the Service, once requested, can not be withdrawn, so
+// where abandoned it may yet be applied: the error is then of Kind
+// ErrOutcomeUnknown, as well as matching the context's error, and the state of
+// the Service should be checked before deploying again.
+func (d *Deployer) DeployContext(ctx context.Context, f faas.Function) (result faas.DeploymentResult, err error) {
+
+	// Report every problem with the Function itself at once, before those
+	// specific to Knative.
+	if err = f.Validate(); err != nil {
+		err = &DeployError{Kind: ErrServiceInvalid, Err: err}
+		return
+	}
 
 	// k8s does not support service names with dots. so encode it such that
 	// www.my-domain,com -> www-my--domain-com
 	serviceName, err := k8s.ToK8sAllowedName(f.Name)
 	if err != nil {
+		err = &DeployError{Kind: ErrServiceInvalid, Err: err}
 		return
 	}
+	result.Name, result.Namespace = serviceName, d.Namespace
+	defer func() {
+		if err != nil {
+			d.emit(EventFailed, serviceName, err.Error())
+		}
+	}()
 
-	client, err := NewServingClient(d.Namespace)
-	if err != nil {
+	// Validate prior to any interaction with the cluster.
+	if err = d.validate(f); err != nil {
+		err = &DeployError{Kind: ErrServiceInvalid, Err: err}
+		return
+	}
+
+	// The image is pulled through its registry's mirror, if any, from which
+	// any digest to which it is pinned is also resolved.
+	if f.Image, err = d.mirrorImage(f.Image); err != nil {
+		err = &DeployError{Kind: ErrServiceInvalid, Err: err}
+		return
+	}
+
+	// Pin the image to the digest to which it currently refers, such that
+	// the resultant revision is reproducible.
+	if f.PinImageDigest {
+		if f.Image, err = d.resolveImage(f.Image); err != nil {
+			return
+		}
+	}
+
+	if d.ManifestDir != "" {
+		err = d.writeManifests(f)
 		return
 	}
 
-	_, err = client.GetService(serviceName)
+	if d.EnsureNamespace {
+		if err = d.ensureNamespace(); err != nil {
+			return
+		}
+	}
+
+	client, err := d.servingClient()
 	if err != nil {
-		if errors.IsNotFound(err) {
+		return
+	}
 
-			// Let's create a new Service
-			err := client.CreateService(generateNewService(serviceName, f.Image))
-			if err != nil {
-				err = fmt.Errorf("knative deployer failed to deploy the service: %v", err)
-				return err
-			}
+	if err = d.checkFeatures(f); err != nil {
+		return
+	}
 
-			err, _ = client.WaitForService(serviceName, DefaultWaitingTimeout, wait.NoopMessageCallback())
-			if err != nil {
-				err = fmt.Errorf("knative deployer failed to wait for the service to become ready: %v", err)
-				return err
-			}
+	// The routing of Functions with a route path or max body size depends on
+	// the ingress through which they are exposed.
+	var ingressClass string
+	if f.RoutePath != "" || f.MaxBodySize != "" {
+		ingressClass = d.ingressClass(f)
+	}
+	if err = d.checkRoutePath(serviceName, f, ingressClass); err != nil {
+		return
+	}
+	if err = d.checkMaxBodySize(serviceName, f, ingressClass); err != nil {
+		return
+	}
 
-			route, err := client.GetRoute(serviceName)
-			if err != nil {
-				err = fmt.Errorf("knative deployer failed to get the route: %v", err)
-				return err
-			}
+	// Ingress classes other than those known may be provided by third party
+	// networking layers, so are passed through with a warning.
+	if f.IngressClass != "" && !knownIngressClasses[f.IngressClass] {
+		d.warn(serviceName, "unrecognized ingress class '%v'", f.IngressClass)
+	}
 
-			fmt.Println("Function deployed on: " + route.Status.URL.String())
+	// As are autoscaler classes other than those known.
+	if f.ScaleClass != "" && !knownScaleClasses[f.ScaleClass] {
+		d.warn(serviceName, "unrecognized autoscaler class '%v'", f.ScaleClass)
+	}
 
-		} else {
-			err = fmt.Errorf("knative deployer failed to get the service: %v", err)
-			return err
+	// An image which is not pulled is likely not in a reachable registry, in
+	// which case Knative can not resolve its tag.
+	if f.ImagePullPolicy == string(corev1.PullNever) && !skipsTagResolution(f.Image) {
+		d.warn(serviceName, "image '%v' is not pulled, but its tag is resolved against its registry by Knative unless it is referenced by digest", f.Image)
+	}
+
+	// Referenced Secrets and ConfigMaps may be created after the Function is
+	// deployed, so those missing are reported as warnings only.
+	if hasEnvVarSources(f.EnvVars) {
+		coreClient, err := d.kubernetesClient()
+		if err != nil {
+			return result, err
 		}
-	} else {
-		// Update the existing Service
-		err = client.UpdateServiceWithRetry(serviceName, updateEnvVars(f.EnvVars), 3)
+		for _, warning := range missingEnvVarSources(coreClient, d.Namespace, f.EnvVars) {
+			d.warn(serviceName, "%v", warning)
+		}
+	}
+
+	// The Brokers to which the Function subscribes must exist.
+	if len(f.Subscriptions) > 0 {
+		eventing, err := d.eventingClient()
 		if err != nil {
-			err = fmt.Errorf("knative deployer failed to update the service: %v", err)
-			return err
+			return result, err
+		}
+		if err = validateBrokers(eventing, f.Subscriptions); err != nil {
+			return result, err
 		}
 	}
 
-	return nil
-}
+	// The Secret with which the domain is served over TLS must exist, the
+	// DomainMapping otherwise never becoming ready.
+	if f.DomainTLSSecret != "" {
+		coreClient, err := d.kubernetesClient()
+		if err != nil {
+			return result, err
+		}
+		if err = checkDomainTLSSecret(coreClient, d.Namespace, f.DomainTLSSecret); err != nil {
+			return result, err
+		}
+	}
 
-func generateNewService(name, image string) *servingv1.Service {
-	containers := []corev1.Container{
-		{
-			Image: image,
-			Env: []corev1.EnvVar{
-				{Name: "VERBOSE", Value: "true"},
-			},
-		},
+	// The sink, if any, is resolved to the URL to which the Function sends
+	// the events it emits.
+	sinkURL := ""
+	if f.Sink != "" {
+		if sinkURL, err = d.resolveSink(ctx, client, f.Sink); err != nil {
+			return
+		}
 	}
 
-	return &v1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
-			Labels: map[string]string{
-				"bosonFunction": "true",
-			},
-		},
-		Spec: v1.ServiceSpec{
-			ConfigurationSpec: v1.ConfigurationSpec{
-				Template: v1.RevisionTemplateSpec{
-					Spec: v1.RevisionSpec{
-						PodSpec: corev1.PodSpec{
-							Containers: containers,
-						},
-					},
-				},
-			},
-		},
+	if err = d.runHook(ctx, "pre-deploy", d.PreDeploy, f, result); err != nil {
+		return
 	}
-}
 
-func updateEnvVars(envVars map[string]string) func(service *servingv1.Service) (*servingv1.Service, error) {
-	return func(service *servingv1.Service) (*servingv1.Service, error) {
-		builtEnvVarName := "BUILT"
-		builtEnvVarValue := time.Now().Format("20060102T150405")
+	create := false
+	existing, err := client.GetService(ctx, serviceName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			err = newDeployError("knative deployer failed to get the service", err)
+			return
+		}
+		create = true
+	}
+
+	if create {
+		// Let's create a new Service
+		service, err := d.renderService(f)
+		if err != nil {
+			return result, err
+		}
+		if err = updateSink(&service.Spec.Template, f.Sink, sinkURL); err != nil {
+			return result, err
+		}
+		if err = updateRoutePath(service, ingressClass, f); err != nil {
+			return result, err
+		}
+		if err = updateMaxBodySize(service, ingressClass, f); err != nil {
+			return result, err
+		}
 
-		toUpdate := make(map[string]string, len(envVars)+1)
-		toRemove := make([]string, 0)
+		d.emit(EventCreating, serviceName, "")
+		err = d.createService(ctx, client, service)
+		if errors.IsAlreadyExists(err) {
+			// Created concurrently since found to be absent, so update it.
+			create = false
+		} else if err != nil {
+			err = explainRejection(f, newDeployError("knative deployer failed to deploy the service", err))
+			return result, err
+		}
+	}
 
-		for name, value := range envVars {
-			if strings.HasSuffix(name, "-") {
-				toRemove = append(toRemove, strings.TrimSuffix(name, "-"))
+	if !create {
+		if existing != nil {
+			if err = d.checkOwnership(existing); err != nil {
+				return
+			}
+		}
+		// Update the existing Service
+		update := updateService(f, d.baselineEnv(f))
+		apply := func(service *servingv1.Service) (*servingv1.Service, error) {
+			// Ownership is verified anew of the Service as updated, it having
+			// been created concurrently or since replaced.
+			if err := d.checkOwnership(service); err != nil {
+				return nil, err
+			}
+			service, err := update(service)
+			if err != nil {
+				return nil, &DeployError{Kind: ErrServiceInvalid, Err: err}
+			}
+			setFunctionLabel(service, functionLabelKey(d.LabelPrefix))
+			if d.Metrics {
+				updateMetricsAnnotations(&service.Spec.Template, f)
 			} else {
-				toUpdate[name] = value
+				removeMetricsAnnotations(&service.Spec.Template, f)
+			}
+			if err := updateSink(&service.Spec.Template, f.Sink, sinkURL); err != nil {
+				return nil, err
+			}
+			if err := updateRoutePath(service, ingressClass, f); err != nil {
+				return nil, err
+			}
+			if err := updateMaxBodySize(service, ingressClass, f); err != nil {
+				return nil, err
 			}
+			return service, nil
+		}
+		// existing is nil where created concurrently, so is always updated.
+		if !d.Force && existing != nil && unchanged(existing, apply) {
+			d.emit(EventUnchanged, serviceName, "")
+		} else {
+			d.emit(EventUpdating, serviceName, "")
+			if err = client.UpdateServiceWithRetry(ctx, serviceName, apply, 3); err != nil {
+				err = explainRejection(f, newDeployError("knative deployer failed to update the service", err))
+				return
+			}
+		}
+	}
+
+	if err = d.reconcileTriggers(serviceName, f.Subscriptions); err != nil {
+		return
+	}
+	if err = d.reconcileSchedule(serviceName, f.Schedule); err != nil {
+		return
+	}
+	if err = d.reconcileDomainMapping(serviceName, f.Domain, f.DomainTLSSecret); err != nil {
+		return
+	}
+
+	// The post-deploy hook is invoked with the result of a deployment which
+	// succeeds, however it returns.
+	defer func() {
+		if err == nil {
+			err = d.runHook(ctx, "post-deploy", d.PostDeploy, f, result)
 		}
+	}()
 
-		toUpdate[builtEnvVarName] = builtEnvVarValue
+	if d.NoWait {
+		return
+	}
 
-		return service, servinglib.UpdateEnvVars(&service.Spec.Template, toUpdate, toRemove)
+	d.emit(EventWaiting, serviceName, "")
+	// The wait is abandoned once the context is done, including when it is
+	// canceled rather than its deadline passing.
+	_, _, err = withContext(ctx, func() (interface{}, error) {
+		err, _ := client.WaitForService(serviceName, d.deployTimeout(ctx, f), d.messageCallback())
+		return nil, err
+	})
+	if err != nil {
+		// Diagnostics are gathered only on failure, and are best effort.
+		// They are gathered within a context of their own, that of the
+		// deployment possibly having passed its deadline, but not once the
+		// deployment is canceled.
+		if ctx.Err() != context.Canceled {
+			diagnosticsCtx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+			if reason := d.readinessFailure(diagnosticsCtx, client, serviceName); reason != "" {
+				err = fmt.Errorf("%w: %v", err, reason)
+			}
+			cancel()
+		}
+		err = waitError(err)
+		return
 	}
+	d.emit(EventReady, serviceName, "")
 
+	// The Service being ready, its latest ready revision is that created by
+	// this deployment, or the revision already current if it was unchanged.
+	service, err := client.GetService(ctx, serviceName)
+	if err != nil {
+		err = newDeployError("knative deployer failed to get the deployed service", err)
+		return
+	}
+	result.Revision = service.Status.LatestReadyRevisionName
+
+	if f.ClusterLocal {
+		return
+	}
+
+	route, err := client.GetRoute(ctx, serviceName)
+	if err != nil {
+		err = newDeployError("knative deployer failed to get the route", err)
+		return
+	}
+	result.URL = route.Status.URL.String()
+	return
+}
+
+// reconcileTriggers of the Function's Subscriptions.  A Function without
+// Subscriptions need not have Eventing available, in which case there are no
+// Triggers to remove.
+func (d *Deployer) reconcileTriggers(serviceName string, subscriptions []faas.Subscription) error {
+	eventing, err := d.eventingClient()
+	if err != nil {
+		if len(subscriptions) == 0 {
+			return nil
+		}
+		return err
+	}
+	return reconcileTriggers(eventing, d.Namespace, serviceName, subscriptions)
+}
+
+// reconcileSchedule of the Function.  A Function without a schedule need not
+// have Eventing available, in which case there is no PingSource to remove.
+func (d *Deployer) reconcileSchedule(serviceName, schedule string) error {
+	client, err := d.pingSourceClient()
+	if err != nil {
+		if schedule == "" {
+			return nil
+		}
+		return err
+	}
+	return reconcileSchedule(client, d.Namespace, serviceName, schedule)
+}
+
+// reconcileDomainMapping of the Function.  A Function without a domain need
+// not have DomainMappings available, in which case there are none to remove.
+func (d *Deployer) reconcileDomainMapping(serviceName, domain, tlsSecret string) error {
+	client, err := d.domainMappingClient()
+	if err != nil {
+		if domain == "" {
+			return nil
+		}
+		return err
+	}
+	return reconcileDomainMapping(client, d.Namespace, serviceName, domain, tlsSecret)
+}
+
+// domainMappingClient returns the client to use for the Deployer's
+// namespace.
+func (d *Deployer) domainMappingClient() (domainMappingClient, error) {
+	if d.domainMappings != nil {
+		return d.domainMappings, nil
+	}
+	return newDomainMappingClient(d.Namespace, d.clientOptions()...)
+}
+
+// pingSourceClient returns the client to use for the Deployer's namespace.
+func (d *Deployer) pingSourceClient() (pingSourceClient, error) {
+	if d.pingSources != nil {
+		return d.pingSources, nil
+	}
+	return newPingSourceClient(d.Namespace, d.clientOptions()...)
+}
+
+// eventingClient returns the client to use for the Deployer's namespace.
+func (d *Deployer) eventingClient() (eventingClient, error) {
+	if d.eventing != nil {
+		return d.eventing, nil
+	}
+	return newEventingClient(d.Namespace, d.clientOptions()...)
+}
+
+// messageCallback returns the callback of progress messages, if any.
+func (d *Deployer) messageCallback() wait.MessageCallback {
+	if d.OnMessage == nil {
+		return wait.NoopMessageCallback()
+	}
+	return d.OnMessage
+}
+
+// ensureNamespace creates the Deployer's Namespace if it does not exist.
+func (d *Deployer) ensureNamespace() error {
+	client, err := d.kubernetesClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Namespaces().Get(d.Namespace, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return newDeployError("knative deployer failed to get the namespace", err)
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: d.Namespace}}
+	_, err = client.CoreV1().Namespaces().Create(namespace)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return newDeployError("knative deployer failed to create the namespace", err)
+	}
+	return nil
+}
+
+// createService creates the Service, retrying with exponential backoff upon
+// errors which are likely transient.  Errors such as an invalid spec are
+// returned immediately.
+func (d *Deployer) createService(ctx context.Context, client ServingClient, service *servingv1.Service) error {
+	backoff := createBackoff
+	backoff.Steps = d.createRetries() + 1
+	return retry.OnError(backoff, retryable, func() error {
+		return client.CreateService(ctx, service)
+	})
+}
+
+func (d *Deployer) createRetries() int {
+	if d.CreateRetries == 0 {
+		return DefaultCreateRetries
+	}
+	return d.CreateRetries
+}
+
+// retryable errors are those which are likely to succeed if retried.
+func retryable(err error) bool {
+	return errors.IsConflict(err) ||
+		errors.IsServerTimeout(err) ||
+		errors.IsTimeout(err) ||
+		errors.IsTooManyRequests(err) ||
+		errors.IsServiceUnavailable(err) ||
+		errors.IsInternalError(err)
+}
+
+// Undeploy the named Function, deleting its Service and waiting for the
+// deletion to complete.  A Function which is not deployed is not an error;
+// removed reports whether there was a Service to delete.
+func (d *Deployer) Undeploy(name string) (removed bool, err error) {
+	serviceName, err := k8s.ToK8sAllowedName(name)
+	if err != nil {
+		return
+	}
+
+	client, err := d.servingClient()
+	if err != nil {
+		return
+	}
+
+	// The Triggers and PingSource invoking the Function, and the
+	// DomainMapping of its domain, are removed first, as they refer to the
+	// Service.
+	if err = d.reconcileTriggers(serviceName, nil); err != nil {
+		return
+	}
+	if err = d.reconcileSchedule(serviceName, ""); err != nil {
+		return
+	}
+	if err = d.reconcileDomainMapping(serviceName, "", ""); err != nil {
+		return
+	}
+
+	err = client.DeleteService(serviceName, 0)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		err = newDeployError("knative deployer failed to delete the service", err)
+		return
+	}
+
+	// Deletion completes only once the Service is gone, such that callers
+	// may rely on its absence.
+	if err = client.WaitForDeletion(serviceName, d.waitTimeout()); err != nil {
+		e := newDeployError("knative deployer failed to wait for the service to be deleted", err)
+		if strings.HasPrefix(err.Error(), "timeout:") {
+			e.Kind = ErrDeployTimeout
+		}
+		err = e
+		return
+	}
+	return true, nil
+}
+
+// validate the image reference, resource quantities and other configuration
+// of the Function specific to Knative.  That independent of the platform, such
+// as its scaling bounds and env var references, is validated by the
+// Function's Validate, which is run first.
+func (d *Deployer) validate(f faas.Function) error {
+	if err := validateImage(f.Image); err != nil {
+		return err
+	}
+	serviceName, err := k8s.ToK8sAllowedName(f.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := revisionName(serviceName, f.RevisionName); err != nil {
+		return err
+	}
+	if errs := validation.IsQualifiedName(functionLabelKey(d.LabelPrefix)); len(errs) > 0 {
+		return fmt.Errorf("invalid label prefix '%v': %v", d.LabelPrefix, strings.Join(errs, ","))
+	}
+	if _, err := resourceRequirements(f.Resources); err != nil {
+		return err
+	}
+	if err := validateScaleDurations(f.ScaleDownDelay, f.ScaleWindow); err != nil {
+		return err
+	}
+	metric, _ := scaleMetric(f)
+	if err := validateScaleMetric(f.ScaleClass, metric); err != nil {
+		return err
+	}
+	if err := validateScaleProfile(f.ScaleProfile, f.ScaleClass); err != nil {
+		return err
+	}
+	if err := validateTimeout(f.Timeout); err != nil {
+		return err
+	}
+	if err := validateImagePullPolicy(f.ImagePullPolicy); err != nil {
+		return err
+	}
+	if _, err := envFromSources(f.EnvFrom); err != nil {
+		return err
+	}
+	for _, m := range [][2]map[string]string{
+		{f.Labels, f.Annotations},
+		{f.ServiceLabels, f.ServiceAnnotations},
+		{f.TemplateLabels, f.TemplateAnnotations},
+	} {
+		if err := validateMetadata(m[0], m[1]); err != nil {
+			return err
+		}
+	}
+	if err := validateMetricsPath(f.MetricsPath); err != nil {
+		return err
+	}
+	if errs := validation.IsValidLabelValue(f.Runtime); len(errs) > 0 {
+		return fmt.Errorf("invalid runtime '%v': %v", f.Runtime, strings.Join(errs, ","))
+	}
+	if err := validateTraffic(f.Traffic); err != nil {
+		return err
+	}
+	if _, _, err := volumes(f.Volumes); err != nil {
+		return err
+	}
+	if _, err := initContainers(f.InitContainers, f.Volumes); err != nil {
+		return err
+	}
+	if _, err := sidecarContainers(f.Port, f.Sidecars); err != nil {
+		return err
+	}
+	if err := validateProtocol(f.Protocol); err != nil {
+		return err
+	}
+	if err := validateScheduling(f); err != nil {
+		return err
+	}
+	if _, err := securityContext(f.SecurityContext); err != nil {
+		return err
+	}
+	if err := validateRoutePath(f.RoutePath); err != nil {
+		return err
+	}
+	if _, err := maxBodySize(f.MaxBodySize); err != nil {
+		return err
+	}
+	if err := validateFeatures(f.Features); err != nil {
+		return err
+	}
+	if err := validateWorkingDir(f.WorkingDir); err != nil {
+		return err
+	}
+	if err := validateSubscriptions(f.Subscriptions); err != nil {
+		return err
+	}
+	if err := validateSchedule(f.Schedule); err != nil {
+		return err
+	}
+	if err := validateSink(f.Sink); err != nil {
+		return err
+	}
+	if err := validateDomain(f.Domain, f.DomainTLSSecret); err != nil {
+		return err
+	}
+	return nil
+}
+
+// servingClient returns the client to use for the Deployer's namespace,
+// verifying first that Knative Serving is installed unless SkipPreflight.
+func (d *Deployer) servingClient() (ServingClient, error) {
+	if d.client != nil {
+		return d.client, nil
+	}
+	if !d.SkipPreflight {
+		coreClient, err := d.kubernetesClient()
+		if err != nil {
+			return nil, err
+		}
+		if err = CheckServing(coreClient.Discovery()); err != nil {
+			return nil, err
+		}
+	}
+	client, err := NewServingClient(d.Namespace, d.clientOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	return knServingClient{client}, nil
+}
+
+// clientOptions for connecting to the Deployer's cluster.
+func (d *Deployer) clientOptions() []ClientOption {
+	return []ClientOption{WithKubeconfigPath(d.Kubeconfig), WithKubeContext(d.Context)}
+}
+
+// kubernetesClient returns the client to use for core resources.
+func (d *Deployer) kubernetesClient() (kubernetes.Interface, error) {
+	if d.coreClient != nil {
+		return d.coreClient, nil
+	}
+	return NewKubernetesClient(d.clientOptions()...)
+}
+
+// waitTimeout returns the configured timeout, or the default if not set.
+func (d *Deployer) waitTimeout() time.Duration {
+	if d.WaitTimeout == 0 {
+		return DefaultWaitingTimeout
+	}
+	return d.WaitTimeout
+}
+
+// deployTimeout is the time to wait for the Service of the Function to become
+// ready: the waitTimeout, extended where the Function's progress deadline is
+// longer, such that Knative's verdict on the revision is awaited rather than
+// the Deployer giving up first.  It is limited to the context's deadline.
+func (d *Deployer) deployTimeout(ctx context.Context, f faas.Function) time.Duration {
+	timeout := d.waitTimeout()
+	if deadline, err := time.ParseDuration(f.ProgressDeadline); err == nil && deadline > 0 {
+		if extended := deadline + progressDeadlineMargin; extended > timeout {
+			timeout = extended
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			return remaining
+		}
+	}
+	return timeout
+}
+
+// validateImage ensures the image is a well formed reference, such as
+// registry/repository:tag or registry/repository@sha256:digest.
+func validateImage(image string) error {
+	if image == "" {
+		return fmt.Errorf("image is required")
+	}
+	if _, err := name.ParseReference(image); err != nil {
+		return fmt.Errorf("invalid image reference '%v': %v", image, err)
+	}
+	return nil
+}
+
+func generateNewService(name string, f faas.Function, baseline []corev1.EnvVar) (*servingv1.Service, error) {
+	containers := []corev1.Container{
+		{
+			Image: f.Image,
+		},
+	}
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				labelKey: labelValue,
+			},
+		},
+		Spec: v1.ServiceSpec{
+			ConfigurationSpec: v1.ConfigurationSpec{
+				Template: v1.RevisionTemplateSpec{
+					Spec: v1.RevisionSpec{
+						PodSpec: corev1.PodSpec{
+							Containers: containers,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := updateTemplate(&service.Spec.Template, f); err != nil {
+		return nil, err
+	}
+	if err := updateRevisionName(&service.Spec.Template, name, f.RevisionName); err != nil {
+		return nil, err
+	}
+
+	toUpdate, toRemove := envVarChanges(f.EnvVars)
+	if err := updateEnv(&service.Spec.Template, toUpdate, toRemove); err != nil {
+		return nil, err
+	}
+
+	updateMetadata(service, f)
+	if err := updateFeatures(service, f); err != nil {
+		return nil, err
+	}
+	if err := updateBaselineEnv(service, baseline, f.EnvVars); err != nil {
+		return nil, err
+	}
+	updateRuntimeLabel(service, f.Runtime)
+	updateIngressClass(service, f.IngressClass)
+	updateVisibility(service, f.ClusterLocal)
+	if err := updateRoutePath(service, f.IngressClass, f); err != nil {
+		return nil, err
+	}
+	if err := updateMaxBodySize(service, f.IngressClass, f); err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// updateService returns a function which applies the configurable aspects of
+// the Function to an existing Service, its container seeded with the baseline
+// environment.
+func updateService(f faas.Function, baseline []corev1.EnvVar) func(service *servingv1.Service) (*servingv1.Service, error) {
+	return func(service *servingv1.Service) (*servingv1.Service, error) {
+		if err := updateTemplate(&service.Spec.Template, f); err != nil {
+			return service, err
+		}
+		if err := updateRevisionName(&service.Spec.Template, service.Name, f.RevisionName); err != nil {
+			return service, err
+		}
+		updateMetadata(service, f)
+		if err := updateFeatures(service, f); err != nil {
+			return service, err
+		}
+		updateRuntimeLabel(service, f.Runtime)
+		updateIngressClass(service, f.IngressClass)
+		updateVisibility(service, f.ClusterLocal)
+		if err := updateRoutePath(service, f.IngressClass, f); err != nil {
+			return service, err
+		}
+		if err := updateMaxBodySize(service, f.IngressClass, f); err != nil {
+			return service, err
+		}
+		if err := updateTraffic(service, f.Traffic); err != nil {
+			return service, err
+		}
+		if err := updateBaselineEnv(service, baseline, f.EnvVars); err != nil {
+			return service, err
+		}
+		return updateEnvVars(f.EnvVars)(service)
+	}
+}
+
+// unchanged reports whether updating the Service would leave both its spec and
+// metadata as they are, disregarding the built annotation which each update
+// refreshes.  Both are defaulted as by Knative, such that fields defaulted on
+// the live Service are not mistaken for changes.  Should the update fail it is
+// considered a change, such that the failure is reported by the update itself.
+func unchanged(service *servingv1.Service, update func(*servingv1.Service) (*servingv1.Service, error)) bool {
+	live := service.DeepCopy()
+	updated, err := update(service.DeepCopy())
+	if err != nil {
+		return false
+	}
+	setAnnotation(&updated.Spec.Template, builtAnnotation, live.Spec.Template.Annotations[builtAnnotation])
+
+	ctx := context.Background()
+	live.Spec.SetDefaults(ctx)
+	updated.Spec.SetDefaults(ctx)
+	return equality.Semantic.DeepEqual(live.Spec, updated.Spec) &&
+		equality.Semantic.DeepEqual(live.Labels, updated.Labels) &&
+		equality.Semantic.DeepEqual(live.Annotations, updated.Annotations)
+}
+
+// updateTemplate applies the configurable aspects of the Function to the
+// revision template.  Used both when generating a new Service and when
+// updating an existing one, such that redeploys converge on the same spec.
+func updateTemplate(template *servingv1.RevisionTemplateSpec, f faas.Function) (err error) {
+	if err = servinglib.UpdateImage(template, f.Image); err != nil {
+		return
+	}
+	if err = updateScale(template, f.MinScale, f.MaxScale); err != nil {
+		return
+	}
+	updateInitialScale(template, f.InitialScale)
+	if err = updateScaleDurations(template, f.ScaleDownDelay, f.ScaleWindow); err != nil {
+		return
+	}
+	updateScaleClass(template, f.ScaleClass)
+	metric, target := scaleMetric(f)
+	if err = updateScaleMetric(template, f.ScaleClass, metric, target); err != nil {
+		return
+	}
+	updateTargetUtilization(template, f.TargetUtilization)
+	if err = updateScaleProfile(template, f.ScaleProfile, f.ScaleClass, metric); err != nil {
+		return
+	}
+	if err = servinglib.UpdateConcurrencyLimit(template, f.Concurrency); err != nil {
+		return
+	}
+	if err = updateTimeout(template, f.Timeout); err != nil {
+		return
+	}
+	setAnnotation(template, progressDeadlineAnnotationKey, f.ProgressDeadline)
+	updateTerminationGracePeriod(template, f.TerminationGracePeriod)
+	if err = updateResources(template, f.Resources); err != nil {
+		return
+	}
+	if err = updateEnvFrom(template, f.EnvFrom); err != nil {
+		return
+	}
+	if err = updatePort(template, f.Port); err != nil {
+		return
+	}
+	if err = updateCommand(template, f.Command, f.Args); err != nil {
+		return
+	}
+	if err = updateWorkingDir(template, f.WorkingDir); err != nil {
+		return
+	}
+	if err = updateImagePullPolicy(template, f.ImagePullPolicy); err != nil {
+		return
+	}
+	if err = updateSecurityContext(template, f.SecurityContext); err != nil {
+		return
+	}
+	if err = updateProbes(template, f.LivenessProbe, f.ReadinessProbe); err != nil {
+		return
+	}
+	if err = updateVolumes(template, f.Volumes); err != nil {
+		return
+	}
+	if err = servinglib.UpdateServiceAccountName(template, f.ServiceAccountName); err != nil {
+		return
+	}
+	if err = updateInitContainers(template, f.InitContainers, f.Volumes); err != nil {
+		return
+	}
+	if err = updateSidecars(template, f.Port, f.Sidecars); err != nil {
+		return
+	}
+	if err = updateProtocol(template, f.Protocol); err != nil {
+		return
+	}
+	if err = updateScheduling(template, f); err != nil {
+		return
+	}
+	updateImagePullSecrets(template, f.ImagePullSecrets)
+	return
+}
+
+// updateRevisionName sets the name of the revision to be created, or clears
+// it such that one is generated.
+func updateRevisionName(template *servingv1.RevisionTemplateSpec, serviceName, name string) error {
+	name, err := revisionName(serviceName, name)
+	if err != nil {
+		return err
+	}
+	template.Name = name
+	return nil
+}
+
+// revisionName returns the name of the revision prefixed with the name of
+// the Service, as required by Knative, validating the result.
+func revisionName(serviceName, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(name, serviceName+"-") {
+		name = serviceName + "-" + name
+	}
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid revision name '%v': %v", name, strings.Join(errs, ","))
+	}
+	return name, nil
+}
+
+// updateImagePullSecrets sets the pod's image pull secrets to exactly those
+// named.
+func updateImagePullSecrets(template *servingv1.RevisionTemplateSpec, names []string) {
+	var secrets []corev1.LocalObjectReference
+	for _, name := range names {
+		secrets = append(secrets, corev1.LocalObjectReference{Name: name})
+	}
+	template.Spec.ImagePullSecrets = secrets
+}
+
+// updateVolumes sets the pod's volumes and the container's mounts to exactly
+// those of the Function, such that removed volumes are reconciled.
+func updateVolumes(template *servingv1.RevisionTemplateSpec, vv []faas.Volume) error {
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	podVolumes, mounts, err := volumes(vv)
+	if err != nil {
+		return err
+	}
+	template.Spec.Volumes = podVolumes
+	container.VolumeMounts = mounts
+	return nil
+}
+
+// volumes converts the Function's volumes to pod volumes and their associated
+// container mounts.  Only Secret, ConfigMap and emptyDir sources are
+// supported, these being the volume types permitted by Knative.
+func volumes(vv []faas.Volume) (podVolumes []corev1.Volume, mounts []corev1.VolumeMount, err error) {
+	paths := map[string]bool{}
+	for _, v := range vv {
+		if !strings.HasPrefix(v.Path, "/") {
+			return nil, nil, fmt.Errorf("invalid volume path '%v', must be absolute", v.Path)
+		}
+		if paths[v.Path] {
+			return nil, nil, fmt.Errorf("multiple volumes are mounted at path '%v'", v.Path)
+		}
+		paths[v.Path] = true
+
+		if v.Source == emptyDirSource {
+			volume, err := emptyDirVolume(v)
+			if err != nil {
+				return nil, nil, err
+			}
+			podVolumes = append(podVolumes, volume)
+			mounts = append(mounts, corev1.VolumeMount{Name: volume.Name, MountPath: v.Path})
+			continue
+		}
+		if v.Size != "" {
+			return nil, nil, fmt.Errorf("volume at path '%v' has a size, but only emptyDir volumes may", v.Path)
+		}
+
+		tokens := strings.SplitN(v.Source, ":", 2)
+		if len(tokens) != 2 || tokens[1] == "" {
+			return nil, nil, fmt.Errorf("invalid volume source '%v', expected 'secret:name', 'configMap:name' or 'emptyDir'", v.Source)
+		}
+
+		volume := corev1.Volume{Name: servinglib.GenerateVolumeName(v.Path)}
+		switch tokens[0] {
+		case "secret":
+			volume.Secret = &corev1.SecretVolumeSource{SecretName: tokens[1]}
+		case "configMap":
+			volume.ConfigMap = &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: tokens[1]}}
+		default:
+			return nil, nil, fmt.Errorf("unsupported volume source type '%v', only 'secret', 'configMap' and 'emptyDir' are supported", tokens[0])
+		}
+
+		podVolumes = append(podVolumes, volume)
+		mounts = append(mounts, corev1.VolumeMount{Name: volume.Name, MountPath: v.Path, ReadOnly: true})
+	}
+	return
+}
+
+// emptyDirSource of a volume of scratch space.
+const emptyDirSource = "emptyDir"
+
+// emptyDirVolume of the size, if any, of the Function's volume, which is
+// writable.
+func emptyDirVolume(v faas.Volume) (corev1.Volume, error) {
+	source := &corev1.EmptyDirVolumeSource{}
+	if v.Size != "" {
+		size, err := resource.ParseQuantity(v.Size)
+		if err != nil {
+			return corev1.Volume{}, fmt.Errorf("invalid size '%v' of the volume at path '%v': %v", v.Size, v.Path, err)
+		}
+		if size.Sign() <= 0 {
+			return corev1.Volume{}, fmt.Errorf("invalid size '%v' of the volume at path '%v': must be greater than zero", v.Size, v.Path)
+		}
+		source.SizeLimit = &size
+	}
+	return corev1.Volume{
+		Name:         servinglib.GenerateVolumeName(v.Path),
+		VolumeSource: corev1.VolumeSource{EmptyDir: source},
+	}, nil
+}
+
+// updateInitContainers sets the init containers of the revision template to
+// exactly those of the Function.
+func updateInitContainers(template *servingv1.RevisionTemplateSpec, cc []faas.InitContainer, vv []faas.Volume) error {
+	containers, err := initContainers(cc, vv)
+	if err != nil {
+		return err
+	}
+	template.Spec.InitContainers = containers
+	return nil
+}
+
+// initContainers converts the Function's init containers to their Kubernetes
+// equivalents, mounting those of the Function's volumes referenced by path.
+func initContainers(cc []faas.InitContainer, vv []faas.Volume) (containers []corev1.Container, err error) {
+	_, mounts, err := volumes(vv)
+	if err != nil {
+		return
+	}
+	mountsByPath := map[string]corev1.VolumeMount{}
+	for _, m := range mounts {
+		mountsByPath[m.MountPath] = m
+	}
+
+	names := map[string]bool{}
+	for i, c := range cc {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("init-%v", i)
+		}
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid init container name '%v': %v", name, strings.Join(errs, ","))
+		}
+		if names[name] {
+			return nil, fmt.Errorf("multiple init containers are named '%v'", name)
+		}
+		names[name] = true
+		if err = validateImage(c.Image); err != nil {
+			return nil, fmt.Errorf("init container '%v': %v", name, err)
+		}
+
+		container := corev1.Container{Name: name, Image: c.Image, Command: c.Command}
+		for _, path := range c.Volumes {
+			mount, ok := mountsByPath[path]
+			if !ok {
+				return nil, fmt.Errorf("init container '%v' mounts '%v', which is not a volume of the function", name, path)
+			}
+			container.VolumeMounts = append(container.VolumeMounts, mount)
+		}
+		if container.Env, err = containerEnv(c.EnvVars); err != nil {
+			return nil, fmt.Errorf("init container '%v': %v", name, err)
+		}
+		containers = append(containers, container)
+	}
+	return
+}
+
+// containerEnv converts env vars, which may refer to Secrets and ConfigMaps,
+// to those of a container, sorted by name.
+func containerEnv(envVars map[string]string) (env []corev1.EnvVar, err error) {
+	for name, value := range envVars {
+		source, err := envVarSource(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for env var '%v': %v", name, err)
+		}
+		if source != nil {
+			env = append(env, corev1.EnvVar{Name: name, ValueFrom: source})
+		} else {
+			env = append(env, corev1.EnvVar{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(env, func(i, j int) bool {
+		return env[i].Name < env[j].Name
+	})
+	return
+}
+
+// updateSidecars sets the containers of the revision template following that
+// of the Function to exactly its sidecars.
+func updateSidecars(template *servingv1.RevisionTemplateSpec, port int32, sidecars []faas.Sidecar) error {
+	if _, err := servinglib.ContainerOfRevisionTemplate(template); err != nil {
+		return err
+	}
+	containers, err := sidecarContainers(port, sidecars)
+	if err != nil {
+		return err
+	}
+	template.Spec.Containers = append(template.Spec.Containers[:1], containers...)
+	return nil
+}
+
+// sidecarContainers converts the Function's sidecars to their Kubernetes
+// equivalents, ensuring that when there are sidecars exactly one container,
+// the Function's or a sidecar's, declares the port on which requests are
+// served, as Knative requires.
+func sidecarContainers(port int32, sidecars []faas.Sidecar) (containers []corev1.Container, err error) {
+	if len(sidecars) == 0 {
+		return
+	}
+	serving := []string{}
+	if port != 0 {
+		serving = append(serving, "function")
+	}
+
+	names := map[string]bool{}
+	for i, c := range sidecars {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("sidecar-%v", i)
+		}
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid sidecar name '%v': %v", name, strings.Join(errs, ","))
+		}
+		if names[name] {
+			return nil, fmt.Errorf("multiple sidecars are named '%v'", name)
+		}
+		names[name] = true
+		if err = validateImage(c.Image); err != nil {
+			return nil, fmt.Errorf("sidecar '%v': %v", name, err)
+		}
+
+		container := corev1.Container{Name: name, Image: c.Image}
+		if c.Port != 0 {
+			if errs := validation.IsValidPortNum(int(c.Port)); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid port %v of sidecar '%v': %v", c.Port, name, strings.Join(errs, ","))
+			}
+			container.Ports = []corev1.ContainerPort{{ContainerPort: c.Port}}
+			serving = append(serving, name)
+		}
+		if container.Env, err = containerEnv(c.EnvVars); err != nil {
+			return nil, fmt.Errorf("sidecar '%v': %v", name, err)
+		}
+		containers = append(containers, container)
+	}
+
+	switch len(serving) {
+	case 0:
+		return nil, fmt.Errorf("with sidecars, exactly one container must declare the port on which requests are served, but none do: set the port of the function or of one sidecar")
+	case 1:
+		return
+	default:
+		return nil, fmt.Errorf("with sidecars, exactly one container must declare the port on which requests are served, but %v do: %v", len(serving), strings.Join(serving, ", "))
+	}
+}
+
+// updateProbes sets the container's liveness and readiness probes, removing
+// those not configured.
+func updateProbes(template *servingv1.RevisionTemplateSpec, liveness, readiness *faas.Probe) error {
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	container.LivenessProbe = probe(liveness)
+	container.ReadinessProbe = probe(readiness)
+	return nil
+}
+
+// probe converts a Function probe to its Kubernetes equivalent.
+func probe(p *faas.Probe) *corev1.Probe {
+	if p == nil {
+		return nil
+	}
+	action := &corev1.HTTPGetAction{Path: p.Path}
+	if p.Port != 0 {
+		action.Port = intstr.FromInt(int(p.Port))
+	}
+	return &corev1.Probe{
+		Handler:             corev1.Handler{HTTPGet: action},
+		InitialDelaySeconds: p.InitialDelaySeconds,
+		PeriodSeconds:       p.PeriodSeconds,
+	}
+}
+
+// updatePort sets the port on which the container listens, or removes it
+// such that the platform default applies if zero.
+func updatePort(template *servingv1.RevisionTemplateSpec, port int32) error {
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	if port == 0 {
+		container.Ports = nil
+		return nil
+	}
+	container.Ports = []corev1.ContainerPort{{ContainerPort: port}}
+	return nil
+}
+
+// updateProtocol names the port of the container serving requests, the
+// Function's or a sidecar's, by the protocol with which they are served, as
+// Knative requires of h2c.  Where no container declares a port, that of the
+// Function's is declared as the default port.  Ports are left unnamed if no
+// protocol is configured.
+func updateProtocol(template *servingv1.RevisionTemplateSpec, protocol string) error {
+	if err := validateProtocol(protocol); err != nil {
+		return err
+	}
+	if protocol == "" {
+		return nil
+	}
+	for i := range template.Spec.Containers {
+		if ports := template.Spec.Containers[i].Ports; len(ports) > 0 {
+			ports[0].Name = protocol
+			return nil
+		}
+	}
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	container.Ports = []corev1.ContainerPort{{Name: protocol, ContainerPort: defaultPort}}
+	return nil
+}
+
+// validateProtocol ensures the protocol, if any, is one supported by Knative.
+func validateProtocol(protocol string) error {
+	switch networking.ProtocolType(protocol) {
+	case "", networking.ProtocolHTTP1, networking.ProtocolH2C:
+		return nil
+	}
+	return fmt.Errorf("invalid protocol '%v': must be %v or %v", protocol, networking.ProtocolHTTP1, networking.ProtocolH2C)
+}
+
+// Annotations by which Prometheus discovers the pods to scrape.
+const (
+	prometheusScrapeAnnotationKey = "prometheus.io/scrape"
+	prometheusPortAnnotationKey   = "prometheus.io/port"
+	prometheusPathAnnotationKey   = "prometheus.io/path"
+
+	defaultPort        = 8080
+	defaultMetricsPath = "/metrics"
+)
+
+// updateMetricsAnnotations annotates the revision template such that its pods
+// are scraped by Prometheus at the Function's metrics port and path.
+func updateMetricsAnnotations(template *servingv1.RevisionTemplateSpec, f faas.Function) {
+	port := f.MetricsPort
+	if port == 0 {
+		port = f.Port
+	}
+	if port == 0 {
+		port = defaultPort
+	}
+	path := f.MetricsPath
+	if path == "" {
+		path = defaultMetricsPath
+	}
+	setAnnotation(template, prometheusScrapeAnnotationKey, "true")
+	setAnnotation(template, prometheusPortAnnotationKey, strconv.Itoa(int(port)))
+	setAnnotation(template, prometheusPathAnnotationKey, path)
+}
+
+// removeMetricsAnnotations of the revision template, such that its pods are no
+// longer scraped, unless configured as annotations of the Function.
+func removeMetricsAnnotations(template *servingv1.RevisionTemplateSpec, f faas.Function) {
+	configured := scopedMetadata(f.Annotations, f.TemplateAnnotations)
+	for _, key := range []string{prometheusScrapeAnnotationKey, prometheusPortAnnotationKey, prometheusPathAnnotationKey} {
+		if _, ok := configured[key]; !ok {
+			delete(template.Annotations, key)
+		}
+	}
+}
+
+// validateMetricsPath ensures the metrics path, if provided, is absolute.
+func validateMetricsPath(path string) error {
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("invalid metrics path '%v', must be absolute", path)
+	}
+	return nil
+}
+
+// updateCommand sets the container's command and args, removing those not
+// configured such that the image's defaults apply.
+func updateCommand(template *servingv1.RevisionTemplateSpec, command, args []string) error {
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	container.Command = command
+	container.Args = args
+	return nil
+}
+
+// updateWorkingDir sets the working directory of the container, removing it
+// if not configured such that that of the image applies.
+func updateWorkingDir(template *servingv1.RevisionTemplateSpec, dir string) error {
+	if err := validateWorkingDir(dir); err != nil {
+		return err
+	}
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	container.WorkingDir = dir
+	return nil
+}
+
+// validateWorkingDir ensures the working directory, if any, is absolute, as
+// relative directories are resolved against that of the image's runtime.
+func validateWorkingDir(dir string) error {
+	if dir != "" && !strings.HasPrefix(dir, "/") {
+		return fmt.Errorf("invalid working directory '%v', must be absolute", dir)
+	}
+	return nil
+}
+
+// updateImagePullPolicy sets the container's image pull policy, removing it
+// if not configured such that the Kubernetes default applies.
+func updateImagePullPolicy(template *servingv1.RevisionTemplateSpec, policy string) error {
+	if err := validateImagePullPolicy(policy); err != nil {
+		return err
+	}
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	container.ImagePullPolicy = corev1.PullPolicy(policy)
+	return nil
+}
+
+// validateImagePullPolicy ensures the policy, if any, is one of those of
+// Kubernetes, which are case sensitive.
+func validateImagePullPolicy(policy string) error {
+	switch corev1.PullPolicy(policy) {
+	case "", corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+		return nil
+	}
+	return fmt.Errorf("invalid image pull policy '%v': must be one of %v, %v or %v", policy, corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever)
+}
+
+// skipsTagResolution reports whether Knative runs the image without first
+// resolving its tag to a digest against the registry, as it does for images
+// referenced by digest and, by default, those of the local registries named.
+// Images loaded onto the cluster's nodes which are not otherwise fail to
+// deploy if the registry is unreachable.
+func skipsTagResolution(image string) bool {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return false
+	}
+	if _, ok := ref.(name.Digest); ok {
+		return true
+	}
+	switch ref.Context().RegistryStr() {
+	case "kind.local", "ko.local", "dev.local":
+		return true
+	}
+	return false
+}
+
+// updateEnvFrom sets the container's envFrom sources to exactly those of the
+// Function, such that removed entries are reconciled.  Kubernetes gives
+// explicitly set env vars precedence over those imported.
+func updateEnvFrom(template *servingv1.RevisionTemplateSpec, envFrom []string) error {
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	sources, err := envFromSources(envFrom)
+	if err != nil {
+		return err
+	}
+	container.EnvFrom = sources
+	return nil
+}
+
+// envFromSources converts entries of the form 'secret:name' or
+// 'configMap:name' to their Kubernetes equivalent.
+func envFromSources(envFrom []string) (sources []corev1.EnvFromSource, err error) {
+	for _, entry := range envFrom {
+		tokens := strings.SplitN(entry, ":", 2)
+		if len(tokens) != 2 || tokens[1] == "" {
+			return nil, fmt.Errorf("invalid envFrom entry '%v', expected 'secret:name' or 'configMap:name'", entry)
+		}
+		reference := corev1.LocalObjectReference{Name: tokens[1]}
+		switch tokens[0] {
+		case "secret":
+			sources = append(sources, corev1.EnvFromSource{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: reference}})
+		case "configMap":
+			sources = append(sources, corev1.EnvFromSource{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: reference}})
+		default:
+			return nil, fmt.Errorf("invalid envFrom entry '%v', expected 'secret:name' or 'configMap:name'", entry)
+		}
+	}
+	return
+}
+
+// updateResources sets the container's resource requirements to exactly
+// those of the Function, removing any which are no longer configured.
+func updateResources(template *servingv1.RevisionTemplateSpec, resources faas.Resources) error {
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	requirements, err := resourceRequirements(resources)
+	if err != nil {
+		return err
+	}
+	container.Resources = requirements
+	return nil
+}
+
+// resourceRequirements converts the Function's resources to their Kubernetes
+// equivalent, erroring on invalid quantities.
+func resourceRequirements(resources faas.Resources) (requirements corev1.ResourceRequirements, err error) {
+	if requirements.Requests, err = resourceList(resources.Requests); err != nil {
+		err = fmt.Errorf("invalid resource requests: %v", err)
+		return
+	}
+	if requirements.Limits, err = resourceList(resources.Limits); err != nil {
+		err = fmt.Errorf("invalid resource limits: %v", err)
+	}
+	return
+}
+
+func resourceList(rl faas.ResourceList) (list corev1.ResourceList, err error) {
+	quantities := []struct {
+		name  corev1.ResourceName
+		value string
+	}{
+		{corev1.ResourceCPU, rl.CPU},
+		{corev1.ResourceMemory, rl.Memory},
+	}
+
+	for _, q := range quantities {
+		if q.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(q.value)
+		if err != nil {
+			return nil, fmt.Errorf("%v quantity '%v': %v", q.name, q.value, err)
+		}
+		if list == nil {
+			list = corev1.ResourceList{}
+		}
+		list[q.name] = quantity
+	}
+	return
+}
+
+// updateScale sets the min and max scale annotations of the template.  A zero
+// value removes the respective annotation such that Knative defaults apply.
+func updateScale(template *servingv1.RevisionTemplateSpec, minScale, maxScale int) (err error) {
+	if minScale != 0 {
+		if err = servinglib.UpdateMinScale(template, minScale); err != nil {
+			return
+		}
+	} else {
+		delete(template.Annotations, autoscaling.MinScaleAnnotationKey)
+	}
+
+	if maxScale != 0 {
+		if err = servinglib.UpdateMaxScale(template, maxScale); err != nil {
+			return
+		}
+	} else {
+		delete(template.Annotations, autoscaling.MaxScaleAnnotationKey)
+	}
+	return
+}
+
+// updateInitialScale sets the initial scale annotation of the template, the
+// number of instances with which a revision starts.  Nil removes it such that
+// the Knative default applies.
+func updateInitialScale(template *servingv1.RevisionTemplateSpec, scale *int) {
+	if scale == nil {
+		setAnnotation(template, autoscaling.InitialScaleAnnotationKey, "")
+		return
+	}
+	setAnnotation(template, autoscaling.InitialScaleAnnotationKey, strconv.Itoa(*scale))
+}
+
+// updateTimeout sets the request timeout of the revision.  Zero removes it
+// such that the Knative default applies.
+func updateTimeout(template *servingv1.RevisionTemplateSpec, timeout int64) error {
+	if err := validateTimeout(timeout); err != nil {
+		return err
+	}
+	if timeout == 0 {
+		template.Spec.TimeoutSeconds = nil
+		return nil
+	}
+	template.Spec.TimeoutSeconds = &timeout
+	return nil
+}
+
+// validateTimeout ensures the timeout is within the bounds permitted by a
+// default Knative installation.
+func validateTimeout(timeout int64) error {
+	if timeout > apisconfig.DefaultMaxRevisionTimeoutSeconds {
+		return fmt.Errorf("timeout (%v) must not exceed %v seconds", timeout, apisconfig.DefaultMaxRevisionTimeoutSeconds)
+	}
+	return nil
+}
+
+// updateTerminationGracePeriod sets the termination grace period of the
+// revision's pods, removing it if not provided such that the Knative default
+// applies.
+func updateTerminationGracePeriod(template *servingv1.RevisionTemplateSpec, seconds *int64) {
+	if seconds == nil {
+		template.Spec.TerminationGracePeriodSeconds = nil
+		return
+	}
+	period := *seconds
+	template.Spec.TerminationGracePeriodSeconds = &period
+}
+
+// updateScaleDurations sets the scale down delay and stable window
+// annotations of the template, removing those which are empty such that
+// Knative defaults apply.
+func updateScaleDurations(template *servingv1.RevisionTemplateSpec, delay, window string) error {
+	if err := validateScaleDurations(delay, window); err != nil {
+		return err
+	}
+	setAnnotation(template, scaleDownDelayAnnotationKey, delay)
+	setAnnotation(template, autoscaling.WindowAnnotationKey, window)
+	return nil
+}
+
+// validateScaleDurations ensures the scale down delay and window are
+// durations within the bounds permitted by Knative.
+func validateScaleDurations(delay, window string) error {
+	if delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return fmt.Errorf("invalid scale down delay '%v': %v", delay, err)
+		}
+		if d < 0 || d > maxScaleDownDelay {
+			return fmt.Errorf("scale down delay '%v' must be between 0s and %v", delay, maxScaleDownDelay)
+		}
+	}
+	if window != "" {
+		w, err := time.ParseDuration(window)
+		if err != nil {
+			return fmt.Errorf("invalid scale window '%v': %v", window, err)
+		}
+		if w < autoscaling.WindowMin || w > autoscaling.WindowMax {
+			return fmt.Errorf("scale window '%v' must be between %v and %v", window, autoscaling.WindowMin, autoscaling.WindowMax)
+		}
+	}
+	return nil
+}
+
+// knownScaleClasses are the classes of the autoscalers provided by Knative
+// Serving and its extensions.
+var knownScaleClasses = map[string]bool{
+	autoscaling.KPA:                true,
+	autoscaling.HPA:                true,
+	"keda.autoscaling.knative.dev": true,
+}
+
+// updateScaleClass sets the autoscaler class annotation of the template,
+// being the Knative Pod Autoscaler unless otherwise selected.
+func updateScaleClass(template *servingv1.RevisionTemplateSpec, class string) {
+	if class == "" {
+		class = autoscaling.KPA
+	}
+	setAnnotation(template, autoscaling.ClassAnnotationKey, class)
+}
+
+// updateScaleMetric sets the autoscaling metric and target annotations of the
+// template, removing those unset such that Knative defaults apply.
+func updateScaleMetric(template *servingv1.RevisionTemplateSpec, class, metric string, target float64) error {
+	if err := validateScaleMetric(class, metric); err != nil {
+		return err
+	}
+	setAnnotation(template, autoscaling.MetricAnnotationKey, metric)
+	value := ""
+	if target != 0 {
+		value = strconv.FormatFloat(target, 'f', -1, 64)
+	}
+	setAnnotation(template, autoscaling.TargetAnnotationKey, value)
+	return nil
+}
+
+// scaleMetric of the Function and its target, being those of its TargetRPS
+// if set, or otherwise its ScaleMetric and ScaleTarget.
+func scaleMetric(f faas.Function) (metric string, target float64) {
+	if f.TargetRPS == 0 {
+		return f.ScaleMetric, f.ScaleTarget
+	}
+	return autoscaling.RPS, f.TargetRPS
+}
+
+// validateScaleMetric ensures the metric is one supported by the autoscaler
+// class.  The metrics of autoscalers other than those of Knative Serving are
+// not known, so are not validated.
+func validateScaleMetric(class, metric string) error {
+	switch class {
+	case "", autoscaling.KPA:
+		if metric != "" && metric != autoscaling.Concurrency && metric != autoscaling.RPS {
+			return fmt.Errorf("invalid scale metric '%v', expected '%v' or '%v'", metric, autoscaling.Concurrency, autoscaling.RPS)
+		}
+	case autoscaling.HPA:
+		if metric != "" && metric != autoscaling.CPU {
+			return fmt.Errorf("invalid scale metric '%v' for autoscaler class '%v', expected '%v'", metric, class, autoscaling.CPU)
+		}
+	}
+	return nil
+}
+
+// updateTargetUtilization sets the target utilization annotation of the
+// template, removing it when unset such that the platform default applies.
+func updateTargetUtilization(template *servingv1.RevisionTemplateSpec, percentage int) {
+	value := ""
+	if percentage != 0 {
+		value = strconv.Itoa(percentage)
+	}
+	setAnnotation(template, autoscaling.TargetUtilizationPercentageKey, value)
+}
+
+// Scaling profiles, expanding into the annotations of scaleProfile.
+const (
+	latencyScaleProfile    = "latency"
+	balancedScaleProfile   = "balanced"
+	throughputScaleProfile = "throughput"
+)
+
+// scaleProfile returns the autoscaling annotations into which the named
+// profile expands, or none if no profile is named.
+func scaleProfile(name string) (map[string]string, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case latencyScaleProfile:
+		return map[string]string{
+			autoscaling.MinScaleAnnotationKey:          "1",
+			autoscaling.TargetAnnotationKey:            "10",
+			autoscaling.TargetUtilizationPercentageKey: "60",
+		}, nil
+	case balancedScaleProfile:
+		return map[string]string{
+			autoscaling.TargetAnnotationKey:            "100",
+			autoscaling.TargetUtilizationPercentageKey: "70",
+		}, nil
+	case throughputScaleProfile:
+		return map[string]string{
+			autoscaling.TargetAnnotationKey:            "200",
+			autoscaling.TargetUtilizationPercentageKey: "90",
+			autoscaling.WindowAnnotationKey:            "120s",
+			scaleDownDelayAnnotationKey:                "5m",
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid scale profile '%v', expected '%v', '%v' or '%v'", name, latencyScaleProfile, balancedScaleProfile, throughputScaleProfile)
+}
+
+// updateScaleProfile sets the annotations of the scaling profile which are
+// not otherwise configured, being those not set by the updates of the
+// Function's scaling which precede it.  The profile's target is that of
+// concurrency, so is not applied to other metrics.
+func updateScaleProfile(template *servingv1.RevisionTemplateSpec, profile, class, metric string) error {
+	if err := validateScaleProfile(profile, class); err != nil {
+		return err
+	}
+	annotations, _ := scaleProfile(profile)
+	for key, value := range annotations {
+		if key == autoscaling.TargetAnnotationKey && metric != "" && metric != autoscaling.Concurrency {
+			continue
+		}
+		if _, ok := template.Annotations[key]; !ok {
+			setAnnotation(template, key, value)
+		}
+	}
+	return nil
+}
+
+// validateScaleProfile ensures the profile is known, and is of the Knative
+// Pod Autoscaler class.
+func validateScaleProfile(profile, class string) error {
+	if _, err := scaleProfile(profile); err != nil {
+		return err
+	}
+	if profile != "" && class != "" && class != autoscaling.KPA {
+		return fmt.Errorf("scale profile '%v' requires the autoscaler class '%v', not '%v'", profile, autoscaling.KPA, class)
+	}
+	return nil
+}
+
+// setAnnotation of the template to the value, removing it if empty.
+func setAnnotation(template *servingv1.RevisionTemplateSpec, key, value string) {
+	if value == "" {
+		delete(template.Annotations, key)
+		return
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[key] = value
+}
+
+// updateEnvVars returns a function which merges the Function's environment
+// variables into those of an existing Service.  Environment variables not
+// configured on the Function, such as those injected by a webhook or
+// operator, are preserved unless explicitly removed with the trailing dash
+// syntax.  The built annotation is refreshed such that a new revision is
+// created, and the BUILT environment variable which it replaces is removed.
+func updateEnvVars(envVars map[string]string) func(service *servingv1.Service) (*servingv1.Service, error) {
+	return func(service *servingv1.Service) (*servingv1.Service, error) {
+		toUpdate, toRemove := envVarChanges(envVars)
+		toRemove = append(toRemove, legacyBuiltEnvVarName)
+
+		template := &service.Spec.Template
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[builtAnnotation] = now().Format("20060102T150405")
+
+		return service, updateEnv(template, toUpdate, toRemove)
+	}
+}
+
+// envVarChanges splits the Function's environment variables into those to
+// update and those to remove (denoted by a trailing dash).
+func envVarChanges(envVars map[string]string) (toUpdate map[string]string, toRemove []string) {
+	toUpdate = make(map[string]string, len(envVars))
+	toRemove = make([]string, 0)
+
+	for name, value := range envVars {
+		if strings.HasSuffix(name, "-") {
+			toRemove = append(toRemove, strings.TrimSuffix(name, "-"))
+		} else {
+			toUpdate[name] = value
+		}
+	}
+	return
+}
+
+// updateBaselineEnv seeds the container with the baseline environment
+// variables, other than those which the Function sets or removes, and removes
+// those of the previous baseline which are no longer of it.  VERBOSE, seeded
+// before the baseline was recorded, is always considered of the previous.
+func updateBaselineEnv(service *servingv1.Service, baseline []corev1.EnvVar, envVars map[string]string) error {
+	container, err := servinglib.ContainerOfRevisionTemplate(&service.Spec.Template)
+	if err != nil {
+		return err
+	}
+	configured := func(name string) bool {
+		_, set := envVars[name]
+		_, unset := envVars[name+"-"]
+		return set || unset
+	}
+
+	replaced := map[string]bool{}
+	for _, name := range append(managedKeys(service.Annotations[managedBaselineEnvAnnotation]), verboseEnvVarName) {
+		replaced[name] = !configured(name)
+	}
+	names := make(map[string]string, len(baseline))
+	seeded := make([]corev1.EnvVar, 0, len(baseline))
+	for _, e := range baseline {
+		if errs := validation.IsEnvVarName(e.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid baseline env var name '%v': %v", e.Name, strings.Join(errs, ","))
+		}
+		names[e.Name] = ""
+		if !configured(e.Name) {
+			replaced[e.Name] = true
+			seeded = append(seeded, e)
+		}
+	}
+
+	env := make([]corev1.EnvVar, 0, len(container.Env)+len(seeded))
+	for _, e := range container.Env {
+		if !replaced[e.Name] {
+			env = append(env, e)
+		}
+	}
+	env = append(env, seeded...)
+	sort.SliceStable(env, func(i, j int) bool {
+		return env[i].Name < env[j].Name
+	})
+	container.Env = env
+
+	if service.Annotations == nil {
+		service.Annotations = map[string]string{}
+	}
+	setManagedKeys(service.Annotations, managedBaselineEnvAnnotation, names)
+	return nil
+}
+
+const (
+	// managedLabelsAnnotation records the keys of the labels applied from the
+	// Function's configuration, such that those later removed from the
+	// configuration can be removed from the Service.
+	managedLabelsAnnotation = "boson.dev/labels"
+
+	// managedAnnotationsAnnotation records the keys of the annotations
+	// applied from the Function's configuration.
+	managedAnnotationsAnnotation = "boson.dev/annotations"
+
+	// managedTemplateLabelsAnnotation and managedTemplateAnnotationsAnnotation
+	// record the keys of those applied to the revision template, which may
+	// differ from those applied to the Service.
+	managedTemplateLabelsAnnotation      = "boson.dev/template-labels"
+	managedTemplateAnnotationsAnnotation = "boson.dev/template-annotations"
+
+	// managedBaselineEnvAnnotation records the names of the environment
+	// variables with which the container was seeded by the baseline.
+	managedBaselineEnvAnnotation = "boson.dev/baseline-env"
+
+	// scaleDownDelayAnnotationKey is the duration for which a revision is
+	// kept at its scale after it is last needed.  Recognized by Knative
+	// Serving v0.20 and later.
+	scaleDownDelayAnnotationKey = autoscaling.GroupName + "/scaleDownDelay"
+
+	// maxScaleDownDelay permitted by Knative.
+	maxScaleDownDelay = time.Hour
+
+	// progressDeadlineAnnotationKey is the duration within which a revision
+	// must become ready before it is marked failed.  Recognized by Knative
+	// Serving v0.21 and later.
+	progressDeadlineAnnotationKey = serving.GroupName + "/progress-deadline"
+
+	// progressDeadlineMargin by which the wait for a Service exceeds the
+	// progress deadline of its revision, allowing for its failure to be
+	// reported.
+	progressDeadlineMargin = 30 * time.Second
+
+	// runtimeLabelKey records the language runtime of the Function, such as
+	// node, go or python.
+	runtimeLabelKey = "boson.dev/runtime"
+
+	// builtAnnotation on the revision template records when the Function was
+	// last deployed, ensuring each deployment creates a new revision.
+	builtAnnotation = "boson.dev/built"
+
+	// legacyBuiltEnvVarName is the environment variable which formerly served
+	// the purpose of builtAnnotation.
+	legacyBuiltEnvVarName = "BUILT"
+)
+
+// updateMetadata merges the labels and annotations onto the Service and its
+// revision template, removing those applied by a previous deployment which
+// are no longer configured.  Labels and annotations not applied by the
+// deployer (for example those of Knative itself) are left untouched.
+// Those of the Function's Labels and Annotations are merged onto both, but
+// for annotations read from pods, such as those of service meshes, which are
+// merged onto the revision template alone.  The Service and Template scoped
+// labels and annotations are merged onto the one alone, taking precedence.
+func updateMetadata(service *servingv1.Service, f faas.Function) {
+	template := &service.Spec.Template
+	previousLabels := managedKeys(service.Annotations[managedLabelsAnnotation])
+	previousAnnotations := managedKeys(service.Annotations[managedAnnotationsAnnotation])
+	// Services last deployed prior to the template's keys being recorded
+	// separately applied the same keys to both.
+	previousTemplateLabels, previousTemplateAnnotations := previousLabels, previousAnnotations
+	if keys, ok := service.Annotations[managedTemplateLabelsAnnotation]; ok {
+		previousTemplateLabels = managedKeys(keys)
+	}
+	if keys, ok := service.Annotations[managedTemplateAnnotationsAnnotation]; ok {
+		previousTemplateAnnotations = managedKeys(keys)
+	}
+
+	serviceAnnotations := make(map[string]string, len(f.Annotations))
+	for key, value := range f.Annotations {
+		if !podAnnotation(key) {
+			serviceAnnotations[key] = value
+		}
+	}
+	serviceLabels := scopedMetadata(f.Labels, f.ServiceLabels)
+	serviceAnnotations = scopedMetadata(serviceAnnotations, f.ServiceAnnotations)
+	templateLabels := scopedMetadata(f.Labels, f.TemplateLabels)
+	templateAnnotations := scopedMetadata(f.Annotations, f.TemplateAnnotations)
+
+	service.Labels = mergeMetadata(service.Labels, serviceLabels, previousLabels)
+	template.Labels = mergeMetadata(template.Labels, templateLabels, previousTemplateLabels)
+	service.Annotations = mergeMetadata(service.Annotations, serviceAnnotations, previousAnnotations)
+	template.Annotations = mergeMetadata(template.Annotations, templateAnnotations, previousTemplateAnnotations)
+
+	// The Function label is always preserved, as it identifies the Service as
+	// having been deployed by this tool.
+	service.Labels[labelKey] = labelValue
+
+	setManagedKeys(service.Annotations, managedLabelsAnnotation, serviceLabels)
+	setManagedKeys(service.Annotations, managedAnnotationsAnnotation, serviceAnnotations)
+	setManagedKeys(service.Annotations, managedTemplateLabelsAnnotation, templateLabels)
+	setManagedKeys(service.Annotations, managedTemplateAnnotationsAnnotation, templateAnnotations)
+}
+
+// scopedMetadata returns the labels or annotations applied to both the Service
+// and its template, overridden by those applied to the one alone.
+func scopedMetadata(both, scoped map[string]string) map[string]string {
+	if len(scoped) == 0 {
+		return both
+	}
+	m := make(map[string]string, len(both)+len(scoped))
+	for key, value := range both {
+		m[key] = value
+	}
+	for key, value := range scoped {
+		m[key] = value
+	}
+	return m
+}
+
+// updateRuntimeLabel records the runtime of the Function as a label on the
+// Service, removing it if the runtime is unknown.
+func updateRuntimeLabel(service *servingv1.Service, runtime string) {
+	if runtime == "" {
+		delete(service.Labels, runtimeLabelKey)
+		return
+	}
+	if service.Labels == nil {
+		service.Labels = map[string]string{}
+	}
+	service.Labels[runtimeLabelKey] = runtime
+}
+
+// updateVisibility labels the Service as cluster-local, such that its route is
+// not exposed externally, or removes the label to restore the default.
+func updateVisibility(service *servingv1.Service, clusterLocal bool) {
+	if !clusterLocal {
+		delete(service.Labels, serving.VisibilityLabelKey)
+		return
+	}
+	if service.Labels == nil {
+		service.Labels = map[string]string{}
+	}
+	service.Labels[serving.VisibilityLabelKey] = serving.VisibilityClusterLocal
+}
+
+// knownIngressClasses are those of the networking layers maintained by the
+// Knative project.
+var knownIngressClasses = map[string]bool{
+	"ambassador.ingress.networking.knative.dev": true,
+	"contour.ingress.networking.knative.dev":    true,
+	"istio.ingress.networking.knative.dev":      true,
+	"kong":                                      true,
+	"kourier.ingress.networking.knative.dev":    true,
+}
+
+// updateIngressClass sets the ingress class annotation of the Service,
+// removing it if empty such that the cluster default applies.
+func updateIngressClass(service *servingv1.Service, class string) {
+	if class == "" {
+		delete(service.Annotations, networking.IngressClassAnnotationKey)
+		return
+	}
+	if service.Annotations == nil {
+		service.Annotations = map[string]string{}
+	}
+	service.Annotations[networking.IngressClassAnnotationKey] = class
+}
+
+// checkOwnership of the Service, returning an error of Kind
+// ErrServiceNotOwned if it is not labelled as a Function, by any of the labels
+// applied now or previously, unless it is to be adopted.
+func (d *Deployer) checkOwnership(service *servingv1.Service) error {
+	if d.Adopt {
+		return nil
+	}
+	for _, key := range []string{functionLabelKey(d.LabelPrefix), labelKey, legacyLabelKey} {
+		if _, ok := service.Labels[key]; ok {
+			return nil
+		}
+	}
+	return &DeployError{Kind: ErrServiceNotOwned, Err: fmt.Errorf("service '%v' exists but was not deployed as a function, so is not modified; adopt it to deploy the function in its place", service.Name)}
+}
+
+// setFunctionLabel identifies the Service as a Function using the given
+// label key, removing the legacy label and that of the default key if
+// overridden.
+func setFunctionLabel(service *servingv1.Service, key string) {
+	delete(service.Labels, legacyLabelKey)
+	if key != labelKey {
+		delete(service.Labels, labelKey)
+	}
+	service.Labels[key] = labelValue
+}
+
+// podAnnotationPrefixes are those of annotations which take effect only on
+// pods, such as those opting a pod in or out of the sidecar injection of a
+// service mesh.
+var podAnnotationPrefixes = []string{
+	"sidecar.istio.io/",
+	"traffic.sidecar.istio.io/",
+	"proxy.istio.io/",
+	"linkerd.io/",
+	"config.linkerd.io/",
+	"config.alpha.linkerd.io/",
+}
+
+// podAnnotation reports whether the annotation takes effect only on pods.
+func podAnnotation(key string) bool {
+	for _, prefix := range podAnnotationPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeMetadata removes the previously applied keys from dest and sets those
+// of src, returning the (possibly newly allocated) result.
+func mergeMetadata(dest, src map[string]string, previous []string) map[string]string {
+	if dest == nil {
+		dest = make(map[string]string, len(src))
+	}
+	for _, key := range previous {
+		delete(dest, key)
+	}
+	for key, value := range src {
+		dest[key] = value
+	}
+	return dest
+}
+
+// managedKeys parses the comma-separated list of keys recorded in a managed
+// keys annotation.
+func managedKeys(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// setManagedKeys records the sorted keys of m in the given annotation, or
+// removes the annotation if there are none.
+func setManagedKeys(annotations map[string]string, annotation string, m map[string]string) {
+	if len(m) == 0 {
+		delete(annotations, annotation)
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	annotations[annotation] = strings.Join(keys, ",")
+}
+
+// validateMetadata ensures the label and annotation keys are qualified names
+// and that the label values are valid.
+func validateMetadata(labels, annotations map[string]string) error {
+	for key, value := range labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid label key '%v': %v", key, strings.Join(errs, ","))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid value for label '%v': %v", key, strings.Join(errs, ","))
+		}
+	}
+	for key := range annotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid annotation key '%v': %v", key, strings.Join(errs, ","))
+		}
+	}
+	return nil
+}
+
+// updateEnv sets the given environment variables on the container, either as
+// literal values or as references to keys of Secrets or ConfigMaps, removes
+// those to be removed, and sorts the result by name.  Environment variables
+// not mentioned are left untouched.
+func updateEnv(template *servingv1.RevisionTemplateSpec, toUpdate map[string]string, toRemove []string) error {
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+
+	replaced := make(map[string]bool, len(toUpdate)+len(toRemove))
+	for name := range toUpdate {
+		replaced[name] = true
+	}
+	for _, name := range toRemove {
+		replaced[name] = true
+	}
+
+	env := make([]corev1.EnvVar, 0, len(container.Env)+len(toUpdate))
+	for _, e := range container.Env {
+		if !replaced[e.Name] {
+			env = append(env, e)
+		}
+	}
+	for name, value := range toUpdate {
+		source, err := envVarSource(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for env var '%v': %v", name, err)
+		}
+		if source != nil {
+			env = append(env, corev1.EnvVar{Name: name, ValueFrom: source})
+		} else {
+			env = append(env, corev1.EnvVar{Name: name, Value: value})
+		}
+	}
+
+	sort.SliceStable(env, func(i, j int) bool {
+		return env[i].Name < env[j].Name
+	})
+	container.Env = env
+	return nil
+}
+
+// envVarSource parses an environment variable value, returning the source to
+// which it refers if it is of the form {{ secret:name:key }} or
+// {{ configMap:name:key }}.  Literal values return a nil source.
+func envVarSource(value string) (*corev1.EnvVarSource, error) {
+	ref, err := faas.ParseEnvVarReference(value)
+	if err != nil || ref == nil {
+		return nil, err
+	}
+	if ref.Kind == "secret" {
+		return &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+				Key:                  ref.Key,
+			},
+		}, nil
+	}
+	return &corev1.EnvVarSource{
+		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+			Key:                  ref.Key,
+		},
+	}, nil
+}
+
+// hasEnvVarSources returns true if any of the environment variables
+// reference a Secret or ConfigMap.
+func hasEnvVarSources(envVars map[string]string) bool {
+	for _, value := range envVars {
+		if ref, _ := faas.ParseEnvVarReference(value); ref != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// missingEnvVarSources returns a warning for each Secret or ConfigMap
+// referenced by the environment variables which does not exist in the
+// namespace (or which could not be verified).
+func missingEnvVarSources(client kubernetes.Interface, namespace string, envVars map[string]string) (warnings []string) {
+	for name, value := range envVars {
+		if strings.HasSuffix(name, "-") {
+			continue
+		}
+		source, err := envVarSource(value)
+		if err != nil || source == nil {
+			continue
+		}
+
+		var kind, sourceName string
+		if source.SecretKeyRef != nil {
+			kind, sourceName = "Secret", source.SecretKeyRef.Name
+			_, err = client.CoreV1().Secrets(namespace).Get(sourceName, metav1.GetOptions{})
+		} else {
+			kind, sourceName = "ConfigMap", source.ConfigMapKeyRef.Name
+			_, err = client.CoreV1().ConfigMaps(namespace).Get(sourceName, metav1.GetOptions{})
+		}
+
+		if errors.IsNotFound(err) {
+			warnings = append(warnings, fmt.Sprintf("env var '%v' references %v '%v' which does not exist in namespace '%v'", name, kind, sourceName, namespace))
+		} else if err != nil {
+			warnings = append(warnings, fmt.Sprintf("unable to verify %v '%v' referenced by env var '%v': %v", kind, sourceName, name, err))
+		}
+	}
+	sort.Strings(warnings)
+	return
 }
@@ -0,0 +1,2701 @@
+package knative
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8swait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	clientservingv1 "knative.dev/client/pkg/serving/v1"
+	"knative.dev/client/pkg/wait"
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	sourcesv1alpha2 "knative.dev/eventing/pkg/apis/sources/v1alpha2"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestGenerateNewServiceScale ensures that min and max scale are rendered as
+// annotations on the revision template, and omitted when zero.
+func TestGenerateNewServiceScale(t *testing.T) {
+	cases := []struct {
+		MinScale int
+		MaxScale int
+		Min      string // expected annotation value, "" for absent
+		Max      string
+		Err      bool
+	}{
+		{0, 0, "", "", false},
+		{1, 0, "1", "", false},
+		{0, 5, "", "5", false},
+		{1, 5, "1", "5", false},
+		{-1, 0, "", "", true},
+		{5, 1, "", "", true}, // max less than min
+	}
+
+	for _, c := range cases {
+		f := faas.Function{Image: "quay.io/alice/f:latest", MinScale: c.MinScale, MaxScale: c.MaxScale}
+		if c.Err {
+			if !deployInvalid(f) {
+				t.Fatalf("expected error for min %v max %v", c.MinScale, c.MaxScale)
+			}
+			continue
+		}
+		service, err := generateNewService("f", f, nil)
+		if err != nil {
+			t.Fatalf("unexpected error for min %v max %v: %v", c.MinScale, c.MaxScale, err)
+		}
+		assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.MinScaleAnnotationKey, c.Min)
+		assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.MaxScaleAnnotationKey, c.Max)
+	}
+}
+
+// TestUpdateServiceScale ensures that scale changes are applied to an existing
+// service, and that zeroed values remove stale annotations.
+func TestUpdateServiceScale(t *testing.T) {
+	service, err := generateNewService("f", faas.Function{Image: "quay.io/alice/f:latest", MinScale: 1, MaxScale: 5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err = updateService(faas.Function{MaxScale: 10}, nil)(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.MinScaleAnnotationKey, "")
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.MaxScaleAnnotationKey, "10")
+}
+
+// deployInvalid reports whether deploying the Function is rejected as invalid,
+// being validated before its Service is generated.
+func deployInvalid(f faas.Function) bool {
+	if f.Name == "" {
+		f.Name = "f"
+	}
+	_, err := (&Deployer{client: knativetest.NewServingClient()}).Deploy(f)
+	return errors.Is(err, ErrServiceInvalid)
+}
+
+func assertAnnotation(t *testing.T, annotations map[string]string, key, expected string) {
+	t.Helper()
+	actual, ok := annotations[key]
+	if expected == "" && ok {
+		t.Fatalf("expected annotation '%v' to be absent, got '%v'", key, actual)
+	}
+	if expected != "" && actual != expected {
+		t.Fatalf("expected annotation '%v' to be '%v', got '%v'", key, expected, actual)
+	}
+}
+
+// TestGenerateNewServiceScaleMetric ensures that the autoscaling metric and
+// target are rendered as annotations, omitted when unset, and validated.
+func TestGenerateNewServiceScaleMetric(t *testing.T) {
+	cases := []struct {
+		Metric string
+		Target float64
+		Valid  bool
+		Value  string // expected target annotation, "" for absent
+	}{
+		{"", 0, true, ""},
+		{"concurrency", 50, true, "50"},
+		{"rps", 150.5, true, "150.5"},
+		{"rps", 0, true, ""},
+		{"", 10, true, "10"},
+		{"cpu", 80, false, ""},
+		{"memory", 0, false, ""},
+		{"concurrency", -1, false, ""},
+	}
+	for _, c := range cases {
+		f := faas.Function{Image: "quay.io/alice/f:latest", ScaleMetric: c.Metric, ScaleTarget: c.Target}
+		if !c.Valid {
+			if !deployInvalid(f) {
+				t.Fatalf("expected metric '%v' with target %v to be rejected", c.Metric, c.Target)
+			}
+			continue
+		}
+		service, err := generateNewService("f", f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.MetricAnnotationKey, c.Metric)
+		assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.TargetAnnotationKey, c.Value)
+	}
+
+	// Defaults are restored on update when unset.
+	f := faas.Function{Image: "quay.io/alice/f:latest", ScaleMetric: "rps", ScaleTarget: 100}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ScaleMetric, f.ScaleTarget = "", 0
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.MetricAnnotationKey, "")
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.TargetAnnotationKey, "")
+}
+
+// TestGenerateNewServiceTargetRPS ensures that a TargetRPS is expanded into
+// both the rps metric and the target annotations, and is validated.
+func TestGenerateNewServiceTargetRPS(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", TargetRPS: 200}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.MetricAnnotationKey, autoscaling.RPS)
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.TargetAnnotationKey, "200")
+
+	invalid := []faas.Function{
+		{Image: f.Image, TargetRPS: -5},
+		{Image: f.Image, TargetRPS: 200, ScaleMetric: "concurrency"},
+		{Image: f.Image, TargetRPS: 200, ScaleTarget: 100},
+		{Image: f.Image, TargetRPS: 200, ScaleClass: autoscaling.HPA},
+	}
+	for _, f := range invalid {
+		if !deployInvalid(f) {
+			t.Fatalf("expected deploying %+v to be invalid", f)
+		}
+	}
+}
+
+// TestGenerateNewServiceTargetUtilization ensures that the target
+// utilization is rendered as an annotation, removed when unset, and
+// validated to be a percentage.
+func TestGenerateNewServiceTargetUtilization(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", TargetUtilization: 85}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.TargetUtilizationPercentageKey, "85")
+
+	f.TargetUtilization = 0
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.TargetUtilizationPercentageKey, "")
+
+	for _, percentage := range []int{-1, 101} {
+		f.TargetUtilization = percentage
+		if !deployInvalid(f) {
+			t.Fatalf("expected target utilization %v to be rejected", percentage)
+		}
+	}
+}
+
+// TestGenerateNewServiceScaleProfile ensures that each scaling profile
+// expands into its annotations, that those configured explicitly take
+// precedence, and that the profile's annotations are removed with it.
+func TestGenerateNewServiceScaleProfile(t *testing.T) {
+	cases := []struct {
+		Profile  string
+		Expected map[string]string
+	}{
+		{"latency", map[string]string{
+			autoscaling.MinScaleAnnotationKey:          "1",
+			autoscaling.TargetAnnotationKey:            "10",
+			autoscaling.TargetUtilizationPercentageKey: "60",
+		}},
+		{"balanced", map[string]string{
+			autoscaling.TargetAnnotationKey:            "100",
+			autoscaling.TargetUtilizationPercentageKey: "70",
+		}},
+		{"throughput", map[string]string{
+			autoscaling.TargetAnnotationKey:            "200",
+			autoscaling.TargetUtilizationPercentageKey: "90",
+			autoscaling.WindowAnnotationKey:            "120s",
+			scaleDownDelayAnnotationKey:                "5m",
+		}},
+	}
+	for _, c := range cases {
+		expected, err := scaleProfile(c.Profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, c.Expected) {
+			t.Fatalf("%v: expected annotations %v, got %v", c.Profile, c.Expected, expected)
+		}
+		f := faas.Function{Image: "quay.io/alice/f:latest", ScaleProfile: c.Profile}
+		service, err := generateNewService("f", f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range c.Expected {
+			assertAnnotation(t, service.Spec.Template.Annotations, key, value)
+		}
+
+		f.ScaleProfile = ""
+		if service, err = updateService(f, nil)(service); err != nil {
+			t.Fatal(err)
+		}
+		for key := range c.Expected {
+			assertAnnotation(t, service.Spec.Template.Annotations, key, "")
+		}
+	}
+
+	// Explicit settings take precedence over those of the profile, whose
+	// concurrency target does not apply to other metrics.
+	f := faas.Function{Image: "quay.io/alice/f:latest", ScaleProfile: "latency", MinScale: 3, TargetRPS: 50}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.MinScaleAnnotationKey, "3")
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.TargetAnnotationKey, "50")
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.TargetUtilizationPercentageKey, "60")
+	f = faas.Function{Image: "quay.io/alice/f:latest", ScaleProfile: "throughput", ScaleMetric: "rps"}
+	if service, err = generateNewService("f", f, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.TargetAnnotationKey, "")
+
+	for _, f := range []faas.Function{
+		{Image: "quay.io/alice/f:latest", ScaleProfile: "fastest"},
+		{Image: "quay.io/alice/f:latest", ScaleProfile: "latency", ScaleClass: autoscaling.HPA, ScaleMetric: "cpu"},
+	} {
+		if _, err := generateNewService("f", f, nil); err == nil {
+			t.Fatalf("expected scale profile '%v' of class '%v' to be rejected", f.ScaleProfile, f.ScaleClass)
+		}
+	}
+}
+
+// TestGenerateNewServiceScaleClass ensures that the autoscaler class is
+// applied, defaulting to the Knative Pod Autoscaler, that custom classes are
+// passed through, and that metrics are validated against known classes.
+func TestGenerateNewServiceScaleClass(t *testing.T) {
+	cases := []struct {
+		Class    string
+		Metric   string
+		Valid    bool
+		Expected string
+	}{
+		{"", "", true, autoscaling.KPA},
+		{autoscaling.KPA, "rps", true, autoscaling.KPA},
+		{autoscaling.HPA, "cpu", true, autoscaling.HPA},
+		{autoscaling.HPA, "rps", false, ""},
+		{"", "cpu", false, ""},
+		{"custom.example.com", "queue-length", true, "custom.example.com"},
+	}
+	for _, c := range cases {
+		f := faas.Function{Image: "quay.io/alice/f:latest", ScaleClass: c.Class, ScaleMetric: c.Metric}
+		if !c.Valid {
+			if !deployInvalid(f) {
+				t.Fatalf("expected metric '%v' of class '%v' to be rejected", c.Metric, c.Class)
+			}
+			continue
+		}
+		service, err := generateNewService("f", f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.ClassAnnotationKey, c.Expected)
+	}
+}
+
+// TestGenerateNewServiceScaleDurations ensures that the scale down delay and
+// window are rendered as annotations, omitted when empty, and validated.
+func TestGenerateNewServiceScaleDurations(t *testing.T) {
+	cases := []struct {
+		Delay  string
+		Window string
+		Valid  bool
+	}{
+		{"", "", true},
+		{"15m", "", true},
+		{"", "120s", true},
+		{"0s", "1h", true},
+		{"forever", "", false},
+		{"2h", "", false},
+		{"-1s", "", false},
+		{"", "1s", false},
+		{"", "2h", false},
+	}
+
+	for _, c := range cases {
+		f := faas.Function{Image: "quay.io/alice/f:latest", ScaleDownDelay: c.Delay, ScaleWindow: c.Window}
+		if !c.Valid {
+			if !deployInvalid(f) {
+				t.Fatalf("expected delay '%v' and window '%v' to be invalid", c.Delay, c.Window)
+			}
+			continue
+		}
+		service, err := generateNewService("f", f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertAnnotation(t, service.Spec.Template.Annotations, scaleDownDelayAnnotationKey, c.Delay)
+		assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.WindowAnnotationKey, c.Window)
+	}
+}
+
+// TestGenerateNewServiceProgressDeadline ensures that the progress deadline
+// is rendered as an annotation, omitted when empty, and validated.
+func TestGenerateNewServiceProgressDeadline(t *testing.T) {
+	cases := []struct {
+		Deadline string
+		Valid    bool
+	}{
+		{"", true},
+		{"15m", true},
+		{"90s", true},
+		{"soon", false},
+		{"0s", false},
+		{"-1m", false},
+	}
+
+	for _, c := range cases {
+		f := faas.Function{Image: "quay.io/alice/f:latest", ProgressDeadline: c.Deadline}
+		if !c.Valid {
+			if !deployInvalid(f) {
+				t.Fatalf("expected progress deadline '%v' to be invalid", c.Deadline)
+			}
+			continue
+		}
+		service, err := generateNewService("f", f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertAnnotation(t, service.Spec.Template.Annotations, progressDeadlineAnnotationKey, c.Deadline)
+	}
+}
+
+// TestGenerateNewServiceTimeout ensures that the request timeout is set on
+// the revision on create and update, and that out of range values error.
+func TestGenerateNewServiceTimeout(t *testing.T) {
+	cases := []struct {
+		Timeout int64
+		Valid   bool
+	}{
+		{0, true},
+		{1, true},
+		{300, true},
+		{600, true},
+		{601, false},
+		{-1, false},
+	}
+
+	for _, c := range cases {
+		f := faas.Function{Image: "quay.io/alice/f:latest", Timeout: c.Timeout}
+		if !c.Valid {
+			if !deployInvalid(f) {
+				t.Fatalf("expected timeout %v to be invalid", c.Timeout)
+			}
+			continue
+		}
+		service, err := generateNewService("f", f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeout := service.Spec.Template.Spec.TimeoutSeconds
+		if c.Timeout == 0 && timeout != nil {
+			t.Fatalf("expected no timeout, got %v", *timeout)
+		}
+		if c.Timeout != 0 && (timeout == nil || *timeout != c.Timeout) {
+			t.Fatalf("expected timeout %v, got %v", c.Timeout, timeout)
+		}
+	}
+
+	f := faas.Function{Image: "quay.io/alice/f:latest", Timeout: 60}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Timeout = 120
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if timeout := service.Spec.Template.Spec.TimeoutSeconds; timeout == nil || *timeout != 120 {
+		t.Fatalf("expected timeout 120 on update, got %v", timeout)
+	}
+}
+
+// TestGenerateNewServiceTerminationGracePeriod ensures that the termination
+// grace period reaches the revision's pod spec, including an explicit zero,
+// that it is omitted when not provided, and that negative values error.
+func TestGenerateNewServiceTerminationGracePeriod(t *testing.T) {
+	cases := []struct {
+		Seconds *int64
+		Valid   bool
+	}{
+		{nil, true},
+		{&[]int64{0}[0], true},
+		{&[]int64{45}[0], true},
+		{&[]int64{-1}[0], false},
+	}
+
+	for _, c := range cases {
+		f := faas.Function{Image: "quay.io/alice/f:latest", TerminationGracePeriod: c.Seconds}
+		if !c.Valid {
+			if !deployInvalid(f) {
+				t.Fatalf("expected termination grace period %v to be invalid", *c.Seconds)
+			}
+			continue
+		}
+		service, err := generateNewService("f", f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		period := service.Spec.Template.Spec.TerminationGracePeriodSeconds
+		if c.Seconds == nil && period != nil {
+			t.Fatalf("expected no termination grace period, got %v", *period)
+		}
+		if c.Seconds != nil && (period == nil || *period != *c.Seconds) {
+			t.Fatalf("expected termination grace period %v, got %v", *c.Seconds, period)
+		}
+	}
+}
+
+// TestGenerateNewServiceInitialScale ensures that the initial scale, if
+// provided, is emitted as the initial scale annotation of the revision, with
+// zero applied explicitly, that it is removed when no longer provided, and
+// that negative values are rejected.
+func TestGenerateNewServiceInitialScale(t *testing.T) {
+	cases := []struct {
+		Scale    *int
+		Expected string
+		Valid    bool
+	}{
+		{nil, "", true},
+		{&[]int{0}[0], "0", true},
+		{&[]int{3}[0], "3", true},
+		{&[]int{-1}[0], "", false},
+	}
+
+	for _, c := range cases {
+		f := faas.Function{Image: "quay.io/alice/f:latest", InitialScale: c.Scale}
+		if !c.Valid {
+			if !deployInvalid(f) {
+				t.Fatalf("expected initial scale %v to be invalid", *c.Scale)
+			}
+			continue
+		}
+		service, err := generateNewService("f", f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.InitialScaleAnnotationKey, c.Expected)
+
+		f.InitialScale = nil
+		if service, err = updateService(f, nil)(service); err != nil {
+			t.Fatal(err)
+		}
+		assertAnnotation(t, service.Spec.Template.Annotations, autoscaling.InitialScaleAnnotationKey, "")
+	}
+}
+
+// TestGenerateNewServiceConcurrency ensures that the Function's concurrency
+// is set as the container concurrency of the revision, with zero (unlimited)
+// being applied explicitly, and negative values rejected.
+func TestGenerateNewServiceConcurrency(t *testing.T) {
+	cases := []struct {
+		Concurrency int64
+		Err         bool
+	}{
+		{0, false},
+		{1, false},
+		{100, false},
+		{-1, true},
+	}
+
+	for _, c := range cases {
+		service, err := generateNewService("f", faas.Function{Image: "quay.io/alice/f:latest", Concurrency: c.Concurrency}, nil)
+		if err != nil {
+			if !c.Err {
+				t.Fatalf("unexpected error for concurrency %v: %v", c.Concurrency, err)
+			}
+			continue
+		}
+		if c.Err {
+			t.Fatalf("expected error for concurrency %v", c.Concurrency)
+		}
+		cc := service.Spec.Template.Spec.ContainerConcurrency
+		if cc == nil || *cc != c.Concurrency {
+			t.Fatalf("expected container concurrency %v, got %v", c.Concurrency, cc)
+		}
+	}
+
+	// Updates also apply the concurrency
+	service, err := generateNewService("f", faas.Function{Image: "quay.io/alice/f:latest"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service, err = updateService(faas.Function{Concurrency: 1}, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if cc := service.Spec.Template.Spec.ContainerConcurrency; cc == nil || *cc != 1 {
+		t.Fatalf("expected updated container concurrency 1, got %v", cc)
+	}
+}
+
+// TestGenerateNewServiceResources ensures that resource requests and limits
+// are converted to container resource requirements, and that invalid
+// quantities are rejected.
+func TestGenerateNewServiceResources(t *testing.T) {
+	f := faas.Function{
+		Image: "quay.io/alice/f:latest",
+		Resources: faas.Resources{
+			Requests: faas.ResourceList{CPU: "250m", Memory: "64Mi"},
+			Limits:   faas.ResourceList{Memory: "128Mi"},
+		},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources := service.Spec.Template.Spec.Containers[0].Resources
+	if q := resources.Requests[corev1.ResourceCPU]; q.String() != "250m" {
+		t.Fatalf("expected cpu request 250m, got %v", q.String())
+	}
+	if q := resources.Requests[corev1.ResourceMemory]; q.String() != "64Mi" {
+		t.Fatalf("expected memory request 64Mi, got %v", q.String())
+	}
+	if q := resources.Limits[corev1.ResourceMemory]; q.String() != "128Mi" {
+		t.Fatalf("expected memory limit 128Mi, got %v", q.String())
+	}
+	if _, ok := resources.Limits[corev1.ResourceCPU]; ok {
+		t.Fatal("expected no cpu limit")
+	}
+
+	// Updates replace the resource requirements entirely.
+	f.Resources = faas.Resources{Limits: faas.ResourceList{CPU: "1"}}
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	resources = service.Spec.Template.Spec.Containers[0].Resources
+	if len(resources.Requests) != 0 {
+		t.Fatalf("expected requests to be removed, got %v", resources.Requests)
+	}
+	if q := resources.Limits[corev1.ResourceCPU]; q.String() != "1" {
+		t.Fatalf("expected cpu limit 1, got %v", q.String())
+	}
+
+	// Invalid quantities error.
+	f.Resources = faas.Resources{Requests: faas.ResourceList{Memory: "lots"}}
+	if _, err = generateNewService("f", f, nil); err == nil {
+		t.Fatal("expected an invalid memory quantity to error")
+	}
+}
+
+// TestGenerateNewServiceVerbose ensures that VERBOSE is only injected into the
+// container environment when deploying verbosely, and that an explicitly
+// configured value is never overwritten.
+func TestGenerateNewServiceVerbose(t *testing.T) {
+	cases := []struct {
+		Verbose  bool
+		EnvVars  map[string]string
+		Expected string // expected VERBOSE value, "" for absent
+	}{
+		{false, nil, ""},
+		{true, nil, "true"},
+		{false, map[string]string{"VERBOSE": "debug"}, "debug"},
+		{true, map[string]string{"VERBOSE": "false"}, "false"},
+		{true, map[string]string{"VERBOSE-": ""}, ""},
+	}
+
+	for _, c := range cases {
+		f := faas.Function{Image: "quay.io/alice/f:latest", EnvVars: c.EnvVars}
+		service, err := generateNewService("f", f, verboseEnv(c.Verbose))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEnvVar(t, service.Spec.Template.Spec.Containers[0].Env, "VERBOSE", c.Expected)
+
+		// Updating a service previously deployed verbosely only retains
+		// VERBOSE if still appropriate.
+		service.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "VERBOSE", Value: "true"}}
+		if service, err = updateService(f, verboseEnv(c.Verbose))(service); err != nil {
+			t.Fatal(err)
+		}
+		assertEnvVar(t, service.Spec.Template.Spec.Containers[0].Env, "VERBOSE", c.Expected)
+	}
+}
+
+func assertEnvVar(t *testing.T, env []corev1.EnvVar, name, expected string) {
+	t.Helper()
+	for _, e := range env {
+		if e.Name == name {
+			if expected == "" {
+				t.Fatalf("expected env var '%v' to be absent, got '%v'", name, e.Value)
+			}
+			if e.Value != expected {
+				t.Fatalf("expected env var '%v' to be '%v', got '%v'", name, expected, e.Value)
+			}
+			return
+		}
+	}
+	if expected != "" {
+		t.Fatalf("expected env var '%v' to be '%v', but it was absent", name, expected)
+	}
+}
+
+// TestDeployBaselineEnv ensures that a baseline env hook seeds the container
+// in place of VERBOSE, that the Function's env vars take precedence, and that
+// variables dropped from the baseline are removed on update.
+func TestDeployBaselineEnv(t *testing.T) {
+	client := knativetest.NewServingClient()
+	baseline := []corev1.EnvVar{
+		{Name: "NODE_ENV", Value: "production"},
+		{Name: "NODE_OPTIONS", Value: "--max-old-space-size=256"},
+		{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+	}
+	deployer := &Deployer{client: client, Verbose: true, Force: true}
+	WithBaselineEnv(func(f faas.Function) []corev1.EnvVar {
+		if f.Runtime != "node" {
+			return nil
+		}
+		return baseline
+	})(deployer)
+
+	f := faas.Function{Name: "f", Runtime: "node", Image: "quay.io/alice/f:latest", EnvVars: map[string]string{"NODE_OPTIONS": "--inspect"}}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	env := client.Services["f"].Spec.Template.Spec.Containers[0].Env
+	assertEnvVar(t, env, "NODE_ENV", "production")
+	assertEnvVar(t, env, "NODE_OPTIONS", "--inspect")
+	assertEnvVar(t, env, "VERBOSE", "")
+	found := false
+	for _, e := range env {
+		if e.Name == "POD_NAME" && e.ValueFrom != nil && e.ValueFrom.FieldRef.FieldPath == "metadata.name" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected the baseline's POD_NAME to be seeded, got %v", env)
+	}
+
+	f.Runtime = "go"
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	env = client.Services["f"].Spec.Template.Spec.Containers[0].Env
+	assertEnvVar(t, env, "NODE_ENV", "")
+	assertEnvVar(t, env, "POD_NAME", "")
+	assertEnvVar(t, env, "NODE_OPTIONS", "--inspect")
+}
+
+// TestUpdateServicePreservesEnvVars ensures that environment variables added
+// to the Service out-of-band survive an update unless explicitly removed, and
+// that the legacy BUILT env var is removed.
+func TestUpdateServicePreservesEnvVars(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", EnvVars: map[string]string{"A": "1"}}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := &service.Spec.Template.Spec.Containers[0]
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "INJECTED", Value: "webhook"},
+		corev1.EnvVar{Name: "REMOVED", Value: "operator"},
+		corev1.EnvVar{Name: "BUILT", Value: "20200101T000000"})
+
+	f.EnvVars = map[string]string{"A": "2", "REMOVED-": ""}
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	env := service.Spec.Template.Spec.Containers[0].Env
+	assertEnvVar(t, env, "A", "2")
+	assertEnvVar(t, env, "INJECTED", "webhook")
+	assertEnvVar(t, env, "REMOVED", "")
+	assertEnvVar(t, env, "BUILT", "")
+}
+
+// TestGenerateNewServiceBuildEnvVars ensures that variables provided to the
+// build alone are absent from the deployed Service.
+func TestGenerateNewServiceBuildEnvVars(t *testing.T) {
+	f := faas.Function{
+		Image:        "quay.io/alice/f:latest",
+		EnvVars:      map[string]string{"A": "1"},
+		BuildEnvVars: map[string]string{"GOPRIVATE_TOKEN": "s3cret"},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range service.Spec.Template.Spec.Containers {
+		for _, e := range c.Env {
+			if e.Name == "GOPRIVATE_TOKEN" || e.Value == "s3cret" {
+				t.Fatalf("expected build env var to be absent from the service, got %v", e)
+			}
+		}
+	}
+	assertEnvVar(t, service.Spec.Template.Spec.Containers[0].Env, "A", "1")
+}
+
+// TestUpdateServiceBuiltAnnotation ensures that each update sets the time of
+// the deployment as an annotation on the revision template, such that a new
+// revision is created.
+func TestUpdateServiceBuiltAnnotation(t *testing.T) {
+	defer func() { now = time.Now }()
+
+	f := faas.Function{Image: "quay.io/alice/f:latest"}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, builtAnnotation, "20200101T000000")
+
+	now = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC) }
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, builtAnnotation, "20200101T000001")
+}
+
+// TestDeployConverges ensures that redeploying a Function whose image,
+// scaling, resources, labels and probes have changed converges the live
+// Service on that which would be created of the changed Function.
+func TestDeployConverges(t *testing.T) {
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client}
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:v1", EnvVars: map[string]string{"A": "1"}}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Image = "quay.io/alice/f:v2"
+	f.MinScale, f.MaxScale = 1, 5
+	f.Resources = faas.Resources{Limits: faas.ResourceList{Memory: "256Mi"}}
+	f.Labels = map[string]string{"team": "payments"}
+	f.ReadinessProbe = &faas.Probe{Path: "/ready"}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+
+	live := client.Services["f"]
+	if image := live.Spec.Template.Spec.Containers[0].Image; image != "quay.io/alice/f:v2" {
+		t.Fatalf("expected the image to be updated, got '%v'", image)
+	}
+	expected, err := deployer.renderService(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setAnnotation(&expected.Spec.Template, builtAnnotation, live.Spec.Template.Annotations[builtAnnotation])
+	if !equality.Semantic.DeepEqual(live.Spec.Template, expected.Spec.Template) {
+		t.Fatalf("expected the live template to converge on\n%+v\ngot\n%+v", expected.Spec.Template, live.Spec.Template)
+	}
+	if live.Labels["team"] != "payments" {
+		t.Fatalf("expected the service to be labeled, got %v", live.Labels)
+	}
+}
+
+// TestDeployUnchanged ensures that redeploying an unchanged Function does not
+// update its Service, and so creates no new revision, unless forced, whereas
+// changing an env var does.
+func TestDeployUnchanged(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	client := knativetest.NewServingClient()
+	events := []EventType{}
+	deployer := &Deployer{client: client, OnEvent: func(e Event) { events = append(events, e.Type) }}
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", EnvVars: map[string]string{"A": "1"}}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	created := client.Services["f"].DeepCopy()
+
+	now = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC) }
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if client.Updates != 0 {
+		t.Fatalf("expected an unchanged function not to be updated, got %v updates", client.Updates)
+	}
+	if !containsEvent(events, EventUnchanged) || containsEvent(events, EventUpdating) {
+		t.Fatalf("expected an Unchanged event in place of Updating, got %v", events)
+	}
+	if !reflect.DeepEqual(client.Services["f"], created) {
+		t.Fatalf("expected the service to be unchanged")
+	}
+
+	f.EnvVars["A"] = "2"
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if client.Updates != 1 {
+		t.Fatalf("expected a changed env var to update the service, got %v updates", client.Updates)
+	}
+	assertAnnotation(t, client.Services["f"].Spec.Template.Annotations, builtAnnotation, "20200101T000001")
+
+	deployer.Force = true
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if client.Updates != 2 {
+		t.Fatalf("expected a forced deploy to update the service, got %v updates", client.Updates)
+	}
+}
+
+// containsEvent reports whether the event type is among those emitted.
+func containsEvent(events []EventType, t EventType) bool {
+	for _, e := range events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// TestEnvVarSource ensures that env var values referencing Secret and
+// ConfigMap keys are parsed, literals are passed through, and malformed
+// references error.
+func TestEnvVarSource(t *testing.T) {
+	cases := []struct {
+		Value string
+		Kind  string // "secret", "configMap" or "" for a literal value
+		Name  string
+		Key   string
+		Err   bool
+	}{
+		{"plain", "", "", "", false},
+		{"", "", "", "", false},
+		{"{{ not a reference }}", "", "", "", false},
+		{"{{ secret:db:password }}", "secret", "db", "password", false},
+		{"{{secret:db:password}}", "secret", "db", "password", false},
+		{"{{ configMap:app-config:log.level }}", "configMap", "app-config", "log.level", false},
+		{"{{ secret:db }}", "", "", "", true},
+		{"{{ secret:db:password", "", "", "", true},
+		{"{{ configMap::key }}", "", "", "", true},
+		{"{{ secret:d b:password }}", "", "", "", true},
+	}
+
+	for _, c := range cases {
+		source, err := envVarSource(c.Value)
+		if err != nil {
+			if !c.Err {
+				t.Fatalf("unexpected error parsing '%v': %v", c.Value, err)
+			}
+			continue
+		}
+		if c.Err {
+			t.Fatalf("expected error parsing '%v'", c.Value)
+		}
+		switch c.Kind {
+		case "":
+			if source != nil {
+				t.Fatalf("expected '%v' to be a literal, got %v", c.Value, source)
+			}
+		case "secret":
+			if source == nil || source.SecretKeyRef == nil || source.SecretKeyRef.Name != c.Name || source.SecretKeyRef.Key != c.Key {
+				t.Fatalf("expected '%v' to reference secret %v key %v, got %v", c.Value, c.Name, c.Key, source)
+			}
+		case "configMap":
+			if source == nil || source.ConfigMapKeyRef == nil || source.ConfigMapKeyRef.Name != c.Name || source.ConfigMapKeyRef.Key != c.Key {
+				t.Fatalf("expected '%v' to reference configMap %v key %v, got %v", c.Value, c.Name, c.Key, source)
+			}
+		}
+	}
+}
+
+// TestGenerateNewServiceEnvVarSources ensures that referencing env vars are
+// rendered with a ValueFrom rather than a literal value.
+func TestGenerateNewServiceEnvVarSources(t *testing.T) {
+	f := faas.Function{
+		Image: "quay.io/alice/f:latest",
+		EnvVars: map[string]string{
+			"LITERAL":  "value",
+			"PASSWORD": "{{ secret:db:password }}",
+		},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := service.Spec.Template.Spec.Containers[0].Env
+	if len(env) != 2 {
+		t.Fatalf("expected 2 env vars, got %v", env)
+	}
+	if env[0].Name != "LITERAL" || env[0].Value != "value" || env[0].ValueFrom != nil {
+		t.Fatalf("unexpected literal env var %v", env[0])
+	}
+	if env[1].Name != "PASSWORD" || env[1].Value != "" || env[1].ValueFrom == nil || env[1].ValueFrom.SecretKeyRef == nil {
+		t.Fatalf("unexpected secret env var %v", env[1])
+	}
+}
+
+// TestMissingEnvVarSources ensures that references to Secrets and ConfigMaps
+// which do not exist yield warnings.
+func TestMissingEnvVarSources(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns"},
+	})
+
+	warnings := missingEnvVarSources(client, "ns", map[string]string{
+		"LITERAL":  "value",
+		"PASSWORD": "{{ secret:db:password }}",
+		"TOKEN":    "{{ secret:api:token }}",
+		"LEVEL":    "{{ configMap:app-config:level }}",
+	})
+	if len(warnings) != 2 {
+		t.Fatalf("expected warnings for the missing secret and configMap, got %v", warnings)
+	}
+}
+
+// TestGenerateNewServiceEnvFrom ensures that Secrets and ConfigMaps imported
+// in their entirety are rendered as envFrom sources, and that updates
+// reconcile removals.
+func TestGenerateNewServiceEnvFrom(t *testing.T) {
+	f := faas.Function{
+		Image:   "quay.io/alice/f:latest",
+		EnvFrom: []string{"secret:db", "configMap:app-config"},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envFrom := service.Spec.Template.Spec.Containers[0].EnvFrom
+	if len(envFrom) != 2 {
+		t.Fatalf("expected 2 envFrom sources, got %v", envFrom)
+	}
+	if envFrom[0].SecretRef == nil || envFrom[0].SecretRef.Name != "db" {
+		t.Fatalf("expected secret 'db', got %v", envFrom[0])
+	}
+	if envFrom[1].ConfigMapRef == nil || envFrom[1].ConfigMapRef.Name != "app-config" {
+		t.Fatalf("expected configMap 'app-config', got %v", envFrom[1])
+	}
+
+	f.EnvFrom = []string{"configMap:app-config"}
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	envFrom = service.Spec.Template.Spec.Containers[0].EnvFrom
+	if len(envFrom) != 1 || envFrom[0].ConfigMapRef == nil {
+		t.Fatalf("expected only configMap 'app-config' to remain, got %v", envFrom)
+	}
+
+	for _, invalid := range []string{"db", "secret:", "volume:db"} {
+		f.EnvFrom = []string{invalid}
+		if _, err = generateNewService("f", f, nil); err == nil {
+			t.Fatalf("expected envFrom entry '%v' to error", invalid)
+		}
+	}
+}
+
+// TestValidateImage ensures that well formed image references are accepted
+// and that empty or malformed references are rejected.
+func TestValidateImage(t *testing.T) {
+	cases := []struct {
+		image string
+		valid bool
+	}{
+		{"quay.io/alice/f:latest", true},
+		{"quay.io/alice/f", true},
+		{"localhost:5000/fn:v1", true},
+		{"alice/f", true},
+		{"quay.io/alice/f@sha256:" + strings.Repeat("a", 64), true},
+		{"", false},
+		{"quay.io/alice/f::latest", false},
+		{"quay.io/Alice/f:latest", false},
+		{"quay.io/alice/f@sha256:abc", false},
+		{"quay.io/alice/f:la test", false},
+	}
+	for _, c := range cases {
+		err := validateImage(c.image)
+		if c.valid && err != nil {
+			t.Fatalf("expected image '%v' to be valid, got %v", c.image, err)
+		}
+		if !c.valid && err == nil {
+			t.Fatalf("expected image '%v' to be invalid", c.image)
+		}
+	}
+
+	deployer := &Deployer{client: knativetest.NewServingClient()}
+	if _, err := deployer.Deploy(faas.Function{Name: "f"}); err == nil {
+		t.Fatal("expected deploying a function without an image to error")
+	}
+}
+
+// TestDeployImagePullPolicyNever ensures that an image already loaded onto
+// the cluster, referenced by digest, is deployed with the Never pull policy
+// without contacting a registry, and that the policy is removed on update.
+func TestDeployImagePullPolicyNever(t *testing.T) {
+	image := "registry.internal/alice/f@sha256:" + strings.Repeat("a", 64)
+	resolver := &fakeResolver{err: errors.New("registry unreachable")}
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client, Resolver: resolver}
+
+	f := faas.Function{Name: "f", Image: image, ImagePullPolicy: "Never", PinImageDigest: true}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	container := client.Services["f"].Spec.Template.Spec.Containers[0]
+	if container.Image != image || container.ImagePullPolicy != corev1.PullNever {
+		t.Fatalf("expected image '%v' never pulled, got '%v' pulled '%v'", image, container.Image, container.ImagePullPolicy)
+	}
+	if len(resolver.images) != 0 {
+		t.Fatalf("expected no registry to be contacted, resolved %v", resolver.images)
+	}
+
+	f.ImagePullPolicy = ""
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if policy := client.Services["f"].Spec.Template.Spec.Containers[0].ImagePullPolicy; policy != "" {
+		t.Fatalf("expected the pull policy to be removed, got '%v'", policy)
+	}
+}
+
+// TestGenerateNewServiceImagePullPolicy ensures that each valid pull policy
+// is applied to the container, that none is set by default, and that invalid
+// policies are rejected.
+func TestGenerateNewServiceImagePullPolicy(t *testing.T) {
+	cases := []struct {
+		policy   string
+		expected corev1.PullPolicy
+		valid    bool
+	}{
+		{"", "", true},
+		{"Always", corev1.PullAlways, true},
+		{"IfNotPresent", corev1.PullIfNotPresent, true},
+		{"Never", corev1.PullNever, true},
+		{"never", "", false},
+		{"Sometimes", "", false},
+	}
+	for _, c := range cases {
+		f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", ImagePullPolicy: c.policy}
+		service, err := generateNewService("f", f, nil)
+		if !c.valid {
+			if err == nil {
+				t.Fatalf("expected pull policy '%v' to be invalid", c.policy)
+			}
+			if _, err := (&Deployer{client: knativetest.NewServingClient()}).Deploy(f); !errors.Is(err, ErrServiceInvalid) {
+				t.Fatalf("expected deploying with pull policy '%v' to be invalid, got '%v'", c.policy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected pull policy '%v' to be valid, got '%v'", c.policy, err)
+		}
+		if policy := service.Spec.Template.Spec.Containers[0].ImagePullPolicy; policy != c.expected {
+			t.Fatalf("expected pull policy '%v', got '%v'", c.expected, policy)
+		}
+	}
+}
+
+// TestSkipsTagResolution ensures that images which Knative runs without
+// resolving their tag against a registry are recognized.
+func TestSkipsTagResolution(t *testing.T) {
+	cases := []struct {
+		image    string
+		expected bool
+	}{
+		{"registry.internal/alice/f@sha256:" + strings.Repeat("a", 64), true},
+		{"dev.local/fn:latest", true},
+		{"kind.local/fn", true},
+		{"registry.internal/alice/f:latest", false},
+		{"fn:latest", false},
+	}
+	for _, c := range cases {
+		if skipsTagResolution(c.image) != c.expected {
+			t.Fatalf("expected skipsTagResolution('%v') to be %v", c.image, c.expected)
+		}
+	}
+}
+
+// TestDeployWaitTimeout ensures that the configured wait timeout is used
+// when waiting for both created and updated services, and that the default
+// applies when unset.
+func TestDeployWaitTimeout(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if client.WaitTimeouts[0] != DefaultWaitingTimeout {
+		t.Fatalf("expected default timeout %v, got %v", DefaultWaitingTimeout, client.WaitTimeouts[0])
+	}
+
+	deployer.WaitTimeout = 5 * time.Minute
+	if _, err := deployer.Deploy(f); err != nil { // update
+		t.Fatal(err)
+	}
+	if client.WaitTimeouts[1] != 5*time.Minute {
+		t.Fatalf("expected configured timeout on update, got %v", client.WaitTimeouts[1])
+	}
+
+	client = knativetest.NewServingClient()
+	deployer = &Deployer{client: client}
+	WithWaitTimeout(2 * time.Minute)(deployer)
+	if _, err := deployer.Deploy(f); err != nil { // create
+		t.Fatal(err)
+	}
+	if client.WaitTimeouts[0] != 2*time.Minute {
+		t.Fatalf("expected configured timeout on create, got %v", client.WaitTimeouts[0])
+	}
+}
+
+// TestDeployProgressDeadline ensures that the wait for the service is
+// extended beyond the wait timeout where the progress deadline is longer,
+// such that Knative marks the revision failed before the deployer gives up.
+func TestDeployProgressDeadline(t *testing.T) {
+	cases := []struct {
+		Deadline    string
+		WaitTimeout time.Duration
+		Expected    time.Duration
+	}{
+		{"", 0, DefaultWaitingTimeout},
+		{"30s", 0, DefaultWaitingTimeout},
+		{"30s", 5 * time.Minute, 5 * time.Minute},
+		{"15m", 0, 15*time.Minute + progressDeadlineMargin},
+		{"15m", 20 * time.Minute, 20 * time.Minute},
+		{"5m", 5 * time.Minute, 5*time.Minute + progressDeadlineMargin},
+	}
+
+	for _, c := range cases {
+		client := knativetest.NewServingClient()
+		deployer := &Deployer{client: client, WaitTimeout: c.WaitTimeout}
+		f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", ProgressDeadline: c.Deadline}
+		if _, err := deployer.Deploy(f); err != nil {
+			t.Fatal(err)
+		}
+		if client.WaitTimeouts[0] != c.Expected {
+			t.Fatalf("deadline '%v' with wait timeout %v: expected to wait %v, got %v", c.Deadline, c.WaitTimeout, c.Expected, client.WaitTimeouts[0])
+		}
+		assertAnnotation(t, client.Services["f"].Spec.Template.Annotations, progressDeadlineAnnotationKey, c.Deadline)
+	}
+}
+
+// TestDeployURL ensures that the URL of the route is returned on both create
+// and update.
+func TestDeployURL(t *testing.T) {
+	f := faas.Function{Name: "f.example.com", Image: "quay.io/alice/f:latest"}
+	deployer := &Deployer{client: knativetest.NewServingClient()}
+
+	for i := 0; i < 2; i++ { // create, then update
+		result, err := deployer.Deploy(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.URL != "http://f-example-com.example.com" {
+			t.Fatalf("unexpected URL '%v'", result.URL)
+		}
+	}
+}
+
+// TestDeployResult ensures that the result of both create and update names
+// the service, its namespace and URL, and the revision which became ready,
+// being that created by an update or the current one if unchanged.
+func TestDeployResult(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client, Namespace: "ns"}
+
+	cases := []struct {
+		name     string
+		image    string
+		revision string
+	}{
+		{"create", "quay.io/alice/f:v1", "f-00001"},
+		{"update", "quay.io/alice/f:v2", "f-00002"},
+		{"unchanged", "quay.io/alice/f:v2", "f-00002"},
+	}
+	for _, c := range cases {
+		f.Image = c.image
+		result, err := deployer.Deploy(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := faas.DeploymentResult{Name: "f", Namespace: "ns", URL: "http://f.example.com", Revision: c.revision}
+		if result != expected {
+			t.Fatalf("%v: expected result %+v, got %+v", c.name, expected, result)
+		}
+	}
+
+	deployer.NoWait = true
+	f.Image = "quay.io/alice/f:v3"
+	result, err := deployer.Deploy(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (faas.DeploymentResult{Name: "f", Namespace: "ns"}); result != expected {
+		t.Fatalf("expected result %+v when not waiting, got %+v", expected, result)
+	}
+}
+
+// TestDeployEnsureNamespace ensures that a missing namespace is created when
+// enabled, that an existing namespace is left untouched, and that nothing is
+// created when disabled.
+func TestDeployEnsureNamespace(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	cases := []struct {
+		name     string
+		ensure   bool
+		existing bool
+		expected bool // namespace exists after deploying
+	}{
+		{"missing", true, false, true},
+		{"existing", true, true, true},
+		{"disabled", false, false, false},
+	}
+	for _, c := range cases {
+		coreClient := fake.NewSimpleClientset()
+		if c.existing {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ci", Labels: map[string]string{"a": "b"}}}
+			if _, err := coreClient.CoreV1().Namespaces().Create(namespace); err != nil {
+				t.Fatal(err)
+			}
+		}
+		deployer := &Deployer{Namespace: "ci", client: knativetest.NewServingClient(), coreClient: coreClient}
+		WithEnsureNamespace(c.ensure)(deployer)
+		if _, err := deployer.Deploy(f); err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+
+		namespace, err := coreClient.CoreV1().Namespaces().Get("ci", metav1.GetOptions{})
+		if c.expected && err != nil {
+			t.Fatalf("%v: expected namespace to exist, got %v", c.name, err)
+		}
+		if !c.expected && !apierrors.IsNotFound(err) {
+			t.Fatalf("%v: expected namespace not to be created", c.name)
+		}
+		if c.existing && namespace.Labels["a"] != "b" {
+			t.Fatalf("%v: expected existing namespace to be untouched", c.name)
+		}
+	}
+}
+
+// TestWithServingClient ensures that an injected client is that with which
+// the Function is deployed.
+func TestWithServingClient(t *testing.T) {
+	client := knativetest.NewServingClient()
+	deployer, err := NewDeployer(WithNamespace("ns"), WithServingClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.URL != "http://f.example.com" {
+		t.Fatalf("expected URL 'http://f.example.com', got '%v'", result.URL)
+	}
+	if _, ok := client.Services["f"]; !ok {
+		t.Fatal("expected the service to be created with the injected client")
+	}
+}
+
+// TestNewDeployerOptions ensures that the options of NewDeployer configure
+// the deployments of the resultant Deployer.
+func TestNewDeployerOptions(t *testing.T) {
+	client := knativetest.NewServingClient()
+	deployer, err := NewDeployer(
+		WithNamespace("ns"),
+		WithVerbose(true),
+		WithWaitTimeout(5*time.Minute),
+		WithServingClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployer.Namespace != "ns" {
+		t.Fatalf("expected namespace 'ns', got '%v'", deployer.Namespace)
+	}
+	if _, err = deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.WaitTimeouts) != 1 || client.WaitTimeouts[0] != 5*time.Minute {
+		t.Fatalf("expected the service to be waited upon for 5m, got %v", client.WaitTimeouts)
+	}
+	found := false
+	for _, e := range client.Services["f"].Spec.Template.Spec.Containers[0].Env {
+		found = found || (e.Name == verboseEnvVarName && e.Value == "true")
+	}
+	if !found {
+		t.Fatalf("expected %v=true in the environment of a verbose deployment", verboseEnvVarName)
+	}
+}
+
+// TestDeployMetrics ensures that revisions are annotated for scraping by
+// Prometheus only when enabled, at the Function's metrics port and path or
+// the defaults.
+func TestDeployMetrics(t *testing.T) {
+	cases := []struct {
+		name     string
+		enabled  bool
+		function faas.Function
+		port     string // expected annotation values, "" for absent
+		path     string
+	}{
+		{"disabled", false, faas.Function{MetricsPort: 9090}, "", ""},
+		{"defaults", true, faas.Function{}, "8080", "/metrics"},
+		{"function port", true, faas.Function{Port: 8081}, "8081", "/metrics"},
+		{"configured", true, faas.Function{Port: 8081, MetricsPort: 9090, MetricsPath: "/stats"}, "9090", "/stats"},
+	}
+	for _, c := range cases {
+		c.function.Name = "f"
+		c.function.Image = "quay.io/alice/f:latest"
+		client := knativetest.NewServingClient()
+		deployer := &Deployer{client: client, Metrics: c.enabled}
+
+		for i := 0; i < 2; i++ { // create, then update
+			if _, err := deployer.Deploy(c.function); err != nil {
+				t.Fatalf("%v: %v", c.name, err)
+			}
+			annotations := client.Services["f"].Spec.Template.Annotations
+			scrape := ""
+			if c.enabled {
+				scrape = "true"
+			}
+			assertAnnotation(t, annotations, prometheusScrapeAnnotationKey, scrape)
+			assertAnnotation(t, annotations, prometheusPortAnnotationKey, c.port)
+			assertAnnotation(t, annotations, prometheusPathAnnotationKey, c.path)
+		}
+	}
+
+	// Disabling metrics removes the annotations on update, less those
+	// configured of the Function.
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	client := knativetest.NewServingClient()
+	if _, err := (&Deployer{client: client, Metrics: true}).Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	f.TemplateAnnotations = map[string]string{prometheusPathAnnotationKey: "/custom"}
+	if _, err := (&Deployer{client: client}).Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	annotations := client.Services["f"].Spec.Template.Annotations
+	assertAnnotation(t, annotations, prometheusScrapeAnnotationKey, "")
+	assertAnnotation(t, annotations, prometheusPortAnnotationKey, "")
+	assertAnnotation(t, annotations, prometheusPathAnnotationKey, "/custom")
+
+	deployer := &Deployer{client: knativetest.NewServingClient(), Metrics: true}
+	if _, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", MetricsPath: "metrics"}); err == nil {
+		t.Fatal("expected a relative metrics path to be rejected")
+	}
+}
+
+// TestGenerateNewServiceInitContainers ensures that init containers are
+// rendered into the pod spec, mounting the Function's volumes by path.
+func TestGenerateNewServiceInitContainers(t *testing.T) {
+	f := faas.Function{
+		Image:   "quay.io/alice/f:latest",
+		Volumes: []faas.Volume{{Source: "configMap:models", Path: "/models"}},
+		InitContainers: []faas.InitContainer{
+			{Image: "quay.io/alice/fetch:latest", Command: []string{"fetch", "/models"}, EnvVars: map[string]string{"B": "2", "A": "1"}, Volumes: []string{"/models"}},
+			{Name: "warm", Image: "quay.io/alice/warm:latest"},
+		},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers := service.Spec.Template.Spec.InitContainers
+	if len(containers) != 2 {
+		t.Fatalf("expected 2 init containers, got %v", len(containers))
+	}
+	fetch := containers[0]
+	if fetch.Name != "init-0" || fetch.Image != "quay.io/alice/fetch:latest" || strings.Join(fetch.Command, " ") != "fetch /models" {
+		t.Fatalf("unexpected init container %+v", fetch)
+	}
+	if len(fetch.Env) != 2 || fetch.Env[0].Name != "A" || fetch.Env[1].Value != "2" {
+		t.Fatalf("expected sorted env vars A and B, got %+v", fetch.Env)
+	}
+	if len(fetch.VolumeMounts) != 1 || fetch.VolumeMounts[0].MountPath != "/models" ||
+		fetch.VolumeMounts[0].Name != service.Spec.Template.Spec.Volumes[0].Name {
+		t.Fatalf("expected the function volume to be mounted at /models, got %+v", fetch.VolumeMounts)
+	}
+	if containers[1].Name != "warm" {
+		t.Fatalf("expected init container 'warm', got '%v'", containers[1].Name)
+	}
+
+	// Removed on update
+	f.InitContainers = nil
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if len(service.Spec.Template.Spec.InitContainers) != 0 {
+		t.Fatalf("expected init containers to be removed, got %v", service.Spec.Template.Spec.InitContainers)
+	}
+
+	invalid := []faas.InitContainer{
+		{Image: "quay.io/alice/fetch:latest", Volumes: []string{"/data"}},
+		{Name: "Fetch", Image: "quay.io/alice/fetch:latest"},
+		{Name: "fetch"},
+		{Image: "quay.io/alice/fetch:latest", EnvVars: map[string]string{"A": "{{ secret:a }}"}},
+	}
+	for _, c := range invalid {
+		f.InitContainers = []faas.InitContainer{c}
+		if _, err := generateNewService("f", f, nil); err == nil {
+			t.Fatalf("expected init container %+v to be rejected", c)
+		}
+	}
+}
+
+// TestGenerateNewServiceSidecars ensures that sidecars are appended following
+// the Function's container, and removed on update.
+func TestGenerateNewServiceSidecars(t *testing.T) {
+	f := faas.Function{
+		Image: "quay.io/alice/f:latest",
+		Sidecars: []faas.Sidecar{
+			{Image: "quay.io/alice/proxy:latest", Port: 8443, EnvVars: map[string]string{"UPSTREAM": "localhost:8080"}},
+		},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containers := service.Spec.Template.Spec.Containers
+	if len(containers) != 2 {
+		t.Fatalf("expected 2 containers, got %v", len(containers))
+	}
+	if containers[0].Image != "quay.io/alice/f:latest" || len(containers[0].Ports) != 0 {
+		t.Fatalf("expected the function's container first, without a port, got %+v", containers[0])
+	}
+	proxy := containers[1]
+	if proxy.Name != "sidecar-0" || proxy.Image != "quay.io/alice/proxy:latest" {
+		t.Fatalf("unexpected sidecar %+v", proxy)
+	}
+	if len(proxy.Ports) != 1 || proxy.Ports[0].ContainerPort != 8443 {
+		t.Fatalf("expected the sidecar to serve on port 8443, got %+v", proxy.Ports)
+	}
+	if len(proxy.Env) != 1 || proxy.Env[0].Value != "localhost:8080" {
+		t.Fatalf("expected sidecar env var UPSTREAM, got %+v", proxy.Env)
+	}
+
+	// Removed on update
+	f.Sidecars = nil
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if len(service.Spec.Template.Spec.Containers) != 1 {
+		t.Fatalf("expected sidecars to be removed, got %v", service.Spec.Template.Spec.Containers)
+	}
+}
+
+// TestSidecarsServingPort ensures that exactly one container must declare the
+// serving port when there are sidecars.
+func TestSidecarsServingPort(t *testing.T) {
+	cases := []struct {
+		name     string
+		port     int32
+		sidecars []faas.Sidecar
+		message  string
+	}{
+		{"none", 0, []faas.Sidecar{{Image: "quay.io/alice/agent:latest"}}, "none do"},
+		{"both", 8080, []faas.Sidecar{{Image: "quay.io/alice/proxy:latest", Port: 8443}}, "2 do: function, sidecar-0"},
+		{"duplicate name", 8080, []faas.Sidecar{{Name: "agent", Image: "quay.io/alice/a:latest"}, {Name: "agent", Image: "quay.io/alice/b:latest"}}, "multiple sidecars"},
+		{"no image", 8080, []faas.Sidecar{{Name: "agent"}}, "sidecar 'agent'"},
+	}
+	for _, c := range cases {
+		f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Port: c.port, Sidecars: c.sidecars}
+		_, err := (&Deployer{client: knativetest.NewServingClient()}).Deploy(f)
+		if !errors.Is(err, ErrServiceInvalid) {
+			t.Fatalf("%v: expected the service to be invalid, got '%v'", c.name, err)
+		}
+		if !strings.Contains(err.Error(), c.message) {
+			t.Fatalf("%v: expected error message to contain '%v', got '%v'", c.name, c.message, err)
+		}
+	}
+
+	f := faas.Function{Image: "quay.io/alice/f:latest", Port: 8080, Sidecars: []faas.Sidecar{{Image: "quay.io/alice/agent:latest"}}}
+	if _, err := generateNewService("f", f, nil); err != nil {
+		t.Fatalf("expected the function's port to suffice, got '%v'", err)
+	}
+}
+
+// TestDeployValidates ensures that the Function is validated prior to any
+// interaction with the cluster, with each of its problems reported at once.
+func TestDeployValidates(t *testing.T) {
+	client := knativetest.NewServingClient()
+	f := faas.Function{Name: "f", MinScale: 3, MaxScale: 1, Timeout: -1}
+	_, err := (&Deployer{client: client}).Deploy(f)
+	if !errors.Is(err, ErrServiceInvalid) {
+		t.Fatalf("expected the service to be invalid, got '%v'", err)
+	}
+	var validationErr *faas.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errs) != 3 {
+		t.Fatalf("expected a ValidationError of 3 problems, got '%v'", err)
+	}
+	if len(client.Services) != 0 {
+		t.Fatalf("expected no service to be created, got %v", len(client.Services))
+	}
+}
+
+// TestDeployInitContainersRejected ensures that the rejection of init
+// containers by a cluster without the feature enabled is explained.
+func TestDeployInitContainersRejected(t *testing.T) {
+	denied := &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    400,
+		Message: "admission webhook \"validation.webhook.serving.knative.dev\" denied the request: validation failed: must not set the field(s): spec.template.spec.initContainers",
+	}}
+	client := knativetest.NewServingClient()
+	client.CreateErrs = []error{denied}
+	deployer := &Deployer{client: client, coreClient: fake.NewSimpleClientset()}
+
+	_, err := deployer.Deploy(faas.Function{
+		Name:           "f",
+		Image:          "quay.io/alice/f:latest",
+		InitContainers: []faas.InitContainer{{Image: "quay.io/alice/fetch:latest"}},
+	})
+	if !errors.Is(err, ErrServiceInvalid) {
+		t.Fatalf("expected the service to be invalid, got '%v'", err)
+	}
+	if !strings.Contains(err.Error(), initContainersFeature.flag) {
+		t.Fatalf("expected the error to name the feature '%v', got '%v'", initContainersFeature.flag, err)
+	}
+}
+
+// TestGenerateNewServiceCommand ensures that the container receives the
+// configured command and args, and that the image's defaults are restored
+// when they are removed.
+func TestGenerateNewServiceCommand(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", Command: []string{"/bin/tool"}, Args: []string{"serve", "--port=8080"}}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := service.Spec.Template.Spec.Containers[0]
+	if strings.Join(container.Command, " ") != "/bin/tool" {
+		t.Fatalf("expected command '/bin/tool', got %v", container.Command)
+	}
+	if strings.Join(container.Args, " ") != "serve --port=8080" {
+		t.Fatalf("expected args 'serve --port=8080', got %v", container.Args)
+	}
+
+	f.Command, f.Args = nil, []string{"worker"}
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	container = service.Spec.Template.Spec.Containers[0]
+	if container.Command != nil {
+		t.Fatalf("expected the image's entrypoint, got command %v", container.Command)
+	}
+	if strings.Join(container.Args, " ") != "worker" {
+		t.Fatalf("expected args 'worker', got %v", container.Args)
+	}
+}
+
+// TestGenerateNewServiceWorkingDir ensures that the working directory is set
+// on the container, that of the image being restored when it is removed, and
+// that relative directories are rejected.
+func TestGenerateNewServiceWorkingDir(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", WorkingDir: "/workspace/data"}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := service.Spec.Template.Spec.Containers[0].WorkingDir; dir != "/workspace/data" {
+		t.Fatalf("expected working directory '/workspace/data', got '%v'", dir)
+	}
+
+	f.WorkingDir = ""
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if dir := service.Spec.Template.Spec.Containers[0].WorkingDir; dir != "" {
+		t.Fatalf("expected the image's working directory, got '%v'", dir)
+	}
+
+	f.WorkingDir = "data"
+	if _, err = generateNewService("f", f, nil); err == nil {
+		t.Fatal("expected a relative working directory to be rejected")
+	}
+}
+
+// TestDeployMessageCallback ensures that the progress messages reported while
+// waiting for the Service are passed to the message callback.
+func TestDeployMessageCallback(t *testing.T) {
+	client := knativetest.NewServingClient()
+	client.WaitMessages = []string{"Configuration \"f\" is waiting for a Revision to become ready.", "Ingress has not yet been reconciled."}
+
+	messages := []string{}
+	deployer := &Deployer{client: client}
+	WithMessageCallback(func(_ time.Duration, message string) {
+		messages = append(messages, message)
+	})(deployer)
+
+	if _, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(messages, "|") != strings.Join(client.WaitMessages, "|") {
+		t.Fatalf("expected messages %q, got %q", client.WaitMessages, messages)
+	}
+
+	// The callback is optional.
+	if _, err := (&Deployer{client: client}).Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDeployNoWait ensures that in NoWait mode the service is created and
+// updated without waiting for readiness, and no URL is returned.
+func TestDeployNoWait(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client}
+	WithNoWait(true)(deployer)
+
+	for i := 0; i < 2; i++ { // create, then update
+		result, err := deployer.Deploy(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.URL != "" {
+			t.Fatalf("expected no URL, got '%v'", result.URL)
+		}
+	}
+	if _, ok := client.Services["f"]; !ok {
+		t.Fatal("expected the service to be created")
+	}
+	if len(client.WaitTimeouts) != 0 {
+		t.Fatal("expected WaitForService not to be called")
+	}
+}
+
+// TestDeployClusterLocal ensures that cluster-local Functions are labeled as
+// such, that no external URL is returned for them, and that the label is
+// removed when the Function is again made public.
+func TestDeployClusterLocal(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", ClusterLocal: true}
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client}
+
+	for i := 0; i < 2; i++ { // create, then update
+		result, err := deployer.Deploy(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.URL != "" {
+			t.Fatalf("expected no external URL, got '%v'", result.URL)
+		}
+		if v := client.Services["f"].Labels[serving.VisibilityLabelKey]; v != serving.VisibilityClusterLocal {
+			t.Fatalf("expected visibility label '%v', got '%v'", serving.VisibilityClusterLocal, v)
+		}
+	}
+
+	f.ClusterLocal = false
+	result, err := deployer.Deploy(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.URL == "" {
+		t.Fatal("expected an external URL")
+	}
+	if _, ok := client.Services["f"].Labels[serving.VisibilityLabelKey]; ok {
+		t.Fatal("expected visibility label to be removed")
+	}
+}
+
+// TestDeployCreateRetries ensures that creating the service is retried upon
+// transient errors, up to the configured number of retries, and not at all
+// upon terminal errors.
+func TestDeployCreateRetries(t *testing.T) {
+	defer func(b k8swait.Backoff) { createBackoff = b }(createBackoff)
+	createBackoff = k8swait.Backoff{Duration: time.Millisecond, Factor: 2}
+
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	resource := servingv1.Resource("services")
+	transient := apierrors.NewServerTimeout(resource, "create", 1)
+
+	cases := []struct {
+		name     string
+		retries  int
+		errs     []error
+		creates  int
+		expectOK bool
+	}{
+		{"transient", 0, []error{transient, apierrors.NewConflict(resource, "f", nil)}, 3, true},
+		{"exhausted", 1, []error{transient, transient, transient}, 2, false},
+		{"terminal", 0, []error{apierrors.NewBadRequest("invalid spec")}, 1, false},
+	}
+	for _, c := range cases {
+		client := knativetest.NewServingClient()
+		client.CreateErrs = c.errs
+		deployer := &Deployer{client: client}
+		WithCreateRetries(c.retries)(deployer)
+
+		_, err := deployer.Deploy(f)
+		if c.expectOK && err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if !c.expectOK && err == nil {
+			t.Fatalf("%v: expected deploy to fail", c.name)
+		}
+		if client.Creates != c.creates {
+			t.Fatalf("%v: expected %v create attempts, got %v", c.name, c.creates, client.Creates)
+		}
+	}
+}
+
+// TestDeployCreateRace ensures that a service created concurrently, between
+// finding it absent and creating it, is updated rather than failing.
+func TestDeployCreateRace(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", EnvVars: map[string]string{"A": "1"}}
+
+	existing, err := generateNewService("f", faas.Function{Image: f.Image}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &racingServingClient{knativetest.NewServingClient(), existing}
+	deployer := &Deployer{client: client}
+
+	if _, err = deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if client.Creates != 1 {
+		t.Fatalf("expected a single create attempt, got %v", client.Creates)
+	}
+	assertEnvVar(t, client.Services["f"].Spec.Template.Spec.Containers[0].Env, "A", "1")
+}
+
+// racingServingClient creates the given service as if by another process
+// immediately after reporting it absent.
+type racingServingClient struct {
+	*knativetest.ServingClient
+	service *servingv1.Service
+}
+
+func (c *racingServingClient) GetService(ctx context.Context, name string) (*servingv1.Service, error) {
+	s, err := c.ServingClient.GetService(ctx, name)
+	if apierrors.IsNotFound(err) && c.service != nil {
+		c.Services[name] = c.service
+		c.service = nil
+	}
+	return s, err
+}
+
+// TestDeployContext ensures that a deployment is abandoned promptly once its
+// context is canceled or its deadline passes, even where the API server does
+// not respond, and that the wait for the Service is limited to the deadline.
+func TestDeployContext(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	hung := make(chan struct{})
+	defer close(hung)
+
+	cases := []struct {
+		name     string
+		context  func() (context.Context, context.CancelFunc)
+		expected error
+		kind     error
+	}{
+		{"canceled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled, nil},
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}, context.DeadlineExceeded, ErrDeployTimeout},
+	}
+	for _, c := range cases {
+		ctx, cancel := c.context()
+		deployer := &Deployer{client: knServingClient{hangingKnClient{hung: hung}}}
+		start := time.Now()
+		_, err := deployer.DeployContext(ctx, f)
+		cancel()
+		if !errors.Is(err, c.expected) {
+			t.Fatalf("%v: expected error matching '%v', got '%v'", c.name, c.expected, err)
+		}
+		if c.kind != nil && !errors.Is(err, c.kind) {
+			t.Fatalf("%v: expected error matching '%v', got '%v'", c.name, c.kind, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("%v: expected the deployment to be abandoned promptly, took %v", c.name, elapsed)
+		}
+	}
+
+	// The wait for the Service to become ready is likewise abandoned, the
+	// reason it is not ready being reported where its deadline passes.
+	pending, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending.Status.Conditions = []apis.Condition{{Type: apis.ConditionReady, Status: corev1.ConditionFalse, Reason: "RevisionMissing", Message: "pending"}}
+	for _, c := range cases {
+		ctx, cancel := c.context()
+		deployer := &Deployer{client: hangingWaitClient{knativetest.NewServingClient(pending.DeepCopy()), hung}}
+		start := time.Now()
+		_, err = deployer.DeployContext(ctx, f)
+		cancel()
+		if !errors.Is(err, c.expected) {
+			t.Fatalf("%v: expected the wait to be abandoned with '%v', got '%v'", c.name, c.expected, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("%v: expected the wait to be abandoned promptly, took %v", c.name, elapsed)
+		}
+		if c.kind == nil {
+			if errors.Is(err, ErrServiceNotReady) {
+				t.Fatalf("%v: expected a canceled wait not to be reported as not ready, got '%v'", c.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, c.kind) || !strings.Contains(err.Error(), "RevisionMissing: pending") {
+			t.Fatalf("%v: expected a timeout reporting the reason, got '%v'", c.name, err)
+		}
+	}
+
+	// A creation abandoned once requested may yet be applied, so is reported
+	// as of unknown outcome.
+	for _, c := range cases {
+		ctx, cancel := c.context()
+		deployer := &Deployer{client: knServingClient{hangingCreateKnClient{hung: hung}}}
+		_, err = deployer.DeployContext(ctx, f)
+		cancel()
+		if !errors.Is(err, ErrOutcomeUnknown) || !errors.Is(err, c.expected) {
+			t.Fatalf("%v: expected the creation's outcome to be unknown, got '%v'", c.name, err)
+		}
+	}
+
+	// A context already done deploys nothing.
+	client := knativetest.NewServingClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := (&Deployer{client: client}).DeployContext(ctx, f); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled context to abort the deployment, got '%v'", err)
+	}
+	if client.Creates != 0 {
+		t.Fatalf("expected no service to be created, got %v creates", client.Creates)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := (&Deployer{client: client, WaitTimeout: time.Minute}).DeployContext(ctx, f); err != nil {
+		t.Fatal(err)
+	}
+	if timeout := client.WaitTimeouts[0]; timeout > 10*time.Second {
+		t.Fatalf("expected the wait to be limited to the context's deadline, got %v", timeout)
+	}
+}
+
+// hangingKnClient is a Knative serving client whose requests for Services do
+// not complete until hung is closed, as of an API server which has hung.
+type hangingKnClient struct {
+	clientservingv1.KnServingClient
+	hung chan struct{}
+}
+
+func (c hangingKnClient) GetService(name string) (*servingv1.Service, error) {
+	<-c.hung
+	return nil, errors.New("connection reset")
+}
+
+// hangingCreateKnClient is a Knative serving client of no Services, whose
+// creations do not complete until hung is closed.
+type hangingCreateKnClient struct {
+	clientservingv1.KnServingClient
+	hung chan struct{}
+}
+
+func (c hangingCreateKnClient) GetService(name string) (*servingv1.Service, error) {
+	return nil, apierrors.NewNotFound(servingv1.Resource("services"), name)
+}
+
+func (c hangingCreateKnClient) CreateService(service *servingv1.Service) error {
+	<-c.hung
+	return errors.New("connection reset")
+}
+
+// hangingWaitClient is a serving client whose waits for Services to become
+// ready do not complete until hung is closed.
+type hangingWaitClient struct {
+	*knativetest.ServingClient
+	hung chan struct{}
+}
+
+func (c hangingWaitClient) WaitForService(name string, timeout time.Duration, msgCallback wait.MessageCallback) (error, time.Duration) {
+	<-c.hung
+	return errors.New("timeout: service 'f' not ready"), timeout
+}
+
+// TestDeployFunctionLabel ensures that the Function label is applied on
+// create, migrated from the legacy label on update, and honors an overridden
+// prefix.
+func TestDeployFunctionLabel(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+
+	legacy, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(legacy.Labels, labelKey)
+	legacy.Labels[legacyLabelKey] = labelValue
+
+	client := knativetest.NewServingClient(legacy)
+	deployer := &Deployer{client: client}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	labels := client.Services["f"].Labels
+	if labels[labelKey] != labelValue {
+		t.Fatalf("expected label '%v' on update, got %v", labelKey, labels)
+	}
+	if _, ok := labels[legacyLabelKey]; ok {
+		t.Fatalf("expected legacy label to be removed, got %v", labels)
+	}
+
+	client = knativetest.NewServingClient()
+	deployer = &Deployer{client: client}
+	WithLabelPrefix("example.com")(deployer)
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	labels = client.Services["f"].Labels
+	if labels["example.com/function"] != labelValue {
+		t.Fatalf("expected prefixed label on create, got %v", labels)
+	}
+	if _, ok := labels[labelKey]; ok {
+		t.Fatalf("expected default label to be omitted when prefixed, got %v", labels)
+	}
+
+	deployer.LabelPrefix = "not a domain"
+	if _, err := deployer.Deploy(f); err == nil {
+		t.Fatal("expected an invalid label prefix to error")
+	}
+}
+
+// TestDeployOwnership ensures that an existing Service not labelled as a
+// Function is not modified, whether found prior to deploying or created
+// concurrently, unless it is adopted.
+func TestDeployOwnership(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	foreign := func() *servingv1.Service {
+		service, err := generateNewService("f", faas.Function{Image: "quay.io/bob/other:latest"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.Labels = map[string]string{"app": "other"}
+		return service
+	}
+
+	client := knativetest.NewServingClient(foreign())
+	deployer := &Deployer{client: client}
+	if _, err := deployer.Deploy(f); !errors.Is(err, ErrServiceNotOwned) {
+		t.Fatalf("expected a foreign service to be refused, got '%v'", err)
+	}
+	if client.Updates != 0 {
+		t.Fatalf("expected the foreign service not to be updated, got %v updates", client.Updates)
+	}
+
+	racing := &racingServingClient{knativetest.NewServingClient(), foreign()}
+	deployer = &Deployer{client: racing}
+	if _, err := deployer.Deploy(f); !errors.Is(err, ErrServiceNotOwned) {
+		t.Fatalf("expected a foreign service created concurrently to be refused, got '%v'", err)
+	}
+	if image := racing.Services["f"].Spec.Template.Spec.Containers[0].Image; image != "quay.io/bob/other:latest" {
+		t.Fatalf("expected the foreign service to be unchanged, got image '%v'", image)
+	}
+
+	client = knativetest.NewServingClient(foreign())
+	deployer = &Deployer{client: client}
+	WithAdopt(true)(deployer)
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	service := client.Services["f"]
+	if service.Labels[labelKey] != labelValue {
+		t.Fatalf("expected the adopted service to be labelled as a function, got %v", service.Labels)
+	}
+	if image := service.Spec.Template.Spec.Containers[0].Image; image != f.Image {
+		t.Fatalf("expected the adopted service to be updated, got image '%v'", image)
+	}
+
+	// Once adopted, the Service is owned.
+	deployer.Adopt = false
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUndeploy ensures that the Service of the Function and its Triggers are
+// deleted, waiting for the deletion, and that a Function which is already
+// gone is not an error.
+func TestUndeploy(t *testing.T) {
+	f := faas.Function{Name: "f.example.com", Image: "quay.io/alice/f:latest"}
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	eventing := knativetest.NewEventingClient(
+		trigger("f-example-com-trigger-0", map[string]string{labelKey: labelValue, functionNameLabelKey: "f-example-com"}),
+		trigger("g-trigger-0", map[string]string{labelKey: labelValue, functionNameLabelKey: "g"}),
+	)
+	deployer.eventing = eventing
+
+	removed, err := deployer.Undeploy(f.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !removed || len(client.Deleted) != 1 || client.Deleted[0] != "f-example-com" {
+		t.Fatalf("expected service f-example-com to be removed, got %v", client.Deleted)
+	}
+	if len(eventing.Deleted) != 1 || eventing.Deleted[0] != "f-example-com-trigger-0" {
+		t.Fatalf("expected only the trigger of the function to be removed, got %v", eventing.Deleted)
+	}
+	if client.WaitTimeouts[len(client.WaitTimeouts)-1] != DefaultWaitingTimeout {
+		t.Fatal("expected deletion to be waited upon")
+	}
+
+	removed, err = deployer.Undeploy(f.Name)
+	if err != nil {
+		t.Fatalf("expected undeploying a removed function not to error, got %v", err)
+	}
+	if removed {
+		t.Fatal("expected undeploying a removed function to report nothing removed")
+	}
+}
+
+// forbiddenTriggers is an eventing client without the rights to list Triggers.
+type forbiddenTriggers struct{ *knativetest.EventingClient }
+
+func (forbiddenTriggers) ListFunctionTriggers(serviceName string) (*v1beta1.TriggerList, error) {
+	return nil, apierrors.NewForbidden(v1beta1.Resource("triggers"), "", errors.New("rbac"))
+}
+
+// forbiddenPingSources is a client without the rights to get PingSources.
+type forbiddenPingSources struct{ *knativetest.PingSourceClient }
+
+func (forbiddenPingSources) GetPingSource(name string) (*sourcesv1alpha2.PingSource, error) {
+	return nil, apierrors.NewForbidden(sourcesv1alpha2.Resource("pingsources"), name, errors.New("rbac"))
+}
+
+// forbiddenDomainMappings is a client without the rights to list
+// DomainMappings.
+type forbiddenDomainMappings struct {
+	*knativetest.DomainMappingClient
+}
+
+func (forbiddenDomainMappings) ListDomainMappings(serviceName string) (*unstructured.UnstructuredList, error) {
+	return nil, apierrors.NewForbidden(domainMappingResource.GroupResource(), "", errors.New("rbac"))
+}
+
+// TestDeployResourcesForbidden ensures that a Function without Subscriptions,
+// a schedule or a domain is deployed and undeployed where the user may not
+// access Triggers, PingSources or DomainMappings, and that one with them is
+// not.
+func TestDeployResourcesForbidden(t *testing.T) {
+	deployer := &Deployer{
+		client:         knativetest.NewServingClient(),
+		eventing:       forbiddenTriggers{knativetest.NewEventingClient()},
+		pingSources:    forbiddenPingSources{knativetest.NewPingSourceClient()},
+		domainMappings: forbiddenDomainMappings{knativetest.NewDomainMappingClient()},
+	}
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := deployer.Undeploy(f.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, f := range map[string]faas.Function{
+		"subscriptions": {Name: "f", Image: f.Image, Subscriptions: []faas.Subscription{{Type: "order.created"}}},
+		"schedule":      {Name: "f", Image: f.Image, Schedule: "0 * * * *"},
+		"domain":        {Name: "f", Image: f.Image, Domain: "api.example.com"},
+	} {
+		if _, err := deployer.Deploy(f); err == nil {
+			t.Fatalf("%v: expected deploying to fail without access to its resources", name)
+		}
+	}
+}
+
+// TestUndeployWaitsForDeletion ensures that Undeploy returns only once the
+// Service, found for a time marked for deletion, is gone.
+func TestUndeployWaitsForDeletion(t *testing.T) {
+	client := knativetest.NewServingClient(&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "f"}})
+	client.DeletePolls = 2
+	deployer := &Deployer{client: client}
+
+	removed, err := deployer.Undeploy("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !removed {
+		t.Fatal("expected the function to be reported removed")
+	}
+	if _, ok := client.Services["f"]; ok {
+		t.Fatal("expected the service to be gone once undeployed")
+	}
+
+	client.Services["f"] = &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "f"}}
+	client.WaitForDeletionErr = errors.New("timeout: service 'f' not deleted after 2m0s: marked for deletion")
+	if _, err = deployer.Undeploy("f"); !errors.Is(err, ErrDeployTimeout) {
+		t.Fatalf("expected a deletion timeout, got '%v'", err)
+	}
+}
+
+// TestWaitForDeletion ensures that a Service is polled until not found, and
+// that a timeout reports its last observed status.
+func TestWaitForDeletion(t *testing.T) {
+	defer func(interval time.Duration) { deletionPollInterval = interval }(deletionPollInterval)
+	deletionPollInterval = time.Millisecond
+
+	deleted := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	terminating := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name:              "f",
+		DeletionTimestamp: &deleted,
+		Finalizers:        []string{"example.com/cleanup"},
+	}}
+	polls := 0
+	getService := func(name string) (*servingv1.Service, error) {
+		polls++
+		if polls > 3 {
+			return nil, apierrors.NewNotFound(servingv1.Resource("services"), name)
+		}
+		return terminating, nil
+	}
+	if err := waitForDeletion(getService, "f", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if polls != 4 {
+		t.Fatalf("expected the service to be polled until not found, polled %v times", polls)
+	}
+
+	stuck := func(string) (*servingv1.Service, error) { return terminating, nil }
+	err := waitForDeletion(stuck, "f", 10*time.Millisecond)
+	if err == nil || !strings.HasPrefix(err.Error(), "timeout:") {
+		t.Fatalf("expected a timeout, got '%v'", err)
+	}
+	if !strings.Contains(err.Error(), "2020-01-01T00:00:00Z") || !strings.Contains(err.Error(), "example.com/cleanup") {
+		t.Fatalf("expected the timeout to report the last observed status, got '%v'", err)
+	}
+}
+
+// TestWaitForRevision ensures that a revision is polled until it becomes
+// ready, and that a revision which fails, or is not ready before the timeout,
+// is reported with its failure condition.
+func TestWaitForRevision(t *testing.T) {
+	defer func(interval time.Duration) { revisionPollInterval = interval }(revisionPollInterval)
+	revisionPollInterval = time.Millisecond
+
+	revisionOf := func(status corev1.ConditionStatus) *servingv1.Revision {
+		revision := &servingv1.Revision{ObjectMeta: metav1.ObjectMeta{
+			Name:   "f-00002",
+			Labels: map[string]string{serving.ServiceLabelKey: "f"},
+		}}
+		revision.Status.Conditions = duckv1.Conditions{{
+			Type:    apis.ConditionReady,
+			Status:  status,
+			Reason:  "ContainerMissing",
+			Message: "image not found",
+		}}
+		return revision
+	}
+
+	polls := 0
+	getRevision := func(name string) (*servingv1.Revision, error) {
+		polls++
+		switch polls {
+		case 1:
+			return nil, apierrors.NewNotFound(servingv1.Resource("revisions"), name)
+		case 2, 3:
+			return revisionOf(corev1.ConditionUnknown), nil
+		}
+		return revisionOf(corev1.ConditionTrue), nil
+	}
+	if err := waitForRevision(getRevision, "f", "f-00002", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if polls != 4 {
+		t.Fatalf("expected the revision to be polled until ready, polled %v times", polls)
+	}
+
+	pending := func(string) (*servingv1.Revision, error) { return revisionOf(corev1.ConditionUnknown), nil }
+	err := waitForRevision(pending, "f", "f-00002", 10*time.Millisecond)
+	if err == nil || !strings.HasPrefix(err.Error(), "timeout:") {
+		t.Fatalf("expected a timeout, got '%v'", err)
+	}
+	if !strings.Contains(err.Error(), "ContainerMissing") || !strings.Contains(err.Error(), "image not found") {
+		t.Fatalf("expected the timeout to report the failure condition, got '%v'", err)
+	}
+
+	failed := func(string) (*servingv1.Revision, error) { return revisionOf(corev1.ConditionFalse), nil }
+	err = waitForRevision(failed, "f", "f-00002", time.Minute)
+	if err == nil || strings.HasPrefix(err.Error(), "timeout:") || !strings.Contains(err.Error(), "image not found") {
+		t.Fatalf("expected the failure to be reported without waiting, got '%v'", err)
+	}
+
+	if err = waitForRevision(getRevision, "g", "f-00002", time.Minute); err == nil {
+		t.Fatal("expected a revision of another service to be rejected")
+	}
+}
+
+// TestGenerateNewServiceMetadata ensures that labels and annotations are
+// applied to both the Service and its revision template, that the Function
+// label is always retained, and that updates remove those no longer
+// configured while retaining those not applied by the deployer.
+func TestGenerateNewServiceMetadata(t *testing.T) {
+	f := faas.Function{
+		Image:       "quay.io/alice/f:latest",
+		Labels:      map[string]string{"cost-center": "42", labelKey: "false"},
+		Annotations: map[string]string{"prometheus.io/scrape": "true"},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, labels := range []map[string]string{service.Labels, service.Spec.Template.Labels} {
+		if labels["cost-center"] != "42" {
+			t.Fatalf("expected label cost-center, got %v", labels)
+		}
+	}
+	if service.Labels[labelKey] != labelValue {
+		t.Fatalf("expected the function label to be retained, got %v", service.Labels)
+	}
+	for _, annotations := range []map[string]string{service.Annotations, service.Spec.Template.Annotations} {
+		if annotations["prometheus.io/scrape"] != "true" {
+			t.Fatalf("expected annotation prometheus.io/scrape, got %v", annotations)
+		}
+	}
+
+	// Simulate an annotation applied by the platform.
+	service.Annotations["serving.knative.dev/creator"] = "alice"
+
+	f.Labels = map[string]string{"team": "a"}
+	f.Annotations = nil
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := service.Labels["cost-center"]; ok {
+		t.Fatalf("expected stale label to be removed, got %v", service.Labels)
+	}
+	if _, ok := service.Spec.Template.Labels["cost-center"]; ok {
+		t.Fatalf("expected stale template label to be removed, got %v", service.Spec.Template.Labels)
+	}
+	if service.Labels["team"] != "a" || service.Labels[labelKey] != labelValue {
+		t.Fatalf("unexpected labels after update %v", service.Labels)
+	}
+	if _, ok := service.Annotations["prometheus.io/scrape"]; ok {
+		t.Fatalf("expected stale annotation to be removed, got %v", service.Annotations)
+	}
+	if service.Annotations["serving.knative.dev/creator"] != "alice" {
+		t.Fatalf("expected annotations not applied by the deployer to be retained, got %v", service.Annotations)
+	}
+}
+
+// TestGenerateNewServiceScopedMetadata ensures that labels and annotations
+// scoped to the Service or its revision template are applied to the one
+// alone, overriding those applied to both, and are removed from it when no
+// longer configured.
+func TestGenerateNewServiceScopedMetadata(t *testing.T) {
+	f := faas.Function{
+		Image:               "quay.io/alice/f:latest",
+		Labels:              map[string]string{"team": "a", "tier": "web"},
+		ServiceLabels:       map[string]string{"owner": "alice"},
+		TemplateLabels:      map[string]string{"tier": "backend"},
+		ServiceAnnotations:  map[string]string{"example.com/service": "true"},
+		TemplateAnnotations: map[string]string{"example.com/pod": "true"},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := service.Spec.Template
+	assertAnnotation(t, service.Labels, "team", "a")
+	assertAnnotation(t, template.Labels, "team", "a")
+	assertAnnotation(t, service.Labels, "owner", "alice")
+	assertAnnotation(t, template.Labels, "owner", "")
+	assertAnnotation(t, service.Labels, "tier", "web")
+	assertAnnotation(t, template.Labels, "tier", "backend")
+	assertAnnotation(t, service.Annotations, "example.com/service", "true")
+	assertAnnotation(t, template.Annotations, "example.com/service", "")
+	assertAnnotation(t, service.Annotations, "example.com/pod", "")
+	assertAnnotation(t, template.Annotations, "example.com/pod", "true")
+
+	// Simulate labels applied by the platform, of the same keys as those
+	// applied to the other.
+	service.Spec.Template.Labels["owner"] = "platform"
+	service.Annotations["example.com/pod"] = "platform"
+
+	f.ServiceLabels, f.TemplateLabels = nil, nil
+	f.ServiceAnnotations, f.TemplateAnnotations = nil, nil
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	template = service.Spec.Template
+	assertAnnotation(t, service.Labels, "owner", "")
+	assertAnnotation(t, template.Labels, "owner", "platform")
+	assertAnnotation(t, template.Labels, "tier", "web")
+	assertAnnotation(t, service.Annotations, "example.com/service", "")
+	assertAnnotation(t, service.Annotations, "example.com/pod", "platform")
+	assertAnnotation(t, template.Annotations, "example.com/pod", "")
+}
+
+// TestGenerateNewServiceMeshAnnotations ensures that annotations read from
+// pods, such as that opting out of Istio's sidecar injection, are placed on
+// the revision template, and not the Service, and are removed when no longer
+// configured.
+func TestGenerateNewServiceMeshAnnotations(t *testing.T) {
+	f := faas.Function{
+		Image:       "quay.io/alice/f:latest",
+		Annotations: map[string]string{"sidecar.istio.io/inject": "false", "team": "a"},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, "sidecar.istio.io/inject", "false")
+	assertAnnotation(t, service.Annotations, "sidecar.istio.io/inject", "")
+	assertAnnotation(t, service.Spec.Template.Annotations, "team", "a")
+	assertAnnotation(t, service.Annotations, "team", "a")
+
+	f.Annotations = map[string]string{"team": "a"}
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Spec.Template.Annotations, "sidecar.istio.io/inject", "")
+}
+
+// TestGenerateNewServiceRuntimeLabel ensures that the runtime of the Function
+// is applied as a label, reconciled on update, and omitted when unknown.
+func TestGenerateNewServiceRuntimeLabel(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", Runtime: "go"}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Labels[runtimeLabelKey] != "go" {
+		t.Fatalf("expected runtime label 'go', got %v", service.Labels)
+	}
+
+	f.Runtime = "node"
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if service.Labels[runtimeLabelKey] != "node" {
+		t.Fatalf("expected runtime label 'node' on update, got %v", service.Labels)
+	}
+
+	f.Runtime = ""
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := service.Labels[runtimeLabelKey]; ok {
+		t.Fatalf("expected runtime label to be omitted when unknown, got %v", service.Labels)
+	}
+}
+
+// TestRevisionName ensures that revision names are prefixed with the name of
+// the service if not already, and that invalid names are rejected.
+func TestRevisionName(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+		valid    bool
+	}{
+		{"", "", true},
+		{"abc123", "f-abc123", true},
+		{"f-abc123", "f-abc123", true},
+		{"fabc123", "f-fabc123", true},
+		{"ABC", "", false},
+		{"build_1", "", false},
+		{"build.1", "", false},
+		{strings.Repeat("a", 62), "", false},
+	}
+	for _, c := range cases {
+		name, err := revisionName("f", c.name)
+		if !c.valid {
+			if err == nil {
+				t.Fatalf("expected revision name '%v' to be invalid", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != c.expected {
+			t.Fatalf("expected revision name '%v' to be '%v', got '%v'", c.name, c.expected, name)
+		}
+	}
+
+	f := faas.Function{Image: "quay.io/alice/f:latest", RevisionName: "abc123"}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Spec.Template.Name != "f-abc123" {
+		t.Fatalf("expected revision name 'f-abc123', got '%v'", service.Spec.Template.Name)
+	}
+	f.RevisionName = "def456"
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if service.Spec.Template.Name != "f-def456" {
+		t.Fatalf("expected revision name 'f-def456' on update, got '%v'", service.Spec.Template.Name)
+	}
+}
+
+// TestGenerateNewServiceIngressClass ensures that the ingress class is set as
+// an annotation of the Service, passed through if unrecognized, and removed
+// when no longer configured.
+func TestGenerateNewServiceIngressClass(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", IngressClass: "kourier.ingress.networking.knative.dev"}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, networking.IngressClassAnnotationKey, "kourier.ingress.networking.knative.dev")
+
+	f.IngressClass = "internal.example.com"
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, networking.IngressClassAnnotationKey, "internal.example.com")
+
+	f.IngressClass = ""
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, networking.IngressClassAnnotationKey, "")
+}
+
+// TestGenerateNewServicePort ensures that a configured port is set on the
+// container, and that none is set by default.
+func TestGenerateNewServicePort(t *testing.T) {
+	service, err := generateNewService("f", faas.Function{Image: "quay.io/alice/f:latest"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ports := service.Spec.Template.Spec.Containers[0].Ports; len(ports) != 0 {
+		t.Fatalf("expected no ports by default, got %v", ports)
+	}
+
+	service, err = generateNewService("f", faas.Function{Image: "quay.io/alice/f:latest", Port: 9000}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ports := service.Spec.Template.Spec.Containers[0].Ports; len(ports) != 1 || ports[0].ContainerPort != 9000 {
+		t.Fatalf("expected port 9000, got %v", ports)
+	}
+
+	if !deployInvalid(faas.Function{Image: "quay.io/alice/f:latest", Port: 70000}) {
+		t.Fatal("expected an invalid port to error")
+	}
+}
+
+// TestGenerateNewServiceProtocol ensures that the port of the container
+// serving requests is named by the protocol, declaring the default port where
+// none is configured, and that unknown protocols are rejected.
+func TestGenerateNewServiceProtocol(t *testing.T) {
+	cases := []struct {
+		name      string
+		f         faas.Function
+		container int
+		port      int32
+	}{
+		{"h2c", faas.Function{Protocol: "h2c", Port: 9000}, 0, 9000},
+		{"http1", faas.Function{Protocol: "http1", Port: 9000}, 0, 9000},
+		{"default port", faas.Function{Protocol: "h2c"}, 0, defaultPort},
+		{"sidecar", faas.Function{Protocol: "h2c", Sidecars: []faas.Sidecar{{Image: "quay.io/alice/proxy:latest", Port: 8443}}}, 1, 8443},
+	}
+	for _, c := range cases {
+		c.f.Image = "quay.io/alice/f:latest"
+		service, err := generateNewService("f", c.f, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		ports := service.Spec.Template.Spec.Containers[c.container].Ports
+		if len(ports) != 1 || ports[0].Name != c.f.Protocol || ports[0].ContainerPort != c.port {
+			t.Fatalf("%v: expected port %v named '%v', got %v", c.name, c.port, c.f.Protocol, ports)
+		}
+	}
+
+	f := faas.Function{Image: "quay.io/alice/f:latest", Protocol: "h2c"}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Protocol = ""
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if ports := service.Spec.Template.Spec.Containers[0].Ports; len(ports) != 0 {
+		t.Fatalf("expected the default port to be removed with the protocol, got %v", ports)
+	}
+
+	f.Protocol = "grpc"
+	if _, err = generateNewService("f", f, nil); err == nil {
+		t.Fatal("expected an unknown protocol to error")
+	}
+}
+
+// TestGenerateNewServiceProbes ensures that configured probes are set on the
+// revision's container, and removed on update when no longer configured.
+func TestGenerateNewServiceProbes(t *testing.T) {
+	f := faas.Function{
+		Image:          "quay.io/alice/f:latest",
+		ReadinessProbe: &faas.Probe{Path: "/ready", InitialDelaySeconds: 10, PeriodSeconds: 5},
+		LivenessProbe:  &faas.Probe{Path: "/alive", Port: 8081},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := service.Spec.Template.Spec.Containers[0]
+	readiness := container.ReadinessProbe
+	if readiness == nil || readiness.HTTPGet == nil || readiness.HTTPGet.Path != "/ready" ||
+		readiness.InitialDelaySeconds != 10 || readiness.PeriodSeconds != 5 || readiness.HTTPGet.Port.IntValue() != 0 {
+		t.Fatalf("unexpected readiness probe %v", readiness)
+	}
+	liveness := container.LivenessProbe
+	if liveness == nil || liveness.HTTPGet == nil || liveness.HTTPGet.Path != "/alive" || liveness.HTTPGet.Port.IntValue() != 8081 {
+		t.Fatalf("unexpected liveness probe %v", liveness)
+	}
+
+	f.LivenessProbe = nil
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if service.Spec.Template.Spec.Containers[0].LivenessProbe != nil {
+		t.Fatal("expected the liveness probe to be removed")
+	}
+}
+
+// TestGenerateNewServiceVolumes ensures that Secret and ConfigMap volumes are
+// added to the pod and mounted into the container, that unsupported sources
+// are rejected, and that updates remove volumes no longer configured.
+func TestGenerateNewServiceVolumes(t *testing.T) {
+	f := faas.Function{
+		Image: "quay.io/alice/f:latest",
+		Volumes: []faas.Volume{
+			{Source: "secret:db", Path: "/etc/db"},
+			{Source: "configMap:app-config", Path: "/etc/config"},
+		},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	podSpec := service.Spec.Template.Spec.PodSpec
+	if len(podSpec.Volumes) != 2 || len(podSpec.Containers[0].VolumeMounts) != 2 {
+		t.Fatalf("expected two volumes and mounts, got %v and %v", podSpec.Volumes, podSpec.Containers[0].VolumeMounts)
+	}
+	secret, mount := podSpec.Volumes[0], podSpec.Containers[0].VolumeMounts[0]
+	if secret.Secret == nil || secret.Secret.SecretName != "db" {
+		t.Fatalf("expected a volume of secret 'db', got %v", secret)
+	}
+	if mount.Name != secret.Name || mount.MountPath != "/etc/db" || !mount.ReadOnly {
+		t.Fatalf("unexpected mount of secret 'db' %v", mount)
+	}
+	configMap, mount := podSpec.Volumes[1], podSpec.Containers[0].VolumeMounts[1]
+	if configMap.ConfigMap == nil || configMap.ConfigMap.Name != "app-config" {
+		t.Fatalf("expected a volume of configMap 'app-config', got %v", configMap)
+	}
+	if mount.Name != configMap.Name || mount.MountPath != "/etc/config" {
+		t.Fatalf("unexpected mount of configMap 'app-config' %v", mount)
+	}
+
+	f.Volumes = f.Volumes[1:]
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	podSpec = service.Spec.Template.Spec.PodSpec
+	if len(podSpec.Volumes) != 1 || len(podSpec.Containers[0].VolumeMounts) != 1 || podSpec.Volumes[0].ConfigMap == nil {
+		t.Fatalf("expected only the configMap volume to remain, got %v", podSpec.Volumes)
+	}
+
+	for _, invalid := range []faas.Volume{
+		{Source: "hostPath:/var", Path: "/var"},
+		{Source: "secret", Path: "/etc/db"},
+		{Source: "secret:db", Path: "etc/db"},
+	} {
+		f.Volumes = []faas.Volume{invalid}
+		if _, err = generateNewService("f", f, nil); err == nil {
+			t.Fatalf("expected volume %v to error", invalid)
+		}
+	}
+}
+
+// TestGenerateNewServiceEmptyDir ensures that an emptyDir volume, and its
+// writable mount, appear on the PodSpec with the size limit, which is
+// validated.
+func TestGenerateNewServiceEmptyDir(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", Volumes: []faas.Volume{{Source: "emptyDir", Path: "/tmp/scratch", Size: "500Mi"}}}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	podSpec := service.Spec.Template.Spec.PodSpec
+	if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].EmptyDir == nil {
+		t.Fatalf("expected an emptyDir volume, got %+v", podSpec.Volumes)
+	}
+	if limit := podSpec.Volumes[0].EmptyDir.SizeLimit; limit == nil || limit.String() != "500Mi" {
+		t.Fatalf("expected a size limit of 500Mi, got %v", limit)
+	}
+	mounts := podSpec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].MountPath != "/tmp/scratch" || mounts[0].ReadOnly || mounts[0].Name != podSpec.Volumes[0].Name {
+		t.Fatalf("expected a writable mount at /tmp/scratch, got %+v", mounts)
+	}
+
+	for _, invalid := range []faas.Volume{
+		{Source: "emptyDir", Path: "/tmp/scratch", Size: "lots"},
+		{Source: "emptyDir", Path: "/tmp/scratch", Size: "-1Gi"},
+		{Source: "secret:db", Path: "/etc/db", Size: "1Mi"},
+	} {
+		f.Volumes = []faas.Volume{invalid}
+		if _, err = generateNewService("f", f, nil); err == nil {
+			t.Fatalf("expected volume %v to error", invalid)
+		}
+	}
+}
+
+// TestGenerateNewServiceServiceAccount ensures that the service account name
+// is set on the revision, and left unset by default.
+func TestGenerateNewServiceServiceAccount(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest"}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := service.Spec.Template.Spec.ServiceAccountName; name != "" {
+		t.Fatalf("expected no service account by default, got '%v'", name)
+	}
+
+	f.ServiceAccountName = "function-identity"
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if name := service.Spec.Template.Spec.ServiceAccountName; name != "function-identity" {
+		t.Fatalf("expected service account 'function-identity', got '%v'", name)
+	}
+}
+
+// TestGenerateNewServiceImagePullSecrets ensures that pull secrets are set on
+// the revision both at creation and update.
+func TestGenerateNewServiceImagePullSecrets(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", ImagePullSecrets: []string{"quay", "docker"}}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := service.Spec.Template.Spec.ImagePullSecrets
+	if len(secrets) != 2 || secrets[0].Name != "quay" || secrets[1].Name != "docker" {
+		t.Fatalf("expected pull secrets quay and docker, got %v", secrets)
+	}
+
+	f.ImagePullSecrets = []string{"docker"}
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	secrets = service.Spec.Template.Spec.ImagePullSecrets
+	if len(secrets) != 1 || secrets[0].Name != "docker" {
+		t.Fatalf("expected only pull secret docker, got %v", secrets)
+	}
+}
@@ -1,9 +1,13 @@
 package knative
 
 import (
+	"context"
+	"sort"
+	"time"
+
 	"k8s.io/apimachinery/pkg/api/errors"
-	v1 "knative.dev/client/pkg/serving/v1"
 	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	"knative.dev/pkg/apis"
 
 	"github.com/boson-project/faas"
 	"github.com/boson-project/faas/k8s"
@@ -12,6 +16,21 @@ import (
 type Describer struct {
 	Verbose   bool
 	namespace string
+
+	// clients to use in place of those constructed for the namespace.
+	client   ServingClient
+	eventing eventingClient
+}
+
+// eventingClient is the subset of the Knative eventing client used by the
+// Describer, Deployer and Remover.
+type eventingClient interface {
+	ListTriggers() (*v1beta1.TriggerList, error)
+	ListFunctionTriggers(serviceName string) (*v1beta1.TriggerList, error)
+	CreateTrigger(trigger *v1beta1.Trigger) error
+	UpdateTrigger(trigger *v1beta1.Trigger) error
+	DeleteTrigger(name string) error
+	GetBroker(name string) (*v1beta1.Broker, error)
 }
 
 func NewDescriber(namespaceOverride string) (describer *Describer, err error) {
@@ -29,6 +48,8 @@ func NewDescriber(namespaceOverride string) (describer *Describer, err error) {
 // restricts to label-syntax, which is thus escaped. Therefore as a knative (kube) implementation
 // detal proper full names have to be escaped on the way in and unescaped on the way out. ex:
 // www.example-site.com -> www-example--site-com
+//
+// A Function which is not deployed results in a NotFoundError.
 func (d *Describer) Describe(name string) (description faas.Description, err error) {
 
 	serviceName, err := k8s.ToK8sAllowedName(name)
@@ -36,28 +57,31 @@ func (d *Describer) Describe(name string) (description faas.Description, err err
 		return
 	}
 
-	servingClient, err := NewServingClient(d.namespace)
+	servingClient, err := d.servingClient()
 	if err != nil {
 		return
 	}
 
-	eventingClient, err := NewEventingClient(d.namespace)
+	eventingClient, err := d.eventingClient()
 	if err != nil {
 		return
 	}
 
-	service, err := servingClient.GetService(serviceName)
+	service, err := servingClient.GetService(context.Background(), serviceName)
+	if errors.IsNotFound(err) {
+		return description, &NotFoundError{Name: name}
+	}
 	if err != nil {
 		return
 	}
 
-	routes, err := servingClient.ListRoutes(v1.WithService(serviceName))
-	if err != nil {
+	// The route may not yet exist if the Service has just been created.
+	route, err := servingClient.GetRoute(context.Background(), serviceName)
+	if err != nil && !errors.IsNotFound(err) {
 		return
 	}
-
-	routeURLs := make([]string, 0, len(routes.Items))
-	for _, route := range routes.Items {
+	routeURLs := []string{}
+	if err == nil && route.Status.URL != nil {
 		routeURLs = append(routeURLs, route.Status.URL.String())
 	}
 
@@ -66,6 +90,7 @@ func (d *Describer) Describe(name string) (description faas.Description, err err
 	if err != nil && !errors.IsNotFound(err) {
 		return
 	}
+	err = nil
 
 	triggerMatches := func(t *v1beta1.Trigger) bool {
 		return (t.Spec.Subscriber.Ref != nil && t.Spec.Subscriber.Ref.Name == service.Name) ||
@@ -74,22 +99,167 @@ func (d *Describer) Describe(name string) (description faas.Description, err err
 
 	}
 
-	subscriptions := make([]faas.Subscription, 0, len(triggers.Items))
-	for _, trigger := range triggers.Items {
-		if triggerMatches(&trigger) {
-			filterAttrs := trigger.Spec.Filter.Attributes
-			subscription := faas.Subscription{
-				Source: filterAttrs["source"],
-				Type:   filterAttrs["type"],
-				Broker: trigger.Spec.Broker,
+	subscriptions := make([]faas.Subscription, 0)
+	if triggers != nil {
+		for _, trigger := range triggers.Items {
+			if triggerMatches(&trigger) {
+				filterAttrs := trigger.Spec.Filter.Attributes
+				subscription := faas.Subscription{
+					Source: filterAttrs["source"],
+					Type:   filterAttrs["type"],
+					Broker: trigger.Spec.Broker,
+				}
+				for name, value := range filterAttrs {
+					if name == "source" || name == "type" {
+						continue
+					}
+					if subscription.Attributes == nil {
+						subscription.Attributes = map[string]string{}
+					}
+					subscription.Attributes[name] = value
+				}
+				subscriptions = append(subscriptions, subscription)
 			}
-			subscriptions = append(subscriptions, subscription)
 		}
 	}
 
+	if service.Status.URL != nil {
+		description.URL = service.Status.URL.String()
+	}
+	if ready := service.Status.GetCondition(apis.ConditionReady); ready != nil {
+		description.Ready = ready.IsTrue()
+		if !description.Ready {
+			description.Message = ready.Message
+		}
+	}
+	description.EnvVars = []faas.EnvVar{}
+	description.LatestRevision = service.Status.LatestCreatedRevisionName
+	description.ReadyRevision = service.Status.LatestReadyRevisionName
+	if len(service.Spec.Template.Spec.Containers) > 0 {
+		for _, e := range service.Spec.Template.Spec.Containers[0].Env {
+			if e.ValueFrom == nil {
+				description.EnvVars = append(description.EnvVars, faas.EnvVar{Name: e.Name, Value: e.Value})
+			}
+		}
+		sort.Slice(description.EnvVars, func(i, j int) bool {
+			return description.EnvVars[i].Name < description.EnvVars[j].Name
+		})
+	}
+
 	description.Routes = routeURLs
 	description.Subscriptions = subscriptions
 	description.Name, err = k8s.FromK8sAllowedName(service.Name)
 
 	return
 }
+
+// Status of a deployed Function, being that of its live Service.  Its JSON
+// field names are stable, for scripting.
+type Status struct {
+	// Name of the Function.
+	Name string `json:"name"`
+	// Generation of the Service's spec, and that most recently observed by
+	// Knative, which lags while an update is reconciled.
+	Generation         int64 `json:"generation"`
+	ObservedGeneration int64 `json:"observedGeneration"`
+	// LatestCreatedRevision and LatestReadyRevision of the Service, which
+	// differ while the latest is becoming ready, or if it failed to.
+	LatestCreatedRevision string `json:"latestCreatedRevision"`
+	LatestReadyRevision   string `json:"latestReadyRevision"`
+	// Conditions of the Service, such as Ready, ConfigurationsReady and
+	// RoutesReady, ordered by type.
+	Conditions []Condition `json:"conditions"`
+}
+
+// Condition of a Service.
+type Condition struct {
+	// Type of the condition, such as Ready.
+	Type string `json:"type"`
+	// Status of the condition: True, False or Unknown.
+	Status string `json:"status"`
+	// Reason and Message explaining the last transition of the condition,
+	// if any.
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime of the condition, the zero time if unknown.
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// Condition of the given type, or nil if the Service has none such.
+func (s Status) Condition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// Ready reports whether the Service has been reconciled and is ready, its
+// Ready condition being true of its current generation.
+func (s Status) Ready() bool {
+	c := s.Condition(string(apis.ConditionReady))
+	return c != nil && c.Status == "True" && s.ObservedGeneration == s.Generation
+}
+
+// Status of the named Function, its live Service's conditions.  A Function
+// which is not deployed results in a NotFoundError.
+func (d *Describer) Status(name string) (status Status, err error) {
+	serviceName, err := k8s.ToK8sAllowedName(name)
+	if err != nil {
+		return
+	}
+	servingClient, err := d.servingClient()
+	if err != nil {
+		return
+	}
+	service, err := servingClient.GetService(context.Background(), serviceName)
+	if errors.IsNotFound(err) {
+		return status, &NotFoundError{Name: name}
+	}
+	if err != nil {
+		return
+	}
+
+	status = Status{
+		Name:                  name,
+		Generation:            service.Generation,
+		ObservedGeneration:    service.Status.ObservedGeneration,
+		LatestCreatedRevision: service.Status.LatestCreatedRevisionName,
+		LatestReadyRevision:   service.Status.LatestReadyRevisionName,
+		Conditions:            []Condition{},
+	}
+	for _, c := range service.Status.Conditions {
+		status.Conditions = append(status.Conditions, Condition{
+			Type:               string(c.Type),
+			Status:             string(c.Status),
+			Reason:             c.Reason,
+			Message:            c.Message,
+			LastTransitionTime: c.LastTransitionTime.Inner.Time,
+		})
+	}
+	sort.Slice(status.Conditions, func(i, j int) bool {
+		return status.Conditions[i].Type < status.Conditions[j].Type
+	})
+	return
+}
+
+// servingClient returns the client to use for the Describer's namespace.
+func (d *Describer) servingClient() (ServingClient, error) {
+	if d.client != nil {
+		return d.client, nil
+	}
+	client, err := NewServingClient(d.namespace)
+	if err != nil {
+		return nil, err
+	}
+	return knServingClient{client}, nil
+}
+
+// eventingClient returns the client to use for the Describer's namespace.
+func (d *Describer) eventingClient() (eventingClient, error) {
+	if d.eventing != nil {
+		return d.eventing, nil
+	}
+	return newEventingClient(d.namespace)
+}
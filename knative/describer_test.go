@@ -0,0 +1,180 @@
+package knative
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDescribe ensures that the URL, readiness, revisions and environment of
+// a deployed Function are described, and that a Function which is not
+// deployed results in a NotFoundError.
+func TestDescribe(t *testing.T) {
+	f := faas.Function{
+		Image:   "quay.io/alice/f:latest",
+		EnvVars: map[string]string{"B": "2", "A": "1", "S": "{{ secret:s:k }}"},
+	}
+	service, err := generateNewService("f-example-com", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Status.URL = &apis.URL{Scheme: "http", Host: "f-example-com.ns.example.com"}
+	service.Status.LatestCreatedRevisionName = "f-example-com-00002"
+	service.Status.LatestReadyRevisionName = "f-example-com-00001"
+	service.Status.Conditions = duckv1.Conditions{
+		{Type: apis.ConditionReady, Status: corev1.ConditionFalse, Message: "Revision failed"},
+	}
+
+	describer := &Describer{client: knativetest.NewServingClient(service), eventing: knativetest.NewEventingClient()}
+	description, err := describer.Describe("f.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.Name != "f.example.com" {
+		t.Fatalf("unexpected name '%v'", description.Name)
+	}
+	if description.URL != "http://f-example-com.ns.example.com" {
+		t.Fatalf("unexpected URL '%v'", description.URL)
+	}
+	if description.Ready || description.Message != "Revision failed" {
+		t.Fatalf("expected not ready with message, got %v '%v'", description.Ready, description.Message)
+	}
+	if description.LatestRevision != "f-example-com-00002" || description.ReadyRevision != "f-example-com-00001" {
+		t.Fatalf("unexpected revisions '%v', '%v'", description.LatestRevision, description.ReadyRevision)
+	}
+	expected := []faas.EnvVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}}
+	if len(description.EnvVars) != len(expected) {
+		t.Fatalf("expected env vars %v, got %v", expected, description.EnvVars)
+	}
+	for i := range expected {
+		if description.EnvVars[i] != expected[i] {
+			t.Fatalf("expected env vars %v, got %v", expected, description.EnvVars)
+		}
+	}
+	if len(description.Routes) != 1 || description.Routes[0] != "http://f-example-com.example.com" {
+		t.Fatalf("unexpected routes %v", description.Routes)
+	}
+
+	_, err = describer.Describe("missing")
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Fatalf("expected a NotFoundError, got %v", err)
+	}
+}
+
+// TestStatus ensures that the conditions of the live Service are reported,
+// with their reasons and messages, and that it is ready only once its
+// Ready condition is true of its current generation.
+func TestStatus(t *testing.T) {
+	cases := []struct {
+		name       string
+		conditions duckv1.Conditions
+		observed   int64
+		ready      bool
+	}{
+		{"ready", duckv1.Conditions{
+			{Type: apis.ConditionReady, Status: corev1.ConditionTrue},
+			{Type: "ConfigurationsReady", Status: corev1.ConditionTrue},
+			{Type: "RoutesReady", Status: corev1.ConditionTrue},
+		}, 2, true},
+		{"not ready", duckv1.Conditions{
+			{Type: apis.ConditionReady, Status: corev1.ConditionFalse, Reason: "RevisionFailed", Message: "Revision failed"},
+			{Type: "RoutesReady", Status: corev1.ConditionTrue},
+			{Type: "ConfigurationsReady", Status: corev1.ConditionFalse, Reason: "RevisionFailed", Message: "Revision failed"},
+		}, 2, false},
+		{"stale", duckv1.Conditions{
+			{Type: apis.ConditionReady, Status: corev1.ConditionTrue},
+		}, 1, false},
+	}
+	for _, c := range cases {
+		service, err := generateNewService("f", faas.Function{Image: "quay.io/alice/f:latest"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.Generation = 2
+		service.Status.ObservedGeneration = c.observed
+		service.Status.LatestCreatedRevisionName = "f-00002"
+		service.Status.LatestReadyRevisionName = "f-00001"
+		service.Status.Conditions = c.conditions
+
+		describer := &Describer{client: knativetest.NewServingClient(service)}
+		status, err := describer.Status("f")
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if status.Ready() != c.ready {
+			t.Fatalf("%v: expected ready %v, got %v", c.name, c.ready, status.Ready())
+		}
+		if len(status.Conditions) != len(c.conditions) {
+			t.Fatalf("%v: expected %v conditions, got %v", c.name, len(c.conditions), status.Conditions)
+		}
+		for i := 1; i < len(status.Conditions); i++ {
+			if status.Conditions[i-1].Type > status.Conditions[i].Type {
+				t.Fatalf("%v: expected conditions ordered by type, got %v", c.name, status.Conditions)
+			}
+		}
+		if status.LatestCreatedRevision != "f-00002" || status.LatestReadyRevision != "f-00001" {
+			t.Fatalf("%v: unexpected revisions %+v", c.name, status)
+		}
+		if c.name == "not ready" {
+			configurations := status.Condition("ConfigurationsReady")
+			if configurations == nil || configurations.Status != "False" || configurations.Reason != "RevisionFailed" || configurations.Message != "Revision failed" {
+				t.Fatalf("expected the failed condition's reason and message, got %+v", configurations)
+			}
+		}
+	}
+
+	_, err := (&Describer{client: knativetest.NewServingClient()}).Status("missing")
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Fatalf("expected a NotFoundError, got %v", err)
+	}
+}
+
+// TestStatusJSON ensures that the JSON of a Function's status is of stable
+// field names, and that of a Function described without environment
+// variables has an empty list of them rather than null.
+func TestStatusJSON(t *testing.T) {
+	service, err := generateNewService("f", faas.Function{Image: "quay.io/alice/f:latest"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Generation = 1
+	service.Status.ObservedGeneration = 1
+	service.Status.LatestCreatedRevisionName = "f-00001"
+	service.Status.LatestReadyRevisionName = "f-00001"
+	service.Status.Conditions = duckv1.Conditions{{
+		Type:               apis.ConditionReady,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))},
+	}}
+	describer := &Describer{client: knativetest.NewServingClient(service), eventing: knativetest.NewEventingClient()}
+
+	status, err := describer.Status("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"name":"f","generation":1,"observedGeneration":1,"latestCreatedRevision":"f-00001","latestReadyRevision":"f-00001",` +
+		`"conditions":[{"type":"Ready","status":"True","lastTransitionTime":"2020-01-01T00:00:00Z"}]}`
+	if string(b) != expected {
+		t.Fatalf("expected JSON %v, got %v", expected, string(b))
+	}
+
+	description, err := describer.Describe("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = json.Marshal(description.EnvVars); err != nil || string(b) != "[]" {
+		t.Fatalf("expected an empty list of env vars, got %v (%v)", string(b), err)
+	}
+}
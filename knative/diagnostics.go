@@ -0,0 +1,101 @@
+package knative
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/apis"
+	"knative.dev/serving/pkg/apis/serving"
+)
+
+// diagnosticsTimeout limits the gathering of the reason a Service failed to
+// become ready.
+const diagnosticsTimeout = 10 * time.Second
+
+// readinessFailure describes why the named Service's latest revision did not
+// become ready, preferring the state of its pods' containers (such as
+// ImagePullBackOff or CrashLoopBackOff) over the revision's conditions.
+// An empty string is returned if no reason could be determined.
+func (d *Deployer) readinessFailure(ctx context.Context, client ServingClient, serviceName string) string {
+	service, err := client.GetService(ctx, serviceName)
+	if err != nil {
+		return ""
+	}
+	revisionName := service.Status.LatestCreatedRevisionName
+	if revisionName == "" {
+		return conditionFailure(service.Status.Conditions)
+	}
+
+	if coreClient, err := d.kubernetesClient(); err == nil {
+		if reason := podFailure(coreClient, d.Namespace, revisionName); reason != "" {
+			return fmt.Sprintf("revision '%v': %v", revisionName, reason)
+		}
+	}
+
+	revision, err := client.GetRevision(revisionName)
+	if err != nil {
+		return conditionFailure(service.Status.Conditions)
+	}
+	if reason := conditionFailure(revision.Status.Conditions); reason != "" {
+		return fmt.Sprintf("revision '%v': %v", revisionName, reason)
+	}
+	return ""
+}
+
+// conditionFailure returns the reason and message of the most recently
+// transitioned condition which is not true.
+func conditionFailure(conditions []apis.Condition) string {
+	var latest *apis.Condition
+	for i := range conditions {
+		c := &conditions[i]
+		if c.IsTrue() || c.Message == "" {
+			continue
+		}
+		if latest == nil || c.LastTransitionTime.Inner.After(latest.LastTransitionTime.Inner.Time) {
+			latest = c
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	if latest.Reason == "" {
+		return latest.Message
+	}
+	return fmt.Sprintf("%v: %v", latest.Reason, latest.Message)
+}
+
+// podFailure returns the reason a container of the named revision's pods is
+// waiting or last terminated, if any.
+func podFailure(client kubernetes.Interface, namespace, revisionName string) string {
+	pods, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%v=%v", serving.RevisionLabelKey, revisionName),
+	})
+	if err != nil {
+		return ""
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if reason := containerFailure(status); reason != "" {
+				return fmt.Sprintf("container '%v': %v", status.Name, reason)
+			}
+		}
+	}
+	return ""
+}
+
+func containerFailure(status corev1.ContainerStatus) string {
+	if w := status.State.Waiting; w != nil && w.Reason != "" && w.Reason != "ContainerCreating" {
+		if w.Message == "" {
+			return w.Reason
+		}
+		return fmt.Sprintf("%v: %v", w.Reason, w.Message)
+	}
+	if t := status.LastTerminationState.Terminated; t != nil && t.ExitCode != 0 {
+		return fmt.Sprintf("%v: exit code %v", t.Reason, t.ExitCode)
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package knative
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDeployReadinessFailure ensures that when the service does not become
+// ready the reason given by its latest revision, or preferably by the
+// revision's pods, is included in the returned error.
+func TestDeployReadinessFailure(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Status.LatestCreatedRevisionName = "f-00001"
+	revision := &servingv1.Revision{ObjectMeta: metav1.ObjectMeta{Name: "f-00001"}}
+	revision.Status.Conditions = duckv1.Conditions{
+		{Type: apis.ConditionReady, Status: corev1.ConditionFalse, Reason: "ContainerMissing", Message: "Unable to fetch image"},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "f-00001-deployment-abc",
+		Namespace: "ns",
+		Labels:    map[string]string{serving.RevisionLabelKey: "f-00001"},
+	}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name: "user-container",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+			Reason: "ImagePullBackOff", Message: "Back-off pulling image",
+		}},
+	}}
+
+	cases := []struct {
+		name     string
+		pods     []*corev1.Pod
+		expected string
+	}{
+		{"revision condition", nil, "ContainerMissing: Unable to fetch image"},
+		{"pod container state", []*corev1.Pod{pod}, "ImagePullBackOff: Back-off pulling image"},
+	}
+	for _, c := range cases {
+		client := knativetest.NewServingClient(service.DeepCopy())
+		client.Revisions[revision.Name] = revision
+		client.WaitErr = errors.New("timeout")
+
+		coreClient := fake.NewSimpleClientset()
+		for _, p := range c.pods {
+			if _, err := coreClient.CoreV1().Pods("ns").Create(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		deployer := &Deployer{Namespace: "ns", client: client, coreClient: coreClient}
+		if _, err = deployer.Deploy(f); err == nil {
+			t.Fatalf("%v: expected deploy to fail", c.name)
+		}
+		if !strings.Contains(err.Error(), c.expected) {
+			t.Fatalf("%v: expected error to contain '%v', got '%v'", c.name, c.expected, err)
+		}
+	}
+}
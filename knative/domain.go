@@ -0,0 +1,196 @@
+package knative
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// domainMappingResource is that of Knative Serving's DomainMappings, which
+// are served by releases newer than that of the typed clients used here, so
+// are managed as unstructured resources.
+var domainMappingResource = schema.GroupVersionResource{
+	Group:    serving.GroupName,
+	Version:  "v1alpha1",
+	Resource: "domainmappings",
+}
+
+// domainMappingClient is the subset of a client of DomainMappings used to
+// map custom domains to Functions.
+type domainMappingClient interface {
+	GetDomainMapping(name string) (*unstructured.Unstructured, error)
+	CreateDomainMapping(mapping *unstructured.Unstructured) error
+	UpdateDomainMapping(mapping *unstructured.Unstructured) error
+	DeleteDomainMapping(name string) error
+	ListDomainMappings(serviceName string) (*unstructured.UnstructuredList, error)
+}
+
+// dynamicDomainMappings is a domainMappingClient of a namespace's
+// DomainMappings using the dynamic client.
+type dynamicDomainMappings struct {
+	resource dynamic.ResourceInterface
+}
+
+// newDomainMappingClient of the namespace.
+func newDomainMappingClient(namespace string, options ...ClientOption) (domainMappingClient, error) {
+	client, err := NewDynamicClient(options...)
+	if err != nil {
+		return nil, err
+	}
+	return dynamicDomainMappings{client.Resource(domainMappingResource).Namespace(namespace)}, nil
+}
+
+func (c dynamicDomainMappings) GetDomainMapping(name string) (*unstructured.Unstructured, error) {
+	return c.resource.Get(name, metav1.GetOptions{})
+}
+
+func (c dynamicDomainMappings) CreateDomainMapping(mapping *unstructured.Unstructured) error {
+	_, err := c.resource.Create(mapping, metav1.CreateOptions{})
+	return err
+}
+
+func (c dynamicDomainMappings) UpdateDomainMapping(mapping *unstructured.Unstructured) error {
+	_, err := c.resource.Update(mapping, metav1.UpdateOptions{})
+	return err
+}
+
+func (c dynamicDomainMappings) DeleteDomainMapping(name string) error {
+	return c.resource.Delete(name, &metav1.DeleteOptions{})
+}
+
+// ListDomainMappings labeled as belonging to the Function of the given
+// Service name.
+func (c dynamicDomainMappings) ListDomainMappings(serviceName string) (*unstructured.UnstructuredList, error) {
+	return c.resource.List(metav1.ListOptions{LabelSelector: functionSelector(serviceName)})
+}
+
+// validateDomain ensures the domain, if any, is a fully qualified hostname,
+// and that a TLS secret is configured only with a domain.
+func validateDomain(domain, tlsSecret string) error {
+	if domain == "" {
+		if tlsSecret != "" {
+			return fmt.Errorf("domain tls secret '%v' requires a domain", tlsSecret)
+		}
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+		return fmt.Errorf("invalid domain '%v': %v", domain, strings.Join(errs, ","))
+	}
+	if !strings.Contains(domain, ".") {
+		return fmt.Errorf("invalid domain '%v': must be a fully qualified hostname, such as api.example.com", domain)
+	}
+	if tlsSecret != "" {
+		if errs := validation.IsDNS1123Subdomain(tlsSecret); len(errs) > 0 {
+			return fmt.Errorf("invalid domain tls secret '%v': %v", tlsSecret, strings.Join(errs, ","))
+		}
+	}
+	return nil
+}
+
+// checkDomainTLSSecret ensures the Secret with which the domain is served
+// over TLS exists in the namespace, and is of a TLS certificate and key.
+func checkDomainTLSSecret(client kubernetes.Interface, namespace, name string) error {
+	secret, err := client.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return &DeployError{Kind: ErrNotFound, Err: fmt.Errorf("domain tls secret '%v' not found in namespace '%v'", name, namespace)}
+	}
+	if err != nil {
+		return newDeployError("knative deployer failed to get the domain tls secret", err)
+	}
+	if secret.Type != corev1.SecretTypeTLS {
+		return &DeployError{Kind: ErrServiceInvalid, Err: fmt.Errorf("domain tls secret '%v' is of type '%v', expected '%v'", name, secret.Type, corev1.SecretTypeTLS)}
+	}
+	return nil
+}
+
+// generateDomainMapping returns the DomainMapping of the domain to the
+// Function's Service, labeled as belonging to the Function.  DomainMappings
+// are named by the domain they map.
+func generateDomainMapping(namespace, serviceName, domain, tlsSecret string) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"ref": map[string]interface{}{
+			"apiVersion": servingv1.SchemeGroupVersion.String(),
+			"kind":       "Service",
+			"name":       serviceName,
+			"namespace":  namespace,
+		},
+	}
+	if tlsSecret != "" {
+		spec["tls"] = map[string]interface{}{"secretName": tlsSecret}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": domainMappingResource.GroupVersion().String(),
+		"kind":       "DomainMapping",
+		"metadata": map[string]interface{}{
+			"name":      domain,
+			"namespace": namespace,
+			"labels": map[string]interface{}{
+				labelKey:             labelValue,
+				functionNameLabelKey: serviceName,
+			},
+		},
+		"spec": spec,
+	}}
+}
+
+// reconcileDomainMapping creates or updates the DomainMapping of the
+// Function's domain, deleting any others belonging to the Function, such as
+// that of a domain since changed.  A DomainMapping of the domain which does
+// not belong to the Function is not modified.  Without a domain,
+// DomainMappings which are unavailable are taken to be none.
+func reconcileDomainMapping(client domainMappingClient, namespace, serviceName, domain, tlsSecret string) error {
+	mappings, err := client.ListDomainMappings(serviceName)
+	if err != nil {
+		if unavailable(err) && domain == "" {
+			// DomainMappings are not served, or may not be accessed, so
+			// there are none to remove.
+			return nil
+		}
+		if errors.IsNotFound(err) {
+			return &DeployError{Kind: ErrNotFound, Err: fmt.Errorf("knative deployer failed to map domain '%v': the cluster does not serve DomainMappings (%v)", domain, domainMappingResource)}
+		}
+		return newDeployError("knative deployer failed to list the domain mappings", err)
+	}
+	for _, m := range mappings.Items {
+		if m.GetName() == domain || !ownedBy(m.GetLabels(), serviceName) {
+			continue
+		}
+		if err = client.DeleteDomainMapping(m.GetName()); err != nil && !errors.IsNotFound(err) {
+			return newDeployError("knative deployer failed to delete the domain mapping", err)
+		}
+	}
+	if domain == "" {
+		return nil
+	}
+
+	desired := generateDomainMapping(namespace, serviceName, domain, tlsSecret)
+	current, err := client.GetDomainMapping(domain)
+	if errors.IsNotFound(err) {
+		if err = client.CreateDomainMapping(desired); err != nil {
+			return newDeployError("knative deployer failed to create the domain mapping", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return newDeployError("knative deployer failed to get the domain mapping", err)
+	}
+	if !ownedBy(current.GetLabels(), serviceName) {
+		return &DeployError{Kind: ErrServiceNotOwned, Err: fmt.Errorf("knative deployer failed to map domain '%v': its domain mapping does not belong to the function", domain)}
+	}
+	current.SetLabels(desired.GetLabels())
+	current.Object["spec"] = desired.Object["spec"]
+	if err = client.UpdateDomainMapping(current); err != nil {
+		return newDeployError("knative deployer failed to update the domain mapping", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package knative
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDeployDomainMapping ensures that a DomainMapping of the Function's
+// domain referencing its Service is created, that it is replaced when the
+// domain changes, and deleted when the domain is removed.
+func TestDeployDomainMapping(t *testing.T) {
+	mappings := knativetest.NewDomainMappingClient()
+	deployer := &Deployer{Namespace: "ns", client: knativetest.NewServingClient(), domainMappings: mappings}
+	f := faas.Function{Name: "www.example.com", Image: "quay.io/alice/f:latest", Domain: "api.example.com"}
+
+	// Create
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	mapping, ok := mappings.DomainMappings["api.example.com"]
+	if !ok {
+		t.Fatal("expected domain mapping api.example.com to be created")
+	}
+	ref, _, _ := unstructured.NestedStringMap(mapping.Object, "spec", "ref")
+	if ref["apiVersion"] != "serving.knative.dev/v1" || ref["kind"] != "Service" || ref["name"] != "www-example-com" || ref["namespace"] != "ns" {
+		t.Fatalf("expected the domain mapping to reference service ns/www-example-com, got %v", ref)
+	}
+	if mapping.GetNamespace() != "ns" || !ownedBy(mapping.GetLabels(), "www-example-com") {
+		t.Fatalf("expected the domain mapping to belong to the function in ns, got %v %v", mapping.GetNamespace(), mapping.GetLabels())
+	}
+
+	// Domain change
+	f.Domain = "v2.api.example.com"
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mappings.DomainMappings["v2.api.example.com"]; !ok {
+		t.Fatal("expected domain mapping v2.api.example.com to be created")
+	}
+	if _, ok := mappings.DomainMappings["api.example.com"]; ok {
+		t.Fatal("expected the domain mapping of the previous domain to be deleted")
+	}
+
+	// Removal
+	f.Domain = ""
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(mappings.DomainMappings) != 0 {
+		t.Fatalf("expected the domain mapping to be deleted, got %v", len(mappings.DomainMappings))
+	}
+}
+
+// TestDeployDomainTLSSecret ensures that the TLS secret of the domain must
+// exist and be of a certificate, and that it is referenced by the mapping.
+func TestDeployDomainTLSSecret(t *testing.T) {
+	secret := func(name string, kind corev1.SecretType) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}, Type: kind}
+	}
+	coreClient := fake.NewSimpleClientset(secret("api-tls", corev1.SecretTypeTLS), secret("opaque", corev1.SecretTypeOpaque))
+
+	cases := []struct {
+		Secret string
+		Err    error
+	}{
+		{"api-tls", nil},
+		{"missing", ErrNotFound},
+		{"opaque", ErrServiceInvalid},
+		{"Not_A_Name", ErrServiceInvalid},
+	}
+	for _, c := range cases {
+		mappings := knativetest.NewDomainMappingClient()
+		serving := knativetest.NewServingClient()
+		deployer := &Deployer{Namespace: "ns", client: serving, coreClient: coreClient, domainMappings: mappings}
+		f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Domain: "api.example.com", DomainTLSSecret: c.Secret}
+
+		_, err := deployer.Deploy(f)
+		if c.Err != nil {
+			if !errors.Is(err, c.Err) {
+				t.Fatalf("%v: expected '%v', got '%v'", c.Secret, c.Err, err)
+			}
+			if len(serving.Services) != 0 || len(mappings.DomainMappings) != 0 {
+				t.Fatalf("%v: expected nothing to be deployed", c.Secret)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, _, _ := unstructured.NestedString(mappings.DomainMappings["api.example.com"].Object, "spec", "tls", "secretName")
+		if name != c.Secret {
+			t.Fatalf("expected the domain mapping to use tls secret '%v', got '%v'", c.Secret, name)
+		}
+	}
+}
+
+// TestValidateDomain ensures that domains must be fully qualified hostnames,
+// and that a TLS secret requires a domain.
+func TestValidateDomain(t *testing.T) {
+	cases := []struct {
+		Domain    string
+		TLSSecret string
+		Valid     bool
+	}{
+		{"", "", true},
+		{"api.example.com", "", true},
+		{"api.example.com", "api-tls", true},
+		{"localhost", "", false},
+		{"API.example.com", "", false},
+		{"api.example.com/v1", "", false},
+		{"", "api-tls", false},
+	}
+	for _, c := range cases {
+		if err := validateDomain(c.Domain, c.TLSSecret); (err == nil) != c.Valid {
+			t.Fatalf("domain '%v' with tls secret '%v': expected valid %v, got '%v'", c.Domain, c.TLSSecret, c.Valid, err)
+		}
+	}
+}
+
+// TestDeployDomainMappingConflict ensures that a DomainMapping of the domain
+// which does not belong to the Function is not modified.
+func TestDeployDomainMappingConflict(t *testing.T) {
+	other := generateDomainMapping("ns", "other", "api.example.com", "")
+	mappings := knativetest.NewDomainMappingClient(other)
+	deployer := &Deployer{Namespace: "ns", client: knativetest.NewServingClient(), domainMappings: mappings}
+
+	_, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Domain: "api.example.com"})
+	if !errors.Is(err, ErrServiceNotOwned) {
+		t.Fatalf("expected a domain mapping not belonging to the function to be an error, got '%v'", err)
+	}
+	if ref, _, _ := unstructured.NestedString(mappings.DomainMappings["api.example.com"].Object, "spec", "ref", "name"); ref != "other" {
+		t.Fatalf("expected the unrelated domain mapping not to be modified, got reference to '%v'", ref)
+	}
+}
+
+// TestUndeployDomainMapping ensures that the DomainMapping of the Function is
+// removed on undeploy.
+func TestUndeployDomainMapping(t *testing.T) {
+	mappings := knativetest.NewDomainMappingClient()
+	deployer := &Deployer{client: knativetest.NewServingClient(), domainMappings: mappings}
+
+	if _, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Domain: "api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := deployer.Undeploy("f"); err != nil {
+		t.Fatal(err)
+	}
+	if len(mappings.Deleted) != 1 || mappings.Deleted[0] != "api.example.com" {
+		t.Fatalf("expected domain mapping api.example.com to be deleted, got %v", mappings.Deleted)
+	}
+}
@@ -0,0 +1,229 @@
+package knative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// Errors categorizing the failures of the Deployer, against which those it
+// returns may be matched using errors.Is.
+var (
+	// ErrServiceInvalid indicates that the Function does not describe a valid
+	// Service, either as validated prior to deployment or as rejected by the
+	// cluster.
+	ErrServiceInvalid = errors.New("invalid service")
+
+	// ErrNotFound indicates that the Function, or a resource on which its
+	// deployment depends, was not found.
+	ErrNotFound = errors.New("not found")
+
+	// ErrDeployTimeout indicates that the Service did not become ready, or was
+	// not deleted, within the Deployer's WaitTimeout, or that the deadline of
+	// the deployment's context passed.
+	ErrDeployTimeout = errors.New("timed out waiting for the service to become ready")
+
+	// ErrServiceNotReady indicates that the Service failed to become ready,
+	// such as when its revision's container can not be started.
+	ErrServiceNotReady = errors.New("service failed to become ready")
+
+	// ErrClusterUnreachable indicates that the cluster could not be reached.
+	ErrClusterUnreachable = errors.New("cluster unreachable")
+
+	// ErrServiceNotOwned indicates that a Service of the Function's name
+	// exists but is not that of a Function, so was not modified.
+	ErrServiceNotOwned = errors.New("service not owned by a function")
+
+	// ErrHookFailed indicates that a pre-deploy or post-deploy hook of the
+	// Deployer failed.
+	ErrHookFailed = errors.New("deploy hook failed")
+
+	// ErrOutcomeUnknown indicates that the creation or update of the Service
+	// was abandoned once the deployment's context was done, but may yet be
+	// applied, the request having been sent.
+	ErrOutcomeUnknown = errors.New("outcome of the deployment unknown")
+)
+
+// DeployError is returned by the Deployer for failures of an operation,
+// retaining both the underlying cause and its category.  Its message is that
+// of the operation and cause, such that it remains human-readable.
+type DeployError struct {
+	// Kind of failure, one of the Err* errors of this package, or nil if the
+	// failure is not categorized.
+	Kind error
+
+	// Op is a description of the operation which failed, if any.
+	Op string
+
+	// Err is the underlying cause.
+	Err error
+}
+
+// newDeployError of the given operation, categorized by its cause.
+func newDeployError(op string, err error) *DeployError {
+	return &DeployError{Kind: kindOf(err), Op: op, Err: err}
+}
+
+func (e *DeployError) Error() string {
+	if e.Op == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %v", e.Op, e.Err)
+}
+
+func (e *DeployError) Unwrap() error {
+	return e.Err
+}
+
+// Is the error of the given Kind.
+func (e *DeployError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// kindOf returns the category of the error, or nil if it is unknown.
+func kindOf(err error) error {
+	var deployErr *DeployError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &deployErr) && deployErr.Kind != nil:
+		return deployErr.Kind
+	case errors.Is(err, context.DeadlineExceeded):
+		// Matched prior to net.Error, which it implements.
+		return ErrDeployTimeout
+	case errors.Is(err, context.Canceled):
+		return nil
+	case apierrors.IsNotFound(err):
+		return ErrNotFound
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err), denied(err):
+		return ErrServiceInvalid
+	case apierrors.IsServiceUnavailable(err), errors.As(err, &netErr):
+		return ErrClusterUnreachable
+	case strings.Contains(err.Error(), "no route to host"):
+		// Flattened to a message by the Knative client.
+		return ErrClusterUnreachable
+	}
+	return nil
+}
+
+// unavailable reports whether the error is that of resources which the cluster
+// does not serve, or which the user may not access, such as those of Eventing
+// where the user's rights cover only Serving.
+func unavailable(err error) bool {
+	return apierrors.IsNotFound(err) || apierrors.IsForbidden(err) || meta.IsNoMatchError(err)
+}
+
+// denied reports whether the request was rejected as invalid by an admission
+// webhook, whose denials bear a status code but no reason.
+func denied(err error) bool {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return false
+	}
+	code := status.Status().Code
+	return code == http.StatusBadRequest || code == http.StatusUnprocessableEntity
+}
+
+// AdmissionError is the rejection of a Service by an admission webhook of the
+// cluster, such as that validating Knative Services, reformatted from the raw
+// denial to name the offending fields.
+type AdmissionError struct {
+	// Webhook which denied the request.
+	Webhook string
+	// Message of the webhook, less the paths of the offending fields.
+	Message string
+	// Fields of the Service to which the webhook objected, if it named any.
+	Fields []string
+	// Err is the underlying denial.
+	Err error
+}
+
+func (e *AdmissionError) Error() string {
+	guidance := fmt.Sprintf("the service was rejected by the admission webhook '%v'; check that the features it uses are enabled by the cluster's feature flags (ConfigMap %v/%v)", e.Webhook, servingSystemNamespace, featuresConfigMap)
+	if len(e.Fields) == 0 {
+		return fmt.Sprintf("%v: %v", guidance, e.Message)
+	}
+	return fmt.Sprintf("%v: field(s) %v: %v", guidance, strings.Join(e.Fields, ", "), e.Message)
+}
+
+func (e *AdmissionError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// admissionDenialPattern is the message of an admission webhook's denial,
+	// capturing the webhook and its message.
+	admissionDenialPattern = regexp.MustCompile(`(?s)admission webhook "([^"]+)" denied the request: (.*)$`)
+	// fieldPathPattern is the path of a field of a resource, as suffixed to
+	// the errors of a validating webhook.
+	fieldPathPattern = regexp.MustCompile(`^(spec|metadata)(\.[-\w\[\]]+)*$`)
+)
+
+// admissionDenial returns the error as an AdmissionError if it is an admission
+// webhook's denial, or nil otherwise.  Each line of the webhook's message is an
+// error, of which field paths are those of the form "<message>: <path>, ...".
+func admissionDenial(err error) *AdmissionError {
+	if !denied(err) {
+		return nil
+	}
+	m := admissionDenialPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return nil
+	}
+	e := &AdmissionError{Webhook: m[1], Err: err}
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(m[2]), "\n") {
+		if i := strings.LastIndex(line, ": "); i >= 0 {
+			if paths, ok := fieldPaths(line[i+2:]); ok {
+				e.Fields = append(e.Fields, paths...)
+				line = line[:i]
+			}
+		}
+		messages = append(messages, line)
+	}
+	e.Message = strings.Join(messages, "; ")
+	return e
+}
+
+// fieldPaths of a comma-separated list, if each is the path of a field.
+func fieldPaths(list string) ([]string, bool) {
+	paths := strings.Split(list, ", ")
+	for _, path := range paths {
+		if !fieldPathPattern.MatchString(path) {
+			return nil, false
+		}
+	}
+	return paths, true
+}
+
+// waitError categorizes a failure waiting for the Service to become ready.
+// The Knative client reports timeouts only by their message.
+func waitError(err error) *DeployError {
+	e := newDeployError("knative deployer failed to wait for the service to become ready", err)
+	if strings.HasPrefix(err.Error(), "timeout:") {
+		e.Kind = ErrDeployTimeout
+	} else if e.Kind == nil && !errors.Is(err, context.Canceled) {
+		e.Kind = ErrServiceNotReady
+	}
+	return e
+}
+
+// NotFoundError indicates that the named Function is not deployed.
+type NotFoundError struct {
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("function '%v' not found", e.Name)
+}
+
+// Is ErrNotFound.
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
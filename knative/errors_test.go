@@ -0,0 +1,153 @@
+package knative
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDeployErrors ensures that each failure path of Deploy returns an error
+// matching its category with errors.Is, retaining both the underlying cause
+// and a human-readable message.
+func TestDeployErrors(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	refused := &url.Error{Op: "Post", URL: "https://cluster.example.com", Err: errors.New("connection refused")}
+
+	cases := []struct {
+		name       string
+		function   faas.Function
+		createErrs []error
+		waitErr    error
+		expected   error
+		message    string
+	}{
+		{"invalid function", faas.Function{Name: "f"}, nil, nil, ErrServiceInvalid, "function has no image"},
+		{"invalid name", faas.Function{Name: "F", Image: f.Image}, nil, nil, ErrServiceInvalid, ""},
+		{"rejected", f, []error{apierrors.NewBadRequest("invalid spec")}, nil, ErrServiceInvalid, "knative deployer failed to deploy the service"},
+		{"unreachable", f, []error{refused}, nil, ErrClusterUnreachable, "connection refused"},
+		{"timeout", f, nil, errors.New("timeout: service 'f' not ready after 60 seconds"), ErrDeployTimeout, "not ready after 60 seconds"},
+		{"not ready", f, nil, errors.New("RevisionFailed: container exited"), ErrServiceNotReady, "container exited"},
+	}
+	for _, c := range cases {
+		client := knativetest.NewServingClient()
+		client.CreateErrs = c.createErrs
+		client.WaitErr = c.waitErr
+		deployer := &Deployer{client: client}
+
+		_, err := deployer.Deploy(c.function)
+		if !errors.Is(err, c.expected) {
+			t.Fatalf("%v: expected error matching '%v', got '%v'", c.name, c.expected, err)
+		}
+		if !strings.Contains(err.Error(), c.message) {
+			t.Fatalf("%v: expected error message to contain '%v', got '%v'", c.name, c.message, err)
+		}
+		var deployErr *DeployError
+		if !errors.As(err, &deployErr) {
+			t.Fatalf("%v: expected a DeployError, got %T", c.name, err)
+		}
+	}
+}
+
+// TestDeployErrorCause ensures that the underlying cause of a DeployError may
+// be inspected.
+func TestDeployErrorCause(t *testing.T) {
+	client := knativetest.NewServingClient()
+	client.CreateErrs = []error{apierrors.NewBadRequest("invalid spec")}
+	deployer := &Deployer{client: client}
+
+	_, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest"})
+	var statusErr *apierrors.StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected the cause to be a StatusError, got '%v'", err)
+	}
+	if !apierrors.IsBadRequest(statusErr) {
+		t.Fatalf("expected a bad request cause, got '%v'", statusErr)
+	}
+}
+
+// TestNotFoundError ensures that errors of Functions not found match
+// ErrNotFound, as do those of the Deployer caused by a resource not found.
+func TestNotFoundError(t *testing.T) {
+	if !errors.Is(&NotFoundError{Name: "f"}, ErrNotFound) {
+		t.Fatal("expected NotFoundError to match ErrNotFound")
+	}
+	err := newDeployError("knative deployer failed to get the route",
+		apierrors.NewNotFound(servingv1.Resource("routes"), "f"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected '%v' to match ErrNotFound", err)
+	}
+	if errors.Is(err, ErrServiceInvalid) {
+		t.Fatalf("expected '%v' not to match ErrServiceInvalid", err)
+	}
+}
+
+// TestDeployAdmissionDenied ensures that the denial of a Service by an
+// admission webhook is reformatted to name the webhook, the offending fields
+// and the webhook's message, with guidance to check the cluster's features.
+func TestDeployAdmissionDenied(t *testing.T) {
+	cases := []struct {
+		name    string
+		message string
+		fields  []string
+		denial  string
+	}{
+		{"single field",
+			"admission webhook \"validation.webhook.serving.knative.dev\" denied the request: validation failed: must not set the field(s): spec.template.spec.containers[0].securityContext.readOnlyRootFilesystem",
+			[]string{"spec.template.spec.containers[0].securityContext.readOnlyRootFilesystem"},
+			"validation failed: must not set the field(s)"},
+		{"multiple errors",
+			"admission webhook \"validation.webhook.serving.knative.dev\" denied the request: validation failed: must not set the field(s): spec.template.spec.hostAliases, spec.template.spec.priorityClassName\ninvalid value: -1: spec.template.spec.timeoutSeconds",
+			[]string{"spec.template.spec.hostAliases", "spec.template.spec.priorityClassName", "spec.template.spec.timeoutSeconds"},
+			"validation failed: must not set the field(s); invalid value: -1"},
+		{"no fields",
+			"admission webhook \"policy.example.com\" denied the request: images must be signed",
+			nil,
+			"images must be signed"},
+	}
+	for _, c := range cases {
+		client := knativetest.NewServingClient()
+		client.CreateErrs = []error{&apierrors.StatusError{ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    400,
+			Message: c.message,
+		}}}
+		deployer := &Deployer{client: client}
+
+		_, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest"})
+		if !errors.Is(err, ErrServiceInvalid) {
+			t.Fatalf("%v: expected the service to be invalid, got '%v'", c.name, err)
+		}
+		var denial *AdmissionError
+		if !errors.As(err, &denial) {
+			t.Fatalf("%v: expected an AdmissionError, got '%v'", c.name, err)
+		}
+		if !reflect.DeepEqual(denial.Fields, c.fields) {
+			t.Fatalf("%v: expected fields %v, got %v", c.name, c.fields, denial.Fields)
+		}
+		if denial.Message != c.denial {
+			t.Fatalf("%v: expected message '%v', got '%v'", c.name, c.denial, denial.Message)
+		}
+		expected := "knative deployer failed to deploy the service: the service was rejected by the admission webhook"
+		if !strings.HasPrefix(err.Error(), expected) || !strings.Contains(err.Error(), "knative-serving/config-features") {
+			t.Fatalf("%v: expected guidance to check the cluster's features, got '%v'", c.name, err)
+		}
+		for _, field := range c.fields {
+			if !strings.Contains(err.Error(), field) {
+				t.Fatalf("%v: expected the error to name the field '%v', got '%v'", c.name, field, err)
+			}
+		}
+		var statusErr *apierrors.StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("%v: expected the cause to remain a StatusError, got '%v'", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package knative
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// EventType is the stage of a deployment to which an Event pertains.
+type EventType string
+
+const (
+	// EventCreating is emitted prior to creating a new Service.
+	EventCreating EventType = "Creating"
+	// EventUpdating is emitted prior to updating an existing Service.
+	EventUpdating EventType = "Updating"
+	// EventUnchanged is emitted in place of EventUpdating when the existing
+	// Service is already as configured, such that it is not updated.
+	EventUnchanged EventType = "Unchanged"
+	// EventWaiting is emitted when waiting for the Service to become ready.
+	EventWaiting EventType = "Waiting"
+	// EventReady is emitted once the Service is ready.
+	EventReady EventType = "Ready"
+	// EventFailed is emitted when the deployment fails, with the error as
+	// its Message.
+	EventFailed EventType = "Failed"
+	// EventWarning is emitted for each warning of a deployment, such as of
+	// an unrecognized ingress class, with the warning as its Message.
+	EventWarning EventType = "Warning"
+)
+
+// Event describes the progress of a deployment.
+type Event struct {
+	Type    EventType
+	Service string
+	Time    time.Time
+	Message string
+}
+
+// emit an Event of the given type to the Deployer's callback, if any.
+func (d *Deployer) emit(t EventType, service, message string) {
+	if d.OnEvent == nil {
+		return
+	}
+	d.OnEvent(Event{Type: t, Service: service, Time: now(), Message: message})
+}
+
+// warn of a problem which does not prevent the deployment, emitting it as an
+// EventWarning and writing it to the Deployer's Warnings.
+func (d *Deployer) warn(service, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	d.emit(EventWarning, service, message)
+	fmt.Fprintf(d.warnings(), "Warning: %v\n", message)
+}
+
+// warnings returns the writer of warnings, being os.Stderr if not set.
+func (d *Deployer) warnings() io.Writer {
+	if d.Warnings == nil {
+		return os.Stderr
+	}
+	return d.Warnings
+}
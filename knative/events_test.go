@@ -0,0 +1,94 @@
+package knative
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDeployEvents ensures that the expected sequence of events is emitted
+// for a create, an update and a failed deployment.
+func TestDeployEvents(t *testing.T) {
+	f := faas.Function{Name: "f.example.com", Image: "quay.io/alice/f:latest"}
+	client := knativetest.NewServingClient()
+
+	var events []Event
+	// Forced, such that redeploying the unchanged Function updates it.
+	deployer := &Deployer{client: client, Force: true}
+	WithEventCallback(func(e Event) { events = append(events, e) })(deployer)
+
+	cases := []struct {
+		name     string
+		waitErr  error
+		expected []EventType
+	}{
+		{"create", nil, []EventType{EventCreating, EventWaiting, EventReady}},
+		{"update", nil, []EventType{EventUpdating, EventWaiting, EventReady}},
+		{"failure", errors.New("timeout"), []EventType{EventUpdating, EventWaiting, EventFailed}},
+	}
+	for _, c := range cases {
+		events = nil
+		client.WaitErr = c.waitErr
+		_, err := deployer.Deploy(f)
+		if c.waitErr == nil && err != nil {
+			t.Fatal(err)
+		}
+
+		types := []EventType{}
+		for _, e := range events {
+			if e.Service != "f-example-com" {
+				t.Fatalf("%v: unexpected service '%v'", c.name, e.Service)
+			}
+			if e.Time.IsZero() {
+				t.Fatalf("%v: expected event to be timestamped", c.name)
+			}
+			types = append(types, e.Type)
+		}
+		if !reflect.DeepEqual(types, c.expected) {
+			t.Fatalf("%v: expected events %v, got %v", c.name, c.expected, types)
+		}
+		if c.waitErr != nil && events[len(events)-1].Message == "" {
+			t.Fatalf("%v: expected the failure to carry the error", c.name)
+		}
+	}
+}
+
+// TestDeployWarnings ensures that the warnings of a deployment are emitted as
+// events and written to the Deployer's Warnings in place of os.Stderr.
+func TestDeployWarnings(t *testing.T) {
+	var warnings bytes.Buffer
+	var events []string
+	deployer := &Deployer{client: knativetest.NewServingClient(), Warnings: &warnings}
+	WithEventCallback(func(e Event) {
+		if e.Type == EventWarning {
+			events = append(events, e.Message)
+		}
+	})(deployer)
+
+	_, err := deployer.Deploy(faas.Function{
+		Name:         "f",
+		Image:        "quay.io/alice/f:latest",
+		IngressClass: "gateway.example.com",
+		ScaleClass:   "autoscaler.example.com",
+		MaxBodySize:  "1M",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"ingress class 'gateway.example.com' is not known to limit the size of requests, so may not honour the boson.dev/max-body-size annotation",
+		"unrecognized ingress class 'gateway.example.com'",
+		"unrecognized autoscaler class 'autoscaler.example.com'",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("expected warning events %v, got %v", expected, events)
+	}
+	if written := strings.Split(strings.TrimSuffix(warnings.String(), "\n"), "\n"); len(written) != len(expected) || written[1] != "Warning: "+expected[1] {
+		t.Fatalf("expected the warnings to be written, got %q", warnings.String())
+	}
+}
@@ -0,0 +1,208 @@
+package knative
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+)
+
+const (
+	// servingSystemNamespace in which Knative Serving is installed.
+	servingSystemNamespace = "knative-serving"
+
+	// featuresConfigMap of Knative Serving, holding its feature flags.
+	featuresConfigMap = "config-features"
+)
+
+// feature of Knative Serving which must be enabled for a Function to use the
+// configuration which it gates.
+type feature struct {
+	// flag of the feature in the features ConfigMap, or empty if its use is
+	// not permitted by this version of Knative Serving regardless of flags.
+	flag string
+	// use of the feature, as described to the user.
+	use string
+	// field of the PodSpec named when the cluster rejects its use.
+	field string
+	// enabled by default, when the flag is absent.
+	enabled bool
+}
+
+var (
+	initContainersFeature = feature{"kubernetes.podspec-init-containers", "init containers", "initContainers", false}
+	multiContainerFeature = feature{"multi-container", "sidecars", "containers", true}
+	nodeSelectorFeature   = feature{"kubernetes.podspec-nodeselector", "node selectors", "nodeSelector", false}
+	tolerationsFeature    = feature{"kubernetes.podspec-tolerations", "tolerations", "tolerations", false}
+	affinityFeature       = feature{"kubernetes.podspec-affinity", "node affinity", "affinity", false}
+	emptyDirFeature       = feature{"kubernetes.podspec-volumes-emptydir", "emptyDir volumes", "emptyDir", false}
+	runAsNonRootFeature   = feature{"kubernetes.podspec-securitycontext", "runAsNonRoot security contexts", "runAsNonRoot", false}
+	readOnlyRootFeature   = feature{"", "readOnlyRootFilesystem security contexts", "readOnlyRootFilesystem", false}
+	capabilitiesFeature   = feature{"", "dropped capabilities", "capabilities", false}
+)
+
+// featuresOf the Function, being those it uses, in order of the precedence of
+// their fields when matched against a rejection.
+func featuresOf(f faas.Function) (features []feature) {
+	if len(f.InitContainers) > 0 {
+		features = append(features, initContainersFeature)
+	}
+	if len(f.NodeSelector) > 0 {
+		features = append(features, nodeSelectorFeature)
+	}
+	if len(f.Tolerations) > 0 {
+		features = append(features, tolerationsFeature)
+	}
+	if len(f.NodeAffinity) > 0 {
+		features = append(features, affinityFeature)
+	}
+	for _, v := range f.Volumes {
+		if v.Source == emptyDirSource {
+			features = append(features, emptyDirFeature)
+			break
+		}
+	}
+	if sc := f.SecurityContext; sc != nil {
+		if sc.RunAsNonRoot != nil {
+			features = append(features, runAsNonRootFeature)
+		}
+		if sc.ReadOnlyRootFilesystem != nil {
+			features = append(features, readOnlyRootFeature)
+		}
+		if len(sc.DropCapabilities) > 0 {
+			features = append(features, capabilitiesFeature)
+		}
+	}
+	// "containers" is also a suffix of initContainers, so is matched last.
+	if len(f.Sidecars) > 0 {
+		features = append(features, multiContainerFeature)
+	}
+	return
+}
+
+// checkFeatures ensures that the features used by the Function are not
+// disabled on the cluster, failing prior to deployment with an error naming
+// the flag to enable.  Where the feature flags can not be read, such as for
+// lack of permission to the Knative Serving namespace, they are not checked,
+// and the cluster's rejection is instead explained by explainRejection.
+func (d *Deployer) checkFeatures(f faas.Function) error {
+	features := featuresOf(f)
+	if len(features) == 0 || d.SkipPreflight {
+		return nil
+	}
+	client, err := d.kubernetesClient()
+	if err != nil {
+		return nil
+	}
+	config, err := client.CoreV1().ConfigMaps(servingSystemNamespace).Get(featuresConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	for _, feature := range features {
+		if feature.flag == "" {
+			continue
+		}
+		value, ok := config.Data[feature.flag]
+		if (ok && strings.EqualFold(value, "disabled")) || (!ok && !feature.enabled) {
+			return &DeployError{Kind: ErrServiceInvalid, Err: fmt.Errorf("%v require that the Knative Serving feature '%v' is enabled in the ConfigMap %v/%v, but it is disabled", feature.use, feature.flag, servingSystemNamespace, featuresConfigMap)}
+		}
+	}
+	return nil
+}
+
+// explainRejection adds to the error of a Service rejected by the cluster
+// the likely cause, where the Function uses a feature which Knative Serving
+// permits only when enabled.  The denials of admission webhooks are first
+// reformatted as an AdmissionError.
+func explainRejection(f faas.Function, err *DeployError) *DeployError {
+	if err.Kind != ErrServiceInvalid {
+		return err
+	}
+	if denial := admissionDenial(err.Err); denial != nil {
+		err.Err = denial
+	}
+	msg := err.Err.Error()
+	for _, feature := range featuresOf(f) {
+		if !strings.Contains(msg, feature.field) {
+			continue
+		}
+		if feature.flag == "" {
+			err.Err = fmt.Errorf("%w (%v are not permitted by the cluster's version of Knative Serving)", err.Err, feature.use)
+		} else {
+			err.Err = fmt.Errorf("%w (%v require that the Knative Serving feature '%v' is enabled)", err.Err, feature.use, feature.flag)
+		}
+		break
+	}
+	return err
+}
+
+// resourceFeature of Knative Serving which, where the cluster's flag for it
+// is "Allowed", is enabled for a single resource by annotating it.
+type resourceFeature struct {
+	// annotation enabling the feature.
+	annotation string
+	// value of the annotation which enables it.
+	value string
+	// template is set where the revision template is annotated, rather than
+	// the Service.
+	template bool
+}
+
+// resourceFeatures by the names with which Functions enable them.
+var resourceFeatures = map[string]resourceFeature{
+	"podspec-dryrun":     {"features.knative.dev/podspec-dryrun", "enabled", false},
+	"queueproxy-podinfo": {"features.knative.dev/queueproxy-podinfo", "enabled", true},
+	"http-full-duplex":   {"features.knative.dev/http-full-duplex", "Enabled", true},
+}
+
+// updateFeatures annotates the Service, or its revision template, to enable
+// each of the Function's features, removing the annotations of those no longer
+// enabled unless configured as annotations of the Function.
+func updateFeatures(service *servingv1.Service, f faas.Function) error {
+	if err := validateFeatures(f.Features); err != nil {
+		return err
+	}
+	enabled := map[string]bool{}
+	for _, name := range f.Features {
+		enabled[name] = true
+	}
+	for name, feature := range resourceFeatures {
+		annotations := &service.Annotations
+		configured := scopedMetadata(f.Annotations, f.ServiceAnnotations)
+		if feature.template {
+			annotations = &service.Spec.Template.Annotations
+			configured = scopedMetadata(f.Annotations, f.TemplateAnnotations)
+		}
+		if !enabled[name] {
+			if _, ok := configured[feature.annotation]; !ok {
+				delete(*annotations, feature.annotation)
+			}
+			continue
+		}
+		if *annotations == nil {
+			*annotations = map[string]string{}
+		}
+		(*annotations)[feature.annotation] = feature.value
+	}
+	return nil
+}
+
+// validateFeatures ensures each feature is one which may be enabled per
+// resource.
+func validateFeatures(features []string) error {
+	for _, name := range features {
+		if _, ok := resourceFeatures[name]; !ok {
+			names := make([]string, 0, len(resourceFeatures))
+			for name := range resourceFeatures {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown feature '%v', must be one of %v", name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
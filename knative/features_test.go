@@ -0,0 +1,44 @@
+package knative
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/boson-project/faas"
+)
+
+// TestGenerateNewServiceFeatures ensures that each feature of the Function is
+// applied as the annotation enabling it, on the Service or its revision
+// template as the feature requires, that unknown features error, and that the
+// annotations of features no longer enabled are removed on update unless
+// configured as annotations of the Function.
+func TestGenerateNewServiceFeatures(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", Features: []string{"podspec-dryrun", "queueproxy-podinfo"}}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, "features.knative.dev/podspec-dryrun", "enabled")
+	assertAnnotation(t, service.Spec.Template.Annotations, "features.knative.dev/podspec-dryrun", "")
+	assertAnnotation(t, service.Spec.Template.Annotations, "features.knative.dev/queueproxy-podinfo", "enabled")
+	assertAnnotation(t, service.Annotations, "features.knative.dev/queueproxy-podinfo", "")
+	assertAnnotation(t, service.Spec.Template.Annotations, "features.knative.dev/http-full-duplex", "")
+
+	f.Features = []string{"http-full-duplex"}
+	f.TemplateAnnotations = map[string]string{"features.knative.dev/queueproxy-podinfo": "disabled"}
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, "features.knative.dev/podspec-dryrun", "")
+	assertAnnotation(t, service.Spec.Template.Annotations, "features.knative.dev/queueproxy-podinfo", "disabled")
+	assertAnnotation(t, service.Spec.Template.Annotations, "features.knative.dev/http-full-duplex", "Enabled")
+
+	f.Features = []string{"multi-container"}
+	_, err = generateNewService("f", f, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown feature 'multi-container'") {
+		t.Fatalf("expected an unknown feature to error, got '%v'", err)
+	}
+	if err = (&Deployer{}).validate(f); err == nil {
+		t.Fatal("expected validation to reject an unknown feature")
+	}
+}
@@ -0,0 +1,58 @@
+package knative
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/boson-project/faas"
+)
+
+// DeployHook is invoked by the Deployer at a stage of each deployment with the
+// Function, as deployed, and the result thus far, such as for running a
+// database migration before the Service is applied or a smoke test once it is
+// ready.  An error fails the deployment unless the Deployer reports hook
+// errors.
+type DeployHook func(ctx context.Context, f faas.Function, result faas.DeploymentResult) error
+
+// WithPreDeploy sets the hook invoked prior to creating or updating the
+// Service, once the Function is validated.  The result bears only the name
+// and namespace of the Service.
+func WithPreDeploy(hook DeployHook) DeployerOption {
+	return func(d *Deployer) {
+		d.PreDeploy = hook
+	}
+}
+
+// WithPostDeploy sets the hook invoked once the Service is ready, with the
+// result of the deployment.
+func WithPostDeploy(hook DeployHook) DeployerOption {
+	return func(d *Deployer) {
+		d.PostDeploy = hook
+	}
+}
+
+// WithReportHookErrors reports the errors of deploy hooks as warnings rather
+// than failing the deployment.
+func WithReportHookErrors(report bool) DeployerOption {
+	return func(d *Deployer) {
+		d.ReportHookErrors = report
+	}
+}
+
+// runHook of the given stage, if any.  Its error is categorized as
+// ErrHookFailed, or is reported as a warning if the Deployer reports hook
+// errors.
+func (d *Deployer) runHook(ctx context.Context, stage string, hook DeployHook, f faas.Function, result faas.DeploymentResult) error {
+	if hook == nil {
+		return nil
+	}
+	err := hook(ctx, f, result)
+	if err == nil {
+		return nil
+	}
+	if d.ReportHookErrors {
+		d.warn(result.Name, "%v hook failed: %v", stage, err)
+		return nil
+	}
+	return &DeployError{Kind: ErrHookFailed, Op: fmt.Sprintf("knative deployer %v hook failed", stage), Err: err}
+}
@@ -0,0 +1,89 @@
+package knative
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDeployHooks ensures that the pre-deploy hook is invoked before the
+// Service is applied, and the post-deploy hook once it is ready, each with the
+// Function and the result thus far.
+func TestDeployHooks(t *testing.T) {
+	client := knativetest.NewServingClient()
+	var calls []string
+	deployer := &Deployer{client: client, Namespace: "ns", OnEvent: func(e Event) {
+		calls = append(calls, string(e.Type))
+	}}
+	deployer.PreDeploy = func(_ context.Context, f faas.Function, result faas.DeploymentResult) error {
+		calls = append(calls, "pre-deploy")
+		if client.Creates != 0 {
+			t.Fatal("expected the pre-deploy hook to be invoked before the service is created")
+		}
+		if f.Name != "f" || result != (faas.DeploymentResult{Name: "f", Namespace: "ns"}) {
+			t.Fatalf("unexpected pre-deploy function %v or result %+v", f.Name, result)
+		}
+		return nil
+	}
+	deployer.PostDeploy = func(_ context.Context, f faas.Function, result faas.DeploymentResult) error {
+		calls = append(calls, "post-deploy")
+		if result.URL != "http://f.example.com" || result.Revision != "f-00001" {
+			t.Fatalf("expected the post-deploy hook to receive the result, got %+v", result)
+		}
+		return nil
+	}
+
+	if _, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"pre-deploy", "Creating", "Waiting", "Ready", "post-deploy"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+}
+
+// TestDeployHookErrors ensures that an error of the pre-deploy hook prevents
+// the Service from being applied, that of the post-deploy hook fails the
+// deployment, and that either is only reported if so configured.
+func TestDeployHookErrors(t *testing.T) {
+	failure := errors.New("migration failed")
+	failing := func(context.Context, faas.Function, faas.DeploymentResult) error { return failure }
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client, PreDeploy: failing}
+	_, err := deployer.Deploy(f)
+	if !errors.Is(err, ErrHookFailed) || !errors.Is(err, failure) {
+		t.Fatalf("expected the pre-deploy hook to fail the deployment, got '%v'", err)
+	}
+	if client.Creates != 0 {
+		t.Fatal("expected no service to be created")
+	}
+
+	deployer = &Deployer{client: client, PostDeploy: failing}
+	if _, err = deployer.Deploy(f); !errors.Is(err, ErrHookFailed) {
+		t.Fatalf("expected the post-deploy hook to fail the deployment, got '%v'", err)
+	}
+	if client.Creates != 1 {
+		t.Fatal("expected the service to remain deployed")
+	}
+
+	f.Image = "quay.io/alice/f:v2"
+	var warnings bytes.Buffer
+	deployer = &Deployer{client: client, PreDeploy: failing, PostDeploy: failing, ReportHookErrors: true, Warnings: &warnings}
+	if _, err = deployer.Deploy(f); err != nil {
+		t.Fatalf("expected hook errors to be reported only, got '%v'", err)
+	}
+	if !strings.Contains(warnings.String(), "Warning: pre-deploy hook failed") || !strings.Contains(warnings.String(), "Warning: post-deploy hook failed") {
+		t.Fatalf("expected the hook errors to be reported as warnings, got %q", warnings.String())
+	}
+	if client.Services["f"].Spec.Template.Spec.Containers[0].Image != f.Image {
+		t.Fatal("expected the service to be updated")
+	}
+}
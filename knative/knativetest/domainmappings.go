@@ -0,0 +1,77 @@
+package knativetest
+
+import (
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"knative.dev/serving/pkg/apis/serving"
+)
+
+// domainMappings is the resource of Knative DomainMappings.
+var domainMappings = schema.GroupResource{Group: serving.GroupName, Resource: "domainmappings"}
+
+// DomainMappingClient is an in-memory client of Knative DomainMappings.
+type DomainMappingClient struct {
+	// DomainMappings by name.
+	DomainMappings map[string]*unstructured.Unstructured
+	// Deleted DomainMapping names, in order.
+	Deleted []string
+}
+
+// NewDomainMappingClient of the given existing DomainMappings.
+func NewDomainMappingClient(mappings ...*unstructured.Unstructured) *DomainMappingClient {
+	c := &DomainMappingClient{DomainMappings: map[string]*unstructured.Unstructured{}}
+	for _, m := range mappings {
+		c.DomainMappings[m.GetName()] = m
+	}
+	return c
+}
+
+func (c *DomainMappingClient) GetDomainMapping(name string) (*unstructured.Unstructured, error) {
+	m, ok := c.DomainMappings[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(domainMappings, name)
+	}
+	return m.DeepCopy(), nil
+}
+
+func (c *DomainMappingClient) CreateDomainMapping(mapping *unstructured.Unstructured) error {
+	if _, ok := c.DomainMappings[mapping.GetName()]; ok {
+		return apierrors.NewAlreadyExists(domainMappings, mapping.GetName())
+	}
+	c.DomainMappings[mapping.GetName()] = mapping.DeepCopy()
+	return nil
+}
+
+func (c *DomainMappingClient) UpdateDomainMapping(mapping *unstructured.Unstructured) error {
+	if _, ok := c.DomainMappings[mapping.GetName()]; !ok {
+		return apierrors.NewNotFound(domainMappings, mapping.GetName())
+	}
+	c.DomainMappings[mapping.GetName()] = mapping.DeepCopy()
+	return nil
+}
+
+func (c *DomainMappingClient) DeleteDomainMapping(name string) error {
+	if _, ok := c.DomainMappings[name]; !ok {
+		return apierrors.NewNotFound(domainMappings, name)
+	}
+	delete(c.DomainMappings, name)
+	c.Deleted = append(c.Deleted, name)
+	return nil
+}
+
+// ListDomainMappings labeled with the name of the given Service.
+func (c *DomainMappingClient) ListDomainMappings(serviceName string) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	for _, m := range c.DomainMappings {
+		if m.GetLabels()["boson.dev/function-name"] == serviceName {
+			list.Items = append(list.Items, *m.DeepCopy())
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].GetName() < list.Items[j].GetName()
+	})
+	return list, nil
+}
@@ -0,0 +1,87 @@
+package knativetest
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+)
+
+// EventingClient is an in-memory knative eventing client holding Triggers and
+// Brokers.
+type EventingClient struct {
+	// Brokers by name.
+	Brokers map[string]*v1beta1.Broker
+	// Triggers in the order in which they are listed.
+	Triggers []v1beta1.Trigger
+	// DeleteErrs returned when deleting the named Triggers.
+	DeleteErrs map[string]error
+	// Deleted Trigger names, in order.
+	Deleted []string
+}
+
+// NewEventingClient of the given existing Triggers.
+func NewEventingClient(triggers ...v1beta1.Trigger) *EventingClient {
+	return &EventingClient{
+		Brokers:    map[string]*v1beta1.Broker{},
+		Triggers:   triggers,
+		DeleteErrs: map[string]error{},
+	}
+}
+
+func (c *EventingClient) ListTriggers() (*v1beta1.TriggerList, error) {
+	items := make([]v1beta1.Trigger, len(c.Triggers))
+	copy(items, c.Triggers)
+	return &v1beta1.TriggerList{Items: items}, nil
+}
+
+// ListFunctionTriggers labeled with the name of the given Service.
+func (c *EventingClient) ListFunctionTriggers(serviceName string) (*v1beta1.TriggerList, error) {
+	list := &v1beta1.TriggerList{}
+	for _, t := range c.Triggers {
+		if t.Labels["boson.dev/function-name"] == serviceName {
+			list.Items = append(list.Items, *t.DeepCopy())
+		}
+	}
+	return list, nil
+}
+
+func (c *EventingClient) DeleteTrigger(name string) error {
+	if err := c.DeleteErrs[name]; err != nil {
+		return err
+	}
+	for i, t := range c.Triggers {
+		if t.Name == name {
+			c.Triggers = append(c.Triggers[:i], c.Triggers[i+1:]...)
+			c.Deleted = append(c.Deleted, name)
+			return nil
+		}
+	}
+	return apierrors.NewNotFound(v1beta1.Resource("triggers"), name)
+}
+
+func (c *EventingClient) CreateTrigger(trigger *v1beta1.Trigger) error {
+	for _, t := range c.Triggers {
+		if t.Name == trigger.Name {
+			return apierrors.NewAlreadyExists(v1beta1.Resource("triggers"), trigger.Name)
+		}
+	}
+	c.Triggers = append(c.Triggers, *trigger.DeepCopy())
+	return nil
+}
+
+func (c *EventingClient) UpdateTrigger(trigger *v1beta1.Trigger) error {
+	for i, t := range c.Triggers {
+		if t.Name == trigger.Name {
+			c.Triggers[i] = *trigger.DeepCopy()
+			return nil
+		}
+	}
+	return apierrors.NewNotFound(v1beta1.Resource("triggers"), trigger.Name)
+}
+
+func (c *EventingClient) GetBroker(name string) (*v1beta1.Broker, error) {
+	b, ok := c.Brokers[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(v1beta1.Resource("brokers"), name)
+	}
+	return b.DeepCopy(), nil
+}
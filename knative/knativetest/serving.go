@@ -0,0 +1,238 @@
+// Package knativetest provides fakes of the clients used by the knative
+// package, for testing without a cluster.
+package knativetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/client/pkg/wait"
+	"knative.dev/pkg/apis"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// ServingClient is an in-memory knative.ServingClient whose Services become
+// ready immediately, and whose routes are of the form http://<name>.example.com.
+// Each create or update of a Service creates its next revision, named as by
+// Knative, which is ready once the Service is waited upon.  Methods taking a
+// context return its error, without effect, if it is done.
+type ServingClient struct {
+	// Services by name.
+	Services map[string]*servingv1.Service
+	// Revisions by name.
+	Revisions map[string]*servingv1.Revision
+	// WaitTimeouts with which Services were waited upon or deleted.
+	WaitTimeouts []time.Duration
+	// WaitErr returned when waiting for a Service.
+	WaitErr error
+	// WaitMessages reported, in order, to the callback of each wait.
+	WaitMessages []string
+	// Deleted Service names, in order.
+	Deleted []string
+	// DeletedRevisions names, in order.
+	DeletedRevisions []string
+	// CreateErrs returned by successive creates, before any succeed.
+	CreateErrs []error
+	// Creates attempted.
+	Creates int
+	// Updates applied.
+	Updates int
+	// DeletePolls is the number of times a Service deleted without waiting
+	// is still found, marked for deletion, before it is gone.
+	DeletePolls int
+	// WaitForDeletionErr returned when waiting for a Service to be deleted.
+	WaitForDeletionErr error
+	// WaitForRevisionErr returned when waiting for a revision to be ready.
+	WaitForRevisionErr error
+
+	// deleting Services, by name, with the polls remaining until gone.
+	deleting map[string]int
+}
+
+// NewServingClient of the given existing Services.
+func NewServingClient(services ...*servingv1.Service) *ServingClient {
+	c := &ServingClient{
+		Services:  map[string]*servingv1.Service{},
+		Revisions: map[string]*servingv1.Revision{},
+		deleting:  map[string]int{},
+	}
+	for _, s := range services {
+		c.Services[s.Name] = s
+	}
+	return c
+}
+
+func (c *ServingClient) GetService(ctx context.Context, name string) (*servingv1.Service, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.getService(name)
+}
+
+func (c *ServingClient) getService(name string) (*servingv1.Service, error) {
+	if polls, ok := c.deleting[name]; ok {
+		if polls == 0 {
+			delete(c.deleting, name)
+			delete(c.Services, name)
+		} else {
+			c.deleting[name] = polls - 1
+		}
+	}
+	s, ok := c.Services[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(servingv1.Resource("services"), name)
+	}
+	return s.DeepCopy(), nil
+}
+
+func (c *ServingClient) CreateService(ctx context.Context, service *servingv1.Service) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.Creates++
+	if len(c.CreateErrs) > 0 {
+		err := c.CreateErrs[0]
+		c.CreateErrs = c.CreateErrs[1:]
+		return err
+	}
+	if _, ok := c.Services[service.Name]; ok {
+		return apierrors.NewAlreadyExists(servingv1.Resource("services"), service.Name)
+	}
+	created := service.DeepCopy()
+	created.Generation = 1
+	created.Status.LatestCreatedRevisionName = revisionName(created)
+	c.Services[service.Name] = created
+	return nil
+}
+
+func (c *ServingClient) UpdateServiceWithRetry(ctx context.Context, name string, updateFunc func(*servingv1.Service) (*servingv1.Service, error), nrRetries int) error {
+	s, err := c.GetService(ctx, name)
+	if err != nil {
+		return err
+	}
+	if s, err = updateFunc(s); err != nil {
+		return err
+	}
+	s.Generation++
+	s.Status.LatestCreatedRevisionName = revisionName(s)
+	c.Services[name] = s
+	c.Updates++
+	return nil
+}
+
+// revisionName of the Service's latest generation: that of its template if
+// named, otherwise <name>-<generation>.
+func revisionName(s *servingv1.Service) string {
+	if s.Spec.Template.Name != "" {
+		return s.Spec.Template.Name
+	}
+	return fmt.Sprintf("%v-%05d", s.Name, s.Generation)
+}
+
+func (c *ServingClient) WaitForService(name string, timeout time.Duration, msgCallback wait.MessageCallback) (error, time.Duration) {
+	c.WaitTimeouts = append(c.WaitTimeouts, timeout)
+	for _, m := range c.WaitMessages {
+		msgCallback(timeout, m)
+	}
+	if s, ok := c.Services[name]; ok && c.WaitErr == nil {
+		s.Status.ObservedGeneration = s.Generation
+		s.Status.LatestReadyRevisionName = s.Status.LatestCreatedRevisionName
+	}
+	return c.WaitErr, timeout
+}
+
+func (c *ServingClient) GetRoute(ctx context.Context, name string) (*servingv1.Route, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	route := &servingv1.Route{}
+	route.Status.URL = &apis.URL{Scheme: "http", Host: name + ".example.com"}
+	return route, nil
+}
+
+func (c *ServingClient) GetRevision(name string) (*servingv1.Revision, error) {
+	r, ok := c.Revisions[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(servingv1.Resource("revisions"), name)
+	}
+	return r.DeepCopy(), nil
+}
+
+func (c *ServingClient) DeleteService(name string, timeout time.Duration) error {
+	if _, ok := c.Services[name]; !ok {
+		return apierrors.NewNotFound(servingv1.Resource("services"), name)
+	}
+	c.Deleted = append(c.Deleted, name)
+	if timeout == 0 && c.DeletePolls > 0 {
+		now := metav1.Now()
+		c.Services[name].DeletionTimestamp = &now
+		c.deleting[name] = c.DeletePolls
+		return nil
+	}
+	delete(c.Services, name)
+	c.WaitTimeouts = append(c.WaitTimeouts, timeout)
+	return nil
+}
+
+// WaitForDeletion polls the named Service until it is gone, unless the
+// WaitForDeletionErr is set.
+func (c *ServingClient) WaitForDeletion(name string, timeout time.Duration) error {
+	c.WaitTimeouts = append(c.WaitTimeouts, timeout)
+	if c.WaitForDeletionErr != nil {
+		return c.WaitForDeletionErr
+	}
+	for {
+		if _, err := c.getService(name); apierrors.IsNotFound(err) {
+			return nil
+		}
+	}
+}
+
+// WaitForRevision returns the WaitForRevisionErr, revisions being ready
+// immediately.
+func (c *ServingClient) WaitForRevision(serviceName, revisionName string, timeout time.Duration) error {
+	c.WaitTimeouts = append(c.WaitTimeouts, timeout)
+	return c.WaitForRevisionErr
+}
+
+func (c *ServingClient) ListServicesWithLabel(key, value string) (*servingv1.ServiceList, error) {
+	list := &servingv1.ServiceList{}
+	for _, s := range c.Services {
+		if s.Labels[key] == value {
+			list.Items = append(list.Items, *s.DeepCopy())
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Namespace+"/"+list.Items[i].Name < list.Items[j].Namespace+"/"+list.Items[j].Name
+	})
+	return list, nil
+}
+
+// ListRevisionsOfService returns the Revisions labelled as those of the named
+// Service, sorted by name.
+func (c *ServingClient) ListRevisionsOfService(serviceName string) (*servingv1.RevisionList, error) {
+	list := &servingv1.RevisionList{}
+	for _, r := range c.Revisions {
+		if r.Labels[serving.ServiceLabelKey] == serviceName {
+			list.Items = append(list.Items, *r.DeepCopy())
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Name < list.Items[j].Name
+	})
+	return list, nil
+}
+
+func (c *ServingClient) DeleteRevision(name string, timeout time.Duration) error {
+	if _, ok := c.Revisions[name]; !ok {
+		return apierrors.NewNotFound(servingv1.Resource("revisions"), name)
+	}
+	delete(c.Revisions, name)
+	c.DeletedRevisions = append(c.DeletedRevisions, name)
+	return nil
+}
@@ -0,0 +1,72 @@
+package knativetest
+
+import (
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	sourcesv1alpha2 "knative.dev/eventing/pkg/apis/sources/v1alpha2"
+)
+
+// PingSourceClient is an in-memory knative PingSource client.
+type PingSourceClient struct {
+	// PingSources by name.
+	PingSources map[string]*sourcesv1alpha2.PingSource
+	// Deleted PingSource names, in order.
+	Deleted []string
+}
+
+// NewPingSourceClient of the given existing PingSources.
+func NewPingSourceClient(sources ...*sourcesv1alpha2.PingSource) *PingSourceClient {
+	c := &PingSourceClient{PingSources: map[string]*sourcesv1alpha2.PingSource{}}
+	for _, s := range sources {
+		c.PingSources[s.Name] = s
+	}
+	return c
+}
+
+func (c *PingSourceClient) GetPingSource(name string) (*sourcesv1alpha2.PingSource, error) {
+	s, ok := c.PingSources[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(sourcesv1alpha2.Resource("pingsources"), name)
+	}
+	return s.DeepCopy(), nil
+}
+
+func (c *PingSourceClient) CreatePingSource(source *sourcesv1alpha2.PingSource) error {
+	if _, ok := c.PingSources[source.Name]; ok {
+		return apierrors.NewAlreadyExists(sourcesv1alpha2.Resource("pingsources"), source.Name)
+	}
+	c.PingSources[source.Name] = source.DeepCopy()
+	return nil
+}
+
+func (c *PingSourceClient) UpdatePingSource(source *sourcesv1alpha2.PingSource) error {
+	if _, ok := c.PingSources[source.Name]; !ok {
+		return apierrors.NewNotFound(sourcesv1alpha2.Resource("pingsources"), source.Name)
+	}
+	c.PingSources[source.Name] = source.DeepCopy()
+	return nil
+}
+
+func (c *PingSourceClient) DeletePingSource(name string) error {
+	if _, ok := c.PingSources[name]; !ok {
+		return apierrors.NewNotFound(sourcesv1alpha2.Resource("pingsources"), name)
+	}
+	delete(c.PingSources, name)
+	c.Deleted = append(c.Deleted, name)
+	return nil
+}
+
+// ListFunctionPingSources labeled with the name of the given Service.
+func (c *PingSourceClient) ListFunctionPingSources(serviceName string) (*sourcesv1alpha2.PingSourceList, error) {
+	list := &sourcesv1alpha2.PingSourceList{}
+	for _, s := range c.PingSources {
+		if s.Labels["boson.dev/function-name"] == serviceName {
+			list.Items = append(list.Items, *s.DeepCopy())
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Name < list.Items[j].Name
+	})
+	return list, nil
+}
@@ -1,23 +1,53 @@
 package knative
 
 import (
-	clientservingv1 "knative.dev/client/pkg/serving/v1"
+	"knative.dev/pkg/apis"
 
+	"github.com/boson-project/faas"
 	"github.com/boson-project/faas/k8s"
 )
 
 const (
-	labelKey   = "bosonFunction"
+	// labelKey identifies a Service as a deployed Function.
+	labelKey   = "boson.dev/function"
 	labelValue = "true"
+
+	// legacyLabelKey identified Functions deployed prior to the adoption of
+	// labelKey.
+	legacyLabelKey = "bosonFunction"
+
+	// functionNameLabelKey identifies the Function, by its Service name, to
+	// which resources created alongside its Service belong.
+	functionNameLabelKey = "boson.dev/function-name"
 )
 
+// functionLabelKey returns the label which identifies a Function given an
+// optional domain prefix overriding that of labelKey.
+func functionLabelKey(prefix string) string {
+	if prefix == "" {
+		return labelKey
+	}
+	return prefix + "/function"
+}
+
 type Lister struct {
-	Verbose   bool
-	namespace string
+	Verbose bool
+	// LabelPrefix overrides the domain of the label identifying Functions,
+	// and must match that with which they were deployed.
+	LabelPrefix string
+	// MatchLegacyLabel includes Functions identified by the legacy label.
+	MatchLegacyLabel bool
+	// AllNamespaces lists the Functions of every namespace rather than only
+	// those of the Lister's namespace.
+	AllNamespaces bool
+	namespace     string
+
+	// client to use in place of one constructed for the namespace.
+	client ServingClient
 }
 
 func NewLister(namespaceOverride string) (l *Lister, err error) {
-	l = &Lister{}
+	l = &Lister{MatchLegacyLabel: true}
 
 	namespace, err := GetNamespace(namespaceOverride)
 	if err != nil {
@@ -28,26 +58,64 @@ func NewLister(namespaceOverride string) (l *Lister, err error) {
 	return
 }
 
-func (l *Lister) List() (names []string, err error) {
+// List the deployed Functions, identified by their label.
+func (l *Lister) List() (items []faas.ListItem, err error) {
 
-	client, err := NewServingClient(l.namespace)
+	client, err := l.servingClient()
 	if err != nil {
 		return
 	}
 
-	lst, err := client.ListServices(clientservingv1.WithLabel(labelKey, labelValue))
-	if err != nil {
-		return
+	keys := []string{functionLabelKey(l.LabelPrefix)}
+	if l.MatchLegacyLabel {
+		keys = append(keys, legacyLabelKey)
 	}
 
-	for _, service := range lst.Items {
-		// Convert the "subdomain-encoded" (i.e. kube-service-friendly) name
-		// back out to a fully qualified service name.
-		n, err := k8s.FromK8sAllowedName(service.Name)
+	items = []faas.ListItem{}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		lst, err := client.ListServicesWithLabel(key, labelValue)
 		if err != nil {
-			return names, err
+			return items, err
+		}
+		for _, service := range lst.Items {
+			if seen[service.Namespace+"/"+service.Name] {
+				continue
+			}
+			seen[service.Namespace+"/"+service.Name] = true
+			// Convert the "subdomain-encoded" (i.e. kube-service-friendly) name
+			// back out to a fully qualified service name.
+			n, err := k8s.FromK8sAllowedName(service.Name)
+			if err != nil {
+				return items, err
+			}
+			item := faas.ListItem{
+				Name:      n,
+				Namespace: service.Namespace,
+				Ready:     service.Status.GetCondition(apis.ConditionReady).IsTrue(),
+			}
+			if service.Status.URL != nil {
+				item.URL = service.Status.URL.String()
+			}
+			items = append(items, item)
 		}
-		names = append(names, n)
 	}
 	return
 }
+
+// servingClient returns the client with which to list Services: of all
+// namespaces if AllNamespaces is set, otherwise of the Lister's namespace.
+func (l *Lister) servingClient() (ServingClient, error) {
+	if l.client != nil {
+		return l.client, nil
+	}
+	namespace := l.namespace
+	if l.AllNamespaces {
+		namespace = "" // all namespaces
+	}
+	client, err := NewServingClient(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return knServingClient{client}, nil
+}
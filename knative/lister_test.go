@@ -0,0 +1,78 @@
+package knative
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestListEmpty ensures that listing a namespace without Functions results
+// in an empty list.
+func TestListEmpty(t *testing.T) {
+	other := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns"}}
+	lister := &Lister{MatchLegacyLabel: true, client: knativetest.NewServingClient(other)}
+
+	items, err := lister.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items == nil || len(items) != 0 {
+		t.Fatalf("expected an empty list, got %v", items)
+	}
+}
+
+// TestList ensures that Functions identified by both the current and legacy
+// labels are listed by their decoded names, with their namespace, URL and
+// readiness, and that Services which are not Functions are excluded.
+func TestList(t *testing.T) {
+	service := func(name string, labels map[string]string, ready bool) *servingv1.Service {
+		s := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: labels}}
+		s.Status.URL = &apis.URL{Scheme: "http", Host: name + ".ns.example.com"}
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		s.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionReady, Status: status}}
+		return s
+	}
+	client := knativetest.NewServingClient(
+		service("a-example-com", map[string]string{labelKey: labelValue}, true),
+		service("b", map[string]string{legacyLabelKey: labelValue}, false),
+		service("c", map[string]string{labelKey: labelValue, legacyLabelKey: labelValue}, true),
+		service("other", nil, true),
+	)
+	lister := &Lister{MatchLegacyLabel: true, client: client}
+
+	items, err := lister.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []faas.ListItem{
+		{Name: "a.example.com", Namespace: "ns", URL: "http://a-example-com.ns.example.com", Ready: true},
+		{Name: "c", Namespace: "ns", URL: "http://c.ns.example.com", Ready: true},
+		{Name: "b", Namespace: "ns", URL: "http://b.ns.example.com", Ready: false},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, items)
+	}
+	for i := range expected {
+		if items[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, items)
+		}
+	}
+
+	lister.MatchLegacyLabel = false
+	if items, err = lister.List(); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected legacy Functions to be excluded, got %v", items)
+	}
+}
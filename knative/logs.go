@@ -0,0 +1,187 @@
+package knative
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/serving/pkg/apis/serving"
+
+	"github.com/boson-project/faas/k8s"
+)
+
+func NewLogs(namespaceOverride string) (logs *Logs, err error) {
+	logs = &Logs{}
+	namespace, err := GetNamespace(namespaceOverride)
+	if err != nil {
+		return
+	}
+	logs.Namespace = namespace
+
+	return
+}
+
+// Logs of deployed Functions, being those of the Function's container in
+// each pod of its active revision.
+type Logs struct {
+	Namespace string
+	// Follow the logs as they are written, until the pods terminate.
+	Follow bool
+	// Since, if provided, limits the logs to those written within the
+	// duration.
+	Since time.Duration
+	// Output to which the logs are written.  Defaults to os.Stdout.
+	Output io.Writer
+
+	// clients to use in place of those constructed for the Namespace.
+	client     ServingClient
+	coreClient kubernetes.Interface
+	// stream opens the logs of a pod, in place of requesting them of the
+	// coreClient.
+	stream func(pod string, options *corev1.PodLogOptions) (io.ReadCloser, error)
+}
+
+// Stream the logs of the named Function.  Where the Function is scaled to
+// zero, there being no pods, a message saying so is written in their place.
+// The logs of multiple pods are prefixed with the name of their pod.
+func (l *Logs) Stream(name string) (err error) {
+	serviceName, err := k8s.ToK8sAllowedName(name)
+	if err != nil {
+		return
+	}
+	client, err := l.servingClient()
+	if err != nil {
+		return
+	}
+	coreClient, err := l.kubernetesClient()
+	if err != nil {
+		return
+	}
+
+	service, err := client.GetService(context.Background(), serviceName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return &NotFoundError{Name: name}
+		}
+		return fmt.Errorf("knative logs failed to get the service: %v", err)
+	}
+	revisionName := service.Status.LatestReadyRevisionName
+	if revisionName == "" {
+		revisionName = service.Status.LatestCreatedRevisionName
+	}
+	if revisionName == "" {
+		return fmt.Errorf("function '%v' has no revision", name)
+	}
+
+	pods, err := coreClient.CoreV1().Pods(l.Namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%v=%v", serving.RevisionLabelKey, revisionName),
+	})
+	if err != nil {
+		return fmt.Errorf("knative logs failed to list the pods of revision '%v': %v", revisionName, err)
+	}
+	if len(pods.Items) == 0 {
+		fmt.Fprintf(l.output(), "Function '%v' is scaled to zero, so has no logs.  Logs are available once a request scales it up.\n", name)
+		return
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	// Each pod is streamed concurrently, such that following them interleaves
+	// their logs, with whole lines written at a time.
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, len(pods.Items))
+	)
+	for i, pod := range pods.Items {
+		prefix := ""
+		if len(pods.Items) > 1 {
+			prefix = fmt.Sprintf("[%v] ", pod.Name)
+		}
+		wg.Add(1)
+		go func(i int, pod corev1.Pod) {
+			defer wg.Done()
+			errs[i] = l.streamPod(coreClient, pod, func(line string) {
+				mu.Lock()
+				defer mu.Unlock()
+				fmt.Fprintln(l.output(), prefix+line)
+			})
+		}(i, pod)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// streamPod passes each line of the logs of the pod's Function container,
+// the first of its containers, to the given function.
+func (l *Logs) streamPod(coreClient kubernetes.Interface, pod corev1.Pod, line func(string)) error {
+	if len(pod.Spec.Containers) == 0 {
+		return nil
+	}
+	options := &corev1.PodLogOptions{Container: pod.Spec.Containers[0].Name, Follow: l.Follow}
+	if l.Since > 0 {
+		seconds := int64(l.Since.Seconds())
+		options.SinceSeconds = &seconds
+	}
+
+	var stream io.ReadCloser
+	var err error
+	if l.stream != nil {
+		stream, err = l.stream(pod.Name, options)
+	} else {
+		stream, err = coreClient.CoreV1().Pods(l.Namespace).GetLogs(pod.Name, options).Stream()
+	}
+	if err != nil {
+		return fmt.Errorf("knative logs failed to stream the logs of pod '%v': %v", pod.Name, err)
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		line(scanner.Text())
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("knative logs failed to read the logs of pod '%v': %v", pod.Name, err)
+	}
+	return nil
+}
+
+func (l *Logs) servingClient() (ServingClient, error) {
+	if l.client != nil {
+		return l.client, nil
+	}
+	client, err := NewServingClient(l.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return knServingClient{client}, nil
+}
+
+func (l *Logs) kubernetesClient() (kubernetes.Interface, error) {
+	if l.coreClient != nil {
+		return l.coreClient, nil
+	}
+	return NewKubernetesClient()
+}
+
+func (l *Logs) output() io.Writer {
+	if l.Output == nil {
+		return os.Stdout
+	}
+	return l.Output
+}
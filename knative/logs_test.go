@@ -0,0 +1,86 @@
+package knative
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// logsPod of the named revision, whose first container is the Function's.
+func logsPod(name, revision string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, Labels: map[string]string{serving.RevisionLabelKey: revision}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "user-container"}, {Name: "queue-proxy"}}},
+	}
+}
+
+// TestLogsStream ensures that the logs of the Function container of each pod
+// of the active revision are streamed, with the configured options, and
+// prefixed by pod when there are several.
+func TestLogsStream(t *testing.T) {
+	service := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "f"}}
+	service.Status.LatestReadyRevisionName = "f-00002"
+	coreClient := fake.NewSimpleClientset(logsPod("f-00002-a", "f-00002"), logsPod("f-00002-b", "f-00002"), logsPod("f-00001-a", "f-00001"))
+
+	var output bytes.Buffer
+	requested := map[string]*corev1.PodLogOptions{}
+	logs := &Logs{
+		Namespace:  "ns",
+		Follow:     true,
+		Since:      5 * time.Minute,
+		Output:     &output,
+		client:     knativetest.NewServingClient(service),
+		coreClient: coreClient,
+		stream: func(pod string, options *corev1.PodLogOptions) (io.ReadCloser, error) {
+			requested[pod] = options
+			return ioutil.NopCloser(strings.NewReader("started\nhandled request\n")), nil
+		},
+	}
+	if err := logs.Stream("f"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requested) != 2 || requested["f-00001-a"] != nil {
+		t.Fatalf("expected the logs of only the active revision's pods, got %v", requested)
+	}
+	options := requested["f-00002-a"]
+	if options.Container != "user-container" || !options.Follow || options.SinceSeconds == nil || *options.SinceSeconds != 300 {
+		t.Fatalf("unexpected log options %+v", options)
+	}
+	for _, line := range []string{"[f-00002-a] started", "[f-00002-a] handled request", "[f-00002-b] started"} {
+		if !strings.Contains(output.String(), line+"\n") {
+			t.Fatalf("expected the logs to contain '%v', got '%v'", line, output.String())
+		}
+	}
+}
+
+// TestLogsScaledToZero ensures that a Function without pods is reported as
+// scaled to zero rather than erroring, and that one not deployed errors.
+func TestLogsScaledToZero(t *testing.T) {
+	service := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "f"}}
+	service.Status.LatestReadyRevisionName = "f-00001"
+
+	var output bytes.Buffer
+	logs := &Logs{Namespace: "ns", Output: &output, client: knativetest.NewServingClient(service), coreClient: fake.NewSimpleClientset()}
+	if err := logs.Stream("f"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output.String(), "scaled to zero") {
+		t.Fatalf("expected the function to be reported as scaled to zero, got '%v'", output.String())
+	}
+
+	if err := logs.Stream("g"); err == nil {
+		t.Fatal("expected streaming the logs of a function not deployed to error")
+	}
+}
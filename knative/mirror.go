@@ -0,0 +1,52 @@
+package knative
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// WithRegistryMirror pulls the images of Functions from the registry host
+// from through the mirror host to, such as in networks where the former is
+// not reachable.  May be provided once for each registry mirrored.
+func WithRegistryMirror(from, to string) DeployerOption {
+	return func(d *Deployer) {
+		if d.RegistryMirrors == nil {
+			d.RegistryMirrors = map[string]string{}
+		}
+		d.RegistryMirrors[from] = to
+	}
+}
+
+// mirrorImage returns the image with its registry host rewritten to that of
+// its mirror, if any, retaining its repository and tag or digest.  Docker Hub
+// may be mirrored as docker.io or index.docker.io.  Rewrites are not chained:
+// the image of a mirror is not itself mirrored.
+func (d *Deployer) mirrorImage(image string) (string, error) {
+	if len(d.RegistryMirrors) == 0 {
+		return image, nil
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference '%v': %v", image, err)
+	}
+	for from, to := range d.RegistryMirrors {
+		source, err := name.NewRegistry(from)
+		if err != nil {
+			return "", fmt.Errorf("invalid registry '%v' to be mirrored: %v", from, err)
+		}
+		if source.RegistryStr() != ref.Context().RegistryStr() {
+			continue
+		}
+		mirror, err := name.NewRegistry(to)
+		if err != nil {
+			return "", fmt.Errorf("invalid mirror '%v' of registry '%v': %v", to, from, err)
+		}
+		repository := mirror.RegistryStr() + "/" + ref.Context().RepositoryStr()
+		if digest, ok := ref.(name.Digest); ok {
+			return repository + "@" + digest.DigestStr(), nil
+		}
+		return repository + ":" + ref.Identifier(), nil
+	}
+	return image, nil
+}
@@ -0,0 +1,79 @@
+package knative
+
+import (
+	"testing"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+const testDigest = "sha256:deb7f7e4c5a7e5f0a0f1e6b7c9e43bd6d9e1d6d1b6b0e5a6f8c2a4b3c1d0e9f8"
+
+// TestMirrorImage ensures that the registry host of an image is rewritten to
+// that of its mirror, retaining its repository and tag or digest, and that
+// images of other registries are unchanged.
+func TestMirrorImage(t *testing.T) {
+	mirrors := map[string]string{
+		"quay.io":   "mirror.example.com",
+		"docker.io": "mirror.example.com:5000",
+	}
+	cases := []struct {
+		image    string
+		expected string
+	}{
+		{"quay.io/alice/f:latest", "mirror.example.com/alice/f:latest"},
+		{"quay.io/alice/f:v1", "mirror.example.com/alice/f:v1"},
+		{"quay.io/alice/f", "mirror.example.com/alice/f:latest"},
+		{"quay.io/alice/f@" + testDigest, "mirror.example.com/alice/f@" + testDigest},
+		{"docker.io/alice/f:v1", "mirror.example.com:5000/alice/f:v1"},
+		{"index.docker.io/alice/f:v1", "mirror.example.com:5000/alice/f:v1"},
+		{"alice/f:v1", "mirror.example.com:5000/alice/f:v1"},
+		{"gcr.io/alice/f:v1", "gcr.io/alice/f:v1"},
+		{"gcr.io/alice/f@" + testDigest, "gcr.io/alice/f@" + testDigest},
+		{"mirror.example.com/alice/f:v1", "mirror.example.com/alice/f:v1"},
+	}
+
+	deployer := &Deployer{RegistryMirrors: mirrors}
+	for _, c := range cases {
+		image, err := deployer.mirrorImage(c.image)
+		if err != nil {
+			t.Fatalf("%v: %v", c.image, err)
+		}
+		if image != c.expected {
+			t.Fatalf("expected '%v' to be mirrored as '%v', got '%v'", c.image, c.expected, image)
+		}
+	}
+
+	deployer = &Deployer{RegistryMirrors: map[string]string{"quay.io": "not a host"}}
+	if _, err := deployer.mirrorImage("quay.io/alice/f:v1"); err == nil {
+		t.Fatal("expected an invalid mirror to error")
+	}
+}
+
+// TestDeployRegistryMirror ensures that the container of both created and
+// updated Services pulls the image through its registry's mirror, and that
+// the Function's image is otherwise unchanged.
+func TestDeployRegistryMirror(t *testing.T) {
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client}
+	WithRegistryMirror("quay.io", "mirror.example.com")(deployer)
+
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:v1"}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if image := client.Services["f"].Spec.Template.Spec.Containers[0].Image; image != "mirror.example.com/alice/f:v1" {
+		t.Fatalf("expected the created service to pull through the mirror, got '%v'", image)
+	}
+	if f.Image != "quay.io/alice/f:v1" {
+		t.Fatalf("expected the function's image to be unchanged, got '%v'", f.Image)
+	}
+
+	f.Image = "quay.io/alice/f@" + testDigest
+	if _, err := deployer.Deploy(f); err != nil { // update
+		t.Fatal(err)
+	}
+	if image := client.Services["f"].Spec.Template.Spec.Containers[0].Image; image != "mirror.example.com/alice/f@"+testDigest {
+		t.Fatalf("expected the updated service to pull through the mirror, got '%v'", image)
+	}
+}
@@ -0,0 +1,111 @@
+package knative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/boson-project/faas"
+)
+
+// DeployAllError reports the namespaces to which a Function was deployed, and
+// those to which it could not be, when deployment to any of them fails.
+type DeployAllError struct {
+	// Succeeded namespaces, in the order given.
+	Succeeded []string
+	// Failed namespaces, in the order given, with the cause of each.
+	Failed []string
+	Errs   []error
+}
+
+func (e *DeployAllError) Error() string {
+	failures := make([]string, len(e.Failed))
+	for i := range e.Failed {
+		failures[i] = fmt.Sprintf("%v: %v", e.Failed[i], e.Errs[i])
+	}
+	msg := fmt.Sprintf("knative deployer failed to deploy to namespace %v", strings.Join(failures, "; "))
+	if len(e.Succeeded) > 0 {
+		msg += fmt.Sprintf(" (deployed to %v)", strings.Join(e.Succeeded, ", "))
+	}
+	return msg
+}
+
+func (e *DeployAllError) failed(namespace string, err error) {
+	e.Failed = append(e.Failed, namespace)
+	e.Errs = append(e.Errs, err)
+}
+
+// DeployAll deploys the Function to each of the namespaces, as does Deploy,
+// returning the results of those to which it was deployed.  Deployment to each
+// namespace is attempted regardless of the failure of others, with any
+// failures reported as a DeployAllError.  The clients of each namespace are
+// constructed once, and the Deployer's own Namespace is unaffected.
+func (d *Deployer) DeployAll(f faas.Function, namespaces []string) (results []faas.DeploymentResult, err error) {
+	return d.DeployAllContext(context.Background(), f, namespaces)
+}
+
+// DeployAllContext deploys the Function to each of the namespaces as does
+// DeployAll, abandoning those remaining once the context is done.
+func (d *Deployer) DeployAllContext(ctx context.Context, f faas.Function, namespaces []string) (results []faas.DeploymentResult, err error) {
+	report := &DeployAllError{}
+	seen := map[string]bool{}
+	for _, namespace := range namespaces {
+		if seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+
+		result, err := d.deployTo(ctx, f, namespace)
+		if err != nil {
+			report.failed(namespace, err)
+			continue
+		}
+		report.Succeeded = append(report.Succeeded, namespace)
+		results = append(results, result)
+	}
+	if len(report.Failed) > 0 {
+		err = report
+	}
+	return
+}
+
+// deployTo deploys the Function to the namespace using a copy of the Deployer
+// for the namespace.
+func (d *Deployer) deployTo(ctx context.Context, f faas.Function, namespace string) (faas.DeploymentResult, error) {
+	nd, err := d.forNamespace(namespace)
+	if err != nil {
+		return faas.DeploymentResult{}, err
+	}
+	return nd.DeployContext(ctx, f)
+}
+
+// forNamespace returns a copy of the Deployer for the namespace, with its
+// clients constructed for it.  A serving client provided by WithServingClient
+// is of the Deployer's own namespace, so is not used.  Eventing clients are
+// constructed where not provided, but are optional, as for Deploy, so are
+// left to be constructed as needed where they can not be now.
+func (d *Deployer) forNamespace(namespace string) (*Deployer, error) {
+	if namespace == "" {
+		return nil, errors.New("namespace is required")
+	}
+	nd := *d
+	nd.Namespace = namespace
+	nd.client = d.namespaceClients[namespace]
+
+	var err error
+	if nd.client, err = nd.servingClient(); err != nil {
+		return nil, err
+	}
+	if nd.eventing == nil {
+		if client, err := newEventingClient(namespace, nd.clientOptions()...); err == nil {
+			nd.eventing = client
+		}
+	}
+	if nd.pingSources == nil {
+		if client, err := newPingSourceClient(namespace, nd.clientOptions()...); err == nil {
+			nd.pingSources = client
+		}
+	}
+	return &nd, nil
+}
@@ -0,0 +1,74 @@
+package knative
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDeployAll ensures that the Function is deployed to each namespace with
+// the client of that namespace, continuing past the failure of one, and that
+// those which succeeded and failed are reported.
+func TestDeployAll(t *testing.T) {
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest"}
+	clients := map[string]*knativetest.ServingClient{
+		"alice": knativetest.NewServingClient(),
+		"bob":   knativetest.NewServingClient(),
+		"carol": knativetest.NewServingClient(),
+	}
+	clients["bob"].CreateErrs = []error{apierrors.NewForbidden(servingv1.Resource("services"), "f", errors.New("quota exceeded"))}
+
+	own := knativetest.NewServingClient()
+	deployer := &Deployer{Namespace: "default", client: own, eventing: knativetest.NewEventingClient(), pingSources: knativetest.NewPingSourceClient(), namespaceClients: map[string]ServingClient{}}
+	for namespace, client := range clients {
+		deployer.namespaceClients[namespace] = client
+	}
+
+	results, err := deployer.DeployAll(f, []string{"alice", "bob", "carol", "alice"})
+	var deployErr *DeployAllError
+	if !errors.As(err, &deployErr) {
+		t.Fatalf("expected a DeployAllError, got '%v'", err)
+	}
+	if !reflect.DeepEqual(deployErr.Succeeded, []string{"alice", "carol"}) {
+		t.Fatalf("expected alice and carol to succeed, got %v", deployErr.Succeeded)
+	}
+	if !reflect.DeepEqual(deployErr.Failed, []string{"bob"}) || !strings.Contains(deployErr.Errs[0].Error(), "quota exceeded") {
+		t.Fatalf("expected bob to fail with its cause, got %v: %v", deployErr.Failed, deployErr.Errs)
+	}
+	if !strings.Contains(err.Error(), "bob") || !strings.Contains(err.Error(), "deployed to alice, carol") {
+		t.Fatalf("expected the error to name the namespaces which failed and succeeded, got '%v'", err)
+	}
+
+	expected := []faas.DeploymentResult{
+		{Name: "f", Namespace: "alice", URL: "http://f.example.com", Revision: "f-00001"},
+		{Name: "f", Namespace: "carol", URL: "http://f.example.com", Revision: "f-00001"},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected results %+v, got %+v", expected, results)
+	}
+	for namespace, client := range clients {
+		if _, ok := client.Services["f"]; ok == (namespace == "bob") {
+			t.Fatalf("expected the service to be deployed to %v only if it succeeded", namespace)
+		}
+	}
+	if clients["alice"].Creates != 1 {
+		t.Fatalf("expected a namespace given twice to be deployed to once, got %v creates", clients["alice"].Creates)
+	}
+	if len(own.Services) != 0 || deployer.Namespace != "default" {
+		t.Fatal("expected the deployer's own namespace to be unaffected")
+	}
+
+	if _, err = deployer.DeployAll(f, []string{"alice", "carol"}); err != nil {
+		t.Fatalf("expected no error when all succeed, got '%v'", err)
+	}
+	if _, err = deployer.DeployAll(f, []string{""}); err == nil {
+		t.Fatal("expected an empty namespace to error")
+	}
+}
@@ -2,14 +2,20 @@ package knative
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+
 	"github.com/boson-project/faas/k8s"
 )
 
-func NewRemover(namespaceOverride string) (remover *Remover, err error) {
+func NewRemover(namespaceOverride string, options ...RemoverOption) (remover *Remover, err error) {
 	remover = &Remover{}
-	namespace, err := GetNamespace(namespaceOverride)
+	for _, o := range options {
+		o(remover)
+	}
+	namespace, err := GetNamespace(namespaceOverride, remover.clientOptions()...)
 	if err != nil {
 		return
 	}
@@ -21,8 +27,64 @@ func NewRemover(namespaceOverride string) (remover *Remover, err error) {
 type Remover struct {
 	Namespace string
 	Verbose   bool
+	// Kubeconfig is the path of the kubeconfig file from which to load the
+	// cluster configuration.  If empty the default locations apply.
+	Kubeconfig string
+	// Context of the kubeconfig to use.  If empty its current context applies.
+	Context string
+	// WaitTimeout is the time to wait for the Service to be deleted.  Zero
+	// uses DefaultWaitingTimeout.
+	WaitTimeout time.Duration
+
+	// clients to use in place of those constructed for the Namespace.
+	client         ServingClient
+	eventing       eventingClient
+	pingSources    pingSourceClient
+	domainMappings domainMappingClient
+}
+
+// RemoverOption configures a Remover.
+type RemoverOption func(*Remover)
+
+// WithRemoverKubeconfig selects the kubeconfig file and context of the
+// cluster from which to remove.  Either may be empty to use the default.
+func WithRemoverKubeconfig(path, context string) RemoverOption {
+	return func(r *Remover) {
+		r.Kubeconfig = path
+		r.Context = context
+	}
+}
+
+// RemoveError reports the resources of a Function which were deleted, and
+// those which could not be, when removal fails for any of them.
+type RemoveError struct {
+	// Deleted resources, of the form kind/name.
+	Deleted []string
+	// Failed resources, of the form kind/name, with the cause of each.
+	Failed []string
+	Errs   []error
 }
 
+func (e *RemoveError) Error() string {
+	failures := make([]string, len(e.Failed))
+	for i := range e.Failed {
+		failures[i] = fmt.Sprintf("%v: %v", e.Failed[i], e.Errs[i])
+	}
+	msg := fmt.Sprintf("knative remover failed to delete %v", strings.Join(failures, "; "))
+	if len(e.Deleted) > 0 {
+		msg += fmt.Sprintf(" (deleted %v)", strings.Join(e.Deleted, ", "))
+	}
+	return msg
+}
+
+func (e *RemoveError) failed(resource string, err error) {
+	e.Failed = append(e.Failed, resource)
+	e.Errs = append(e.Errs, err)
+}
+
+// Remove the named Function: its Service and the eventing resources labeled
+// as belonging to it.  Deletion of each is attempted regardless of the
+// failure of others, with any failures reported as a RemoveError.
 func (remover *Remover) Remove(name string) (err error) {
 
 	serviceName, err := k8s.ToK8sAllowedName(name)
@@ -30,15 +92,183 @@ func (remover *Remover) Remove(name string) (err error) {
 		return
 	}
 
-	client, err := NewServingClient(remover.Namespace)
+	client, err := remover.servingClient()
 	if err != nil {
 		return
 	}
 
-	err = client.DeleteService(serviceName, time.Second*60)
+	eventingClient, err := remover.eventingClient()
 	if err != nil {
-		err = fmt.Errorf("knative remover failed to delete the service: %v", err)
+		return
 	}
 
-	return
+	result := &RemoveError{}
+
+	// Eventing resources are deleted first, as they refer to the Service.
+	triggers, err := eventingClient.ListFunctionTriggers(serviceName)
+	// IsNotFound -- Eventing is probably not installed on the cluster
+	if err != nil && !errors.IsNotFound(err) {
+		result.failed("triggers", err)
+	}
+	if err == nil {
+		for _, t := range triggers.Items {
+			if !ownedBy(t.Labels, serviceName) {
+				continue
+			}
+			resource := "trigger/" + t.Name
+			if err := eventingClient.DeleteTrigger(t.Name); err != nil && !errors.IsNotFound(err) {
+				result.failed(resource, err)
+				continue
+			}
+			result.Deleted = append(result.Deleted, resource)
+			if remover.Verbose {
+				fmt.Printf("Deleted %v\n", resource)
+			}
+		}
+	}
+
+	remover.removePingSources(serviceName, result)
+	remover.removeDomainMappings(serviceName, result)
+
+	// The Service is reported deleted only once it is gone.
+	resource := "service/" + serviceName
+	if err := client.DeleteService(serviceName, 0); err != nil {
+		result.failed(resource, err)
+	} else if err := client.WaitForDeletion(serviceName, remover.waitTimeout()); err != nil {
+		result.failed(resource, err)
+	} else {
+		result.Deleted = append(result.Deleted, resource)
+		if remover.Verbose {
+			fmt.Printf("Deleted %v\n", resource)
+		}
+	}
+
+	if len(result.Failed) > 0 {
+		return result
+	}
+	return nil
+}
+
+// removePingSources of the Function, recording the result of each.  Sources
+// which can not be listed, such as if Eventing is not installed, are skipped.
+func (remover *Remover) removePingSources(serviceName string, result *RemoveError) {
+	client, err := remover.pingSourceClient()
+	if err != nil {
+		return
+	}
+	sources, err := client.ListFunctionPingSources(serviceName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			result.failed("pingsources", err)
+		}
+		return
+	}
+	for _, s := range sources.Items {
+		if !ownedBy(s.Labels, serviceName) {
+			continue
+		}
+		resource := "pingsource/" + s.Name
+		if err := client.DeletePingSource(s.Name); err != nil && !errors.IsNotFound(err) {
+			result.failed(resource, err)
+			continue
+		}
+		result.Deleted = append(result.Deleted, resource)
+		if remover.Verbose {
+			fmt.Printf("Deleted %v\n", resource)
+		}
+	}
+}
+
+// removeDomainMappings of the Function, recording the result of each.
+// DomainMappings which can not be listed, such as if they are not served by
+// the cluster, are skipped.
+func (remover *Remover) removeDomainMappings(serviceName string, result *RemoveError) {
+	client, err := remover.domainMappingClient()
+	if err != nil {
+		return
+	}
+	mappings, err := client.ListDomainMappings(serviceName)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			result.failed("domainmappings", err)
+		}
+		return
+	}
+	for _, m := range mappings.Items {
+		if !ownedBy(m.GetLabels(), serviceName) {
+			continue
+		}
+		resource := "domainmapping/" + m.GetName()
+		if err := client.DeleteDomainMapping(m.GetName()); err != nil && !errors.IsNotFound(err) {
+			result.failed(resource, err)
+			continue
+		}
+		result.Deleted = append(result.Deleted, resource)
+		if remover.Verbose {
+			fmt.Printf("Deleted %v\n", resource)
+		}
+	}
+}
+
+// functionSelector of the resources created alongside the Service of the
+// given name, labeled as belonging to its Function.
+func functionSelector(serviceName string) string {
+	return fmt.Sprintf("%v=%v", functionNameLabelKey, serviceName)
+}
+
+// ownedBy reports whether the labels identify a resource as belonging to the
+// Function of the given Service name.
+func ownedBy(labels map[string]string, serviceName string) bool {
+	return (labels[labelKey] == labelValue || labels[legacyLabelKey] == labelValue) &&
+		labels[functionNameLabelKey] == serviceName
+}
+
+// servingClient returns the client to use for the Remover's namespace.
+func (remover *Remover) servingClient() (ServingClient, error) {
+	if remover.client != nil {
+		return remover.client, nil
+	}
+	client, err := NewServingClient(remover.Namespace, remover.clientOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	return knServingClient{client}, nil
+}
+
+// pingSourceClient returns the client to use for the Remover's namespace.
+func (remover *Remover) pingSourceClient() (pingSourceClient, error) {
+	if remover.pingSources != nil {
+		return remover.pingSources, nil
+	}
+	return newPingSourceClient(remover.Namespace, remover.clientOptions()...)
+}
+
+// domainMappingClient returns the client to use for the Remover's
+// namespace.
+func (remover *Remover) domainMappingClient() (domainMappingClient, error) {
+	if remover.domainMappings != nil {
+		return remover.domainMappings, nil
+	}
+	return newDomainMappingClient(remover.Namespace, remover.clientOptions()...)
+}
+
+// eventingClient returns the client to use for the Remover's namespace.
+func (remover *Remover) eventingClient() (eventingClient, error) {
+	if remover.eventing != nil {
+		return remover.eventing, nil
+	}
+	return newEventingClient(remover.Namespace, remover.clientOptions()...)
+}
+
+// clientOptions for connecting to the Remover's cluster.
+func (remover *Remover) clientOptions() []ClientOption {
+	return []ClientOption{WithKubeconfigPath(remover.Kubeconfig), WithKubeContext(remover.Context)}
+}
+
+// waitTimeout returns the configured timeout, or the default if not set.
+func (remover *Remover) waitTimeout() time.Duration {
+	if remover.WaitTimeout == 0 {
+		return DefaultWaitingTimeout
+	}
+	return remover.WaitTimeout
 }
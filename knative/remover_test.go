@@ -0,0 +1,115 @@
+package knative
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	sourcesv1alpha2 "knative.dev/eventing/pkg/apis/sources/v1alpha2"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+func trigger(name string, labels map[string]string) v1beta1.Trigger {
+	return v1beta1.Trigger{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+// TestRemove ensures that the Service and the Triggers, PingSources and
+// DomainMappings labeled as belonging to the Function are deleted, and that
+// those of other Functions are not.
+func TestRemove(t *testing.T) {
+	service := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "f-example-com"}}
+	serving := knativetest.NewServingClient(service)
+	eventing := knativetest.NewEventingClient(
+		trigger("f-ping", map[string]string{labelKey: labelValue, functionNameLabelKey: "f-example-com"}),
+		trigger("f-legacy", map[string]string{legacyLabelKey: labelValue, functionNameLabelKey: "f-example-com"}),
+		trigger("g-ping", map[string]string{labelKey: labelValue, functionNameLabelKey: "g"}),
+		trigger("unlabeled", nil),
+	)
+	pingSources := knativetest.NewPingSourceClient(
+		&sourcesv1alpha2.PingSource{ObjectMeta: metav1.ObjectMeta{Name: "f-example-com-schedule", Labels: map[string]string{labelKey: labelValue, functionNameLabelKey: "f-example-com"}}},
+		&sourcesv1alpha2.PingSource{ObjectMeta: metav1.ObjectMeta{Name: "g-schedule", Labels: map[string]string{labelKey: labelValue, functionNameLabelKey: "g"}}},
+	)
+	domainMappings := knativetest.NewDomainMappingClient(
+		generateDomainMapping("", "f-example-com", "api.example.com", ""),
+		generateDomainMapping("", "g", "g.example.com", ""),
+	)
+	remover := &Remover{client: serving, eventing: eventing, pingSources: pingSources, domainMappings: domainMappings}
+
+	if err := remover.Remove("f.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(eventing.Deleted, ",") != "f-ping,f-legacy" {
+		t.Fatalf("expected triggers f-ping and f-legacy to be deleted, got %v", eventing.Deleted)
+	}
+	if strings.Join(serving.Deleted, ",") != "f-example-com" {
+		t.Fatalf("expected service f-example-com to be deleted, got %v", serving.Deleted)
+	}
+	if strings.Join(pingSources.Deleted, ",") != "f-example-com-schedule" {
+		t.Fatalf("expected ping source f-example-com-schedule to be deleted, got %v", pingSources.Deleted)
+	}
+	if strings.Join(domainMappings.Deleted, ",") != "api.example.com" {
+		t.Fatalf("expected domain mapping api.example.com to be deleted, got %v", domainMappings.Deleted)
+	}
+	if len(eventing.Triggers) != 2 {
+		t.Fatalf("expected the triggers of other functions to remain, got %v", len(eventing.Triggers))
+	}
+	if serving.WaitTimeouts[len(serving.WaitTimeouts)-1] != DefaultWaitingTimeout {
+		t.Fatalf("expected the deletion of the service to be waited upon, got %v", serving.WaitTimeouts)
+	}
+}
+
+// TestRemoveWaitsForDeletion ensures that the Service is reported deleted
+// only once it is gone, and as failed if it is not gone in time.
+func TestRemoveWaitsForDeletion(t *testing.T) {
+	serving := knativetest.NewServingClient(&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "f"}})
+	serving.DeletePolls = 2
+	remover := &Remover{client: serving, eventing: knativetest.NewEventingClient(), pingSources: knativetest.NewPingSourceClient(), domainMappings: knativetest.NewDomainMappingClient(), WaitTimeout: time.Minute}
+
+	if err := remover.Remove("f"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := serving.Services["f"]; ok {
+		t.Fatal("expected the service to be gone once removed")
+	}
+	if timeout := serving.WaitTimeouts[len(serving.WaitTimeouts)-1]; timeout != time.Minute {
+		t.Fatalf("expected the configured wait timeout, got %v", timeout)
+	}
+
+	serving.Services["f"] = &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "f"}}
+	serving.WaitForDeletionErr = errors.New("timeout: service 'f' not deleted after 1m0s: marked for deletion")
+	err := remover.Remove("f")
+	var removeErr *RemoveError
+	if !errors.As(err, &removeErr) || strings.Join(removeErr.Failed, ",") != "service/f" || len(removeErr.Deleted) != 0 {
+		t.Fatalf("expected service/f to fail to be deleted, got '%v'", err)
+	}
+}
+
+// TestRemovePartialFailure ensures that a failure to delete one resource does
+// not prevent the deletion of others, and that the error reports both.
+func TestRemovePartialFailure(t *testing.T) {
+	labels := map[string]string{labelKey: labelValue, functionNameLabelKey: "f"}
+	serving := knativetest.NewServingClient(&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "f"}})
+	eventing := knativetest.NewEventingClient(trigger("a", labels), trigger("b", labels))
+	eventing.DeleteErrs["a"] = errors.New("forbidden")
+	remover := &Remover{client: serving, eventing: eventing, pingSources: knativetest.NewPingSourceClient(), domainMappings: knativetest.NewDomainMappingClient()}
+
+	err := remover.Remove("f")
+	var removeErr *RemoveError
+	if !errors.As(err, &removeErr) {
+		t.Fatalf("expected a RemoveError, got '%v'", err)
+	}
+	if strings.Join(removeErr.Failed, ",") != "trigger/a" {
+		t.Fatalf("expected trigger/a to fail, got %v", removeErr.Failed)
+	}
+	if strings.Join(removeErr.Deleted, ",") != "trigger/b,service/f" {
+		t.Fatalf("expected trigger/b and service/f to be deleted, got %v", removeErr.Deleted)
+	}
+	if !strings.Contains(err.Error(), "trigger/a: forbidden") || !strings.Contains(err.Error(), "deleted trigger/b, service/f") {
+		t.Fatalf("expected the error to report the failed and deleted resources, got '%v'", err)
+	}
+}
@@ -0,0 +1,203 @@
+package knative
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	sourcesv1alpha2 "knative.dev/eventing/pkg/apis/sources/v1alpha2"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/k8s"
+)
+
+// Render the Service which would be created by deploying the Function as
+// YAML, without interacting with the cluster.  The manifest includes the
+// environment, labels, scaling annotations and resources to be applied.
+func (d *Deployer) Render(f faas.Function) (manifest []byte, err error) {
+	if err = f.Validate(); err != nil {
+		return
+	}
+	if err = d.validate(f); err != nil {
+		return
+	}
+	if f.Image, err = d.mirrorImage(f.Image); err != nil {
+		return
+	}
+	return d.manifest(f)
+}
+
+// manifest of the Function's Service, its image already mirrored.
+func (d *Deployer) manifest(f faas.Function) ([]byte, error) {
+	service, err := d.renderService(f)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := yaml.Marshal(service)
+	if err != nil {
+		return nil, fmt.Errorf("knative deployer failed to render the service: %v", err)
+	}
+	return manifest, nil
+}
+
+// writeManifests writes the rendered manifests of the Function to the
+// ManifestDir, each named after the Function: that of its Service, and those
+// of the eventing resources invoking it, being the Trigger of each of its
+// Subscriptions and the PingSource of its schedule, and that of the
+// DomainMapping of its domain.  Those written previously of resources the Function no
+// longer has are removed.  Rendering is deterministic, so an unchanged
+// Function produces identical files.
+func (d *Deployer) writeManifests(f faas.Function) error {
+	serviceName, err := k8s.ToK8sAllowedName(f.Name)
+	if err != nil {
+		return err
+	}
+	manifest, err := d.manifest(f)
+	if err != nil {
+		return err
+	}
+	manifests := map[string][]byte{serviceName + ".yaml": manifest}
+	for i, s := range f.Subscriptions {
+		trigger := generateTrigger(d.Namespace, serviceName, i, s)
+		trigger.TypeMeta = metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "Trigger"}
+		if manifests[trigger.Name+".yaml"], err = yaml.Marshal(trigger); err != nil {
+			return fmt.Errorf("knative deployer failed to render the trigger: %v", err)
+		}
+	}
+	if f.Schedule != "" {
+		pingSource := generatePingSource(d.Namespace, serviceName, f.Schedule)
+		pingSource.TypeMeta = metav1.TypeMeta{APIVersion: sourcesv1alpha2.SchemeGroupVersion.String(), Kind: "PingSource"}
+		if manifests[pingSource.Name+".yaml"], err = yaml.Marshal(pingSource); err != nil {
+			return fmt.Errorf("knative deployer failed to render the ping source: %v", err)
+		}
+	}
+	if f.Domain != "" {
+		mapping := generateDomainMapping(d.Namespace, serviceName, f.Domain, f.DomainTLSSecret)
+		if manifests[domainMappingManifest(serviceName)], err = yaml.Marshal(mapping.Object); err != nil {
+			return fmt.Errorf("knative deployer failed to render the domain mapping: %v", err)
+		}
+	}
+
+	if err = os.MkdirAll(d.ManifestDir, 0755); err != nil {
+		return fmt.Errorf("knative deployer failed to create the manifest directory: %v", err)
+	}
+	if err = d.removeStaleManifests(serviceName, manifests); err != nil {
+		return err
+	}
+	for name, manifest := range manifests {
+		if err = ioutil.WriteFile(filepath.Join(d.ManifestDir, name), manifest, 0644); err != nil {
+			return fmt.Errorf("knative deployer failed to write the manifest: %v", err)
+		}
+	}
+	return nil
+}
+
+// removeStaleManifests of the Function's resources in the ManifestDir which
+// are not among those to be written.  The directory may be shared by several
+// Functions, whose file names can coincide, so only a manifest of the
+// expected kind labeled as belonging to the Function is removed.
+func (d *Deployer) removeStaleManifests(serviceName string, manifests map[string][]byte) error {
+	files, err := ioutil.ReadDir(d.ManifestDir)
+	if err != nil {
+		return fmt.Errorf("knative deployer failed to read the manifest directory: %v", err)
+	}
+	for _, file := range files {
+		name := file.Name()
+		kind := resourceManifestKind(serviceName, name)
+		if _, ok := manifests[name]; ok || kind == "" {
+			continue
+		}
+		path := filepath.Join(d.ManifestDir, name)
+		owned, err := isOwnedManifest(path, serviceName, kind)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			continue
+		}
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("knative deployer failed to remove the stale manifest: %v", err)
+		}
+	}
+	return nil
+}
+
+// resourceManifestKind returns the kind of the resource, other than the
+// Service itself, written for the Function of the Service to the file of the
+// given name, or "" if the name is not that of such a manifest.
+func resourceManifestKind(serviceName, file string) string {
+	if !strings.HasSuffix(file, ".yaml") {
+		return ""
+	}
+	if file == domainMappingManifest(serviceName) {
+		return "DomainMapping"
+	}
+	name := strings.TrimSuffix(file, ".yaml")
+	if name == pingSourceName(serviceName) {
+		return "PingSource"
+	}
+	if index := strings.TrimPrefix(name, serviceName+"-trigger-"); index != name {
+		if _, err := strconv.Atoi(index); err == nil {
+			return "Trigger"
+		}
+	}
+	return ""
+}
+
+// isOwnedManifest reports whether the file is the manifest of a resource of
+// the given kind belonging to the Function of the Service.  A file which is
+// not such a manifest, such as one which does not parse, is not owned.
+func isOwnedManifest(path, serviceName, kind string) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("knative deployer failed to read the manifest: %v", err)
+	}
+	resource := metav1.PartialObjectMetadata{}
+	if err = yaml.Unmarshal(data, &resource); err != nil {
+		return false, nil
+	}
+	return resource.Kind == kind && ownedBy(resource.Labels, serviceName), nil
+}
+
+// domainMappingManifest is the file of the manifest of the DomainMapping of
+// the Function, which is named after the Function rather than its domain.
+func domainMappingManifest(serviceName string) string {
+	return serviceName + "-domain-mapping.yaml"
+}
+
+// renderService returns the complete Service, including its type and
+// namespace, which would be created for the Function.
+func (d *Deployer) renderService(f faas.Function) (*servingv1.Service, error) {
+	serviceName, err := k8s.ToK8sAllowedName(f.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := generateNewService(serviceName, f, d.baselineEnv(f))
+	if err != nil {
+		return nil, fmt.Errorf("knative deployer failed to generate the service: %v", err)
+	}
+	setFunctionLabel(service, functionLabelKey(d.LabelPrefix))
+	if d.Metrics {
+		updateMetricsAnnotations(&service.Spec.Template, f)
+	} else {
+		removeMetricsAnnotations(&service.Spec.Template, f)
+	}
+
+	service.TypeMeta = metav1.TypeMeta{
+		APIVersion: servingv1.SchemeGroupVersion.String(),
+		Kind:       "Service",
+	}
+	service.Namespace = d.Namespace
+	return service, nil
+}
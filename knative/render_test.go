@@ -0,0 +1,260 @@
+package knative
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	sourcesv1alpha2 "knative.dev/eventing/pkg/apis/sources/v1alpha2"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestRender ensures that the rendered manifest round-trips into a Service
+// equivalent to that which would be created, and that rendering does not
+// interact with the cluster.
+func TestRender(t *testing.T) {
+	f := faas.Function{
+		Name:     "f.example.com",
+		Image:    "quay.io/alice/f:latest",
+		EnvVars:  map[string]string{"A": "1"},
+		Labels:   map[string]string{"team": "a"},
+		MinScale: 1,
+		MaxScale: 3,
+		Resources: faas.Resources{
+			Limits: faas.ResourceList{Memory: "256Mi"},
+		},
+	}
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{Namespace: "ns", client: client}
+
+	manifest, err := deployer.Render(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Services) != 0 {
+		t.Fatal("expected rendering not to create a service")
+	}
+
+	rendered := &servingv1.Service{}
+	if err = yaml.Unmarshal(manifest, rendered); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := deployer.renderService(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rendered, expected) {
+		t.Fatalf("expected rendered service\n%v\nto equal\n%+v", string(manifest), expected)
+	}
+
+	if rendered.Kind != "Service" || rendered.APIVersion != "serving.knative.dev/v1" {
+		t.Fatalf("unexpected type '%v/%v'", rendered.APIVersion, rendered.Kind)
+	}
+	if rendered.Name != "f-example-com" || rendered.Namespace != "ns" {
+		t.Fatalf("unexpected name '%v/%v'", rendered.Namespace, rendered.Name)
+	}
+	if rendered.Labels["team"] != "a" || rendered.Labels[labelKey] != labelValue {
+		t.Fatalf("unexpected labels %v", rendered.Labels)
+	}
+	template := rendered.Spec.Template
+	assertAnnotation(t, template.Annotations, autoscaling.MinScaleAnnotationKey, "1")
+	container := template.Spec.Containers[0]
+	assertEnvVar(t, container.Env, "A", "1")
+	if memory := container.Resources.Limits.Memory(); memory.String() != "256Mi" {
+		t.Fatalf("unexpected memory limit %v", memory)
+	}
+
+	if _, err = deployer.Render(faas.Function{Name: "f"}); err == nil {
+		t.Fatal("expected rendering an invalid function to error")
+	}
+}
+
+// TestDeployManifestDir ensures that deploying with a manifest directory
+// writes the Service named after the Function rather than creating it, and
+// that an unchanged Function produces byte-identical output.
+func TestDeployManifestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := faas.Function{
+		Name:     "f.example.com",
+		Image:    "quay.io/alice/f:latest",
+		EnvVars:  map[string]string{"B": "2", "A": "1"},
+		Labels:   map[string]string{"team": "a", "app": "f"},
+		MinScale: 1,
+	}
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{Namespace: "ns", client: client, ManifestDir: filepath.Join(dir, "config")}
+
+	var previous []byte
+	for i := 0; i < 3; i++ {
+		if _, err = deployer.Deploy(f); err != nil {
+			t.Fatal(err)
+		}
+		manifest, err := ioutil.ReadFile(filepath.Join(dir, "config", "f-example-com.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if previous != nil && !bytes.Equal(manifest, previous) {
+			t.Fatalf("expected identical manifests, got\n%s\nand\n%s", previous, manifest)
+		}
+		previous = manifest
+	}
+	if len(client.Services) != 0 {
+		t.Fatal("expected writing manifests not to create a service")
+	}
+}
+
+// TestDeployManifestDirResources ensures that deploying with a manifest
+// directory also writes the Triggers, PingSource and DomainMapping of the
+// Function, each to its own file, byte-identical when unchanged, and that the
+// manifests of those since removed are deleted while those of other Functions
+// are not.
+func TestDeployManifestDirResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"g-trigger-0.yaml", "f-example-com-trigger-notes.yaml"} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte("unrelated"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := faas.Function{
+		Name:  "f.example.com",
+		Image: "quay.io/alice/f:latest",
+		Subscriptions: []faas.Subscription{
+			{Type: "order.created", Broker: "orders"},
+			{Source: "/billing", Attributes: map[string]string{"region": "eu"}},
+		},
+		Schedule:        "0 * * * *",
+		Domain:          "api.example.com",
+		DomainTLSSecret: "api-tls",
+	}
+	deployer := &Deployer{Namespace: "ns", client: knativetest.NewServingClient(), ManifestDir: dir}
+
+	var previous map[string][]byte
+	for i := 0; i < 3; i++ {
+		if _, err = deployer.Deploy(f); err != nil {
+			t.Fatal(err)
+		}
+		manifests := readManifests(t, dir)
+		if previous != nil && !reflect.DeepEqual(manifests, previous) {
+			t.Fatalf("expected identical manifests, got\n%s\nand\n%s", previous, manifests)
+		}
+		previous = manifests
+	}
+
+	for i, s := range f.Subscriptions {
+		trigger := &v1beta1.Trigger{}
+		if err = yaml.Unmarshal(previous[triggerName("f-example-com", i)+".yaml"], trigger); err != nil {
+			t.Fatal(err)
+		}
+		expected := generateTrigger("ns", "f-example-com", i, s)
+		expected.TypeMeta = metav1.TypeMeta{APIVersion: "eventing.knative.dev/v1beta1", Kind: "Trigger"}
+		if !reflect.DeepEqual(trigger, expected) {
+			t.Fatalf("expected trigger\n%+v\ngot\n%+v", expected, trigger)
+		}
+	}
+
+	pingSource := &sourcesv1alpha2.PingSource{}
+	if err = yaml.Unmarshal(previous["f-example-com-schedule.yaml"], pingSource); err != nil {
+		t.Fatal(err)
+	}
+	expectedPingSource := generatePingSource("ns", "f-example-com", f.Schedule)
+	expectedPingSource.TypeMeta = metav1.TypeMeta{APIVersion: "sources.knative.dev/v1alpha2", Kind: "PingSource"}
+	if !reflect.DeepEqual(pingSource, expectedPingSource) {
+		t.Fatalf("expected ping source\n%+v\ngot\n%+v", expectedPingSource, pingSource)
+	}
+
+	mapping := &unstructured.Unstructured{}
+	if err = yaml.Unmarshal(previous["f-example-com-domain-mapping.yaml"], &mapping.Object); err != nil {
+		t.Fatal(err)
+	}
+	if expectedMapping := generateDomainMapping("ns", "f-example-com", f.Domain, f.DomainTLSSecret); !reflect.DeepEqual(mapping, expectedMapping) {
+		t.Fatalf("expected domain mapping\n%+v\ngot\n%+v", expectedMapping, mapping)
+	}
+
+	f.Subscriptions = f.Subscriptions[:1]
+	f.Schedule = ""
+	f.Domain, f.DomainTLSSecret = "", ""
+	if _, err = deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range readManifests(t, dir) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expected := []string{"f-example-com-trigger-0.yaml", "f-example-com-trigger-notes.yaml", "f-example-com.yaml", "g-trigger-0.yaml"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected manifests %v, got %v", expected, names)
+	}
+}
+
+// TestDeployManifestDirShared ensures manifests of other Functions written to
+// the same ManifestDir are not removed as stale, even where their file names
+// coincide with those of the Function's resources.
+func TestDeployManifestDirShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	deployer := &Deployer{Namespace: "ns", client: knativetest.NewServingClient(), ManifestDir: dir}
+
+	for _, name := range []string{"foo.schedule", "foo.trigger.1", "foo.domain.mapping"} {
+		if _, err = deployer.Deploy(faas.Function{Name: name, Image: "quay.io/alice/f:latest"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "foo-trigger-2.yaml"), []byte("kind: [unparsable"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = deployer.Deploy(faas.Function{Name: "foo", Image: "quay.io/alice/f:latest"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for name := range readManifests(t, dir) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expected := []string{"foo-domain-mapping.yaml", "foo-schedule.yaml", "foo-trigger-1.yaml", "foo-trigger-2.yaml", "foo.yaml"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected manifests %v, got %v", expected, names)
+	}
+}
+
+// readManifests of the directory by file name.
+func readManifests(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests := map[string][]byte{}
+	for _, file := range files {
+		if manifests[file.Name()], err = ioutil.ReadFile(filepath.Join(dir, file.Name())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return manifests
+}
@@ -0,0 +1,53 @@
+package knative
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// ImageResolver resolves an image reference to one pinned by digest, for
+// example quay.io/alice/f:latest to quay.io/alice/f@sha256:...
+type ImageResolver interface {
+	Resolve(image string) (string, error)
+}
+
+// registryResolver resolves images by querying their registry, using the
+// credentials of the local docker configuration.
+type registryResolver struct{}
+
+func (registryResolver) Resolve(image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	descriptor, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return "", err
+	}
+	return ref.Context().Digest(descriptor.Digest.String()).String(), nil
+}
+
+// resolveImage returns the image pinned by the digest to which it currently
+// refers.  Images already referenced by digest are returned as-is.
+func (d *Deployer) resolveImage(image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := ref.(name.Digest); ok {
+		return image, nil
+	}
+
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = registryResolver{}
+	}
+	resolved, err := resolver.Resolve(image)
+	if err != nil {
+		return "", fmt.Errorf("knative deployer failed to resolve the digest of image '%v': %v", image, err)
+	}
+	return resolved, nil
+}
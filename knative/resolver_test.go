@@ -0,0 +1,77 @@
+package knative
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+type fakeResolver struct {
+	digest string
+	err    error
+	images []string
+}
+
+func (r *fakeResolver) Resolve(image string) (string, error) {
+	r.images = append(r.images, image)
+	if r.err != nil {
+		return "", r.err
+	}
+	return strings.Split(image, ":")[0] + "@" + r.digest, nil
+}
+
+// TestDeployPinImageDigest ensures that the image of a Function configured
+// for digest pinning is deployed by digest, that images already pinned are
+// not resolved, that redeploys update the digest, and that failure to resolve is an error.
+func TestDeployPinImageDigest(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	resolver := &fakeResolver{digest: digest}
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client, Resolver: resolver}
+
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", PinImageDigest: true}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	image := client.Services["f"].Spec.Template.Spec.Containers[0].Image
+	if image != "quay.io/alice/f@"+digest {
+		t.Fatalf("expected image pinned by digest, got '%v'", image)
+	}
+
+	// Redeploying updates the pinned digest.
+	resolver.digest = "sha256:" + strings.Repeat("b", 64)
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	image = client.Services["f"].Spec.Template.Spec.Containers[0].Image
+	if image != "quay.io/alice/f@"+resolver.digest {
+		t.Fatalf("expected image pinned by the updated digest, got '%v'", image)
+	}
+
+	resolver.images = nil
+	f.Image = "quay.io/alice/f@" + digest
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(resolver.images) != 0 {
+		t.Fatalf("expected an image referenced by digest not to be resolved, resolved %v", resolver.images)
+	}
+
+	f.PinImageDigest = false
+	f.Image = "quay.io/alice/f:latest"
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(resolver.images) != 0 {
+		t.Fatalf("expected no resolution when not pinning, resolved %v", resolver.images)
+	}
+
+	f.PinImageDigest = true
+	resolver.err = errors.New("unauthorized")
+	if _, err := deployer.Deploy(f); err == nil {
+		t.Fatal("expected failure to resolve the digest to error")
+	}
+}
@@ -0,0 +1,112 @@
+package knative
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas/k8s"
+)
+
+// Revisions of the named Function, most recent first.
+func (d *Deployer) Revisions(name string) (revisions []servingv1.Revision, err error) {
+	serviceName, err := k8s.ToK8sAllowedName(name)
+	if err != nil {
+		return
+	}
+	client, err := d.servingClient()
+	if err != nil {
+		return
+	}
+	return d.revisions(client, serviceName)
+}
+
+// Prune the revisions of the named Function, deleting all but the keep most
+// recent of those not serving, returning the names of those deleted.  As with
+// Knative's own garbage collection, revisions routed by the Service, its latest
+// created and ready revisions, and those annotated to be preserved, are never
+// deleted nor counted against those kept.
+func (d *Deployer) Prune(name string, keep int) (pruned []string, err error) {
+	if keep < 0 {
+		return nil, fmt.Errorf("revisions to keep (%v) must not be negative", keep)
+	}
+	serviceName, err := k8s.ToK8sAllowedName(name)
+	if err != nil {
+		return
+	}
+	client, err := d.servingClient()
+	if err != nil {
+		return
+	}
+
+	service, err := client.GetService(context.Background(), serviceName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, &NotFoundError{Name: name}
+		}
+		return nil, newDeployError("knative deployer failed to get the service", err)
+	}
+	revisions, err := d.revisions(client, serviceName)
+	if err != nil {
+		return
+	}
+
+	active := servingRevisions(service)
+	for _, revision := range revisions {
+		if active[revision.Name] || preserved(revision) {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		// A revision already gone, such as collected by Knative, is pruned.
+		if e := client.DeleteRevision(revision.Name, d.waitTimeout()); e != nil && !apierrors.IsNotFound(e) {
+			return pruned, newDeployError(fmt.Sprintf("knative deployer failed to delete the revision '%v'", revision.Name), e)
+		}
+		pruned = append(pruned, revision.Name)
+	}
+	return
+}
+
+// revisions of the named Service, most recent first.
+func (d *Deployer) revisions(client ServingClient, serviceName string) ([]servingv1.Revision, error) {
+	list, err := client.ListRevisionsOfService(serviceName)
+	if err != nil {
+		return nil, newDeployError("knative deployer failed to list the revisions", err)
+	}
+	revisions := list.Items
+	sort.SliceStable(revisions, func(i, j int) bool {
+		ti, tj := revisions[i].CreationTimestamp, revisions[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return tj.Before(&ti)
+		}
+		return revisions[i].Name > revisions[j].Name
+	})
+	return revisions, nil
+}
+
+// servingRevisions of the Service: those to which its traffic is, or is to
+// be, routed, and its latest created and ready revisions.
+func servingRevisions(service *servingv1.Service) map[string]bool {
+	names := map[string]bool{
+		service.Status.LatestCreatedRevisionName: true,
+		service.Status.LatestReadyRevisionName:   true,
+	}
+	for _, target := range append(service.Spec.Traffic, service.Status.Traffic...) {
+		names[target.RevisionName] = true
+	}
+	delete(names, "")
+	return names
+}
+
+// preserved reports whether the revision is annotated to be exempt from
+// garbage collection.
+func preserved(revision servingv1.Revision) bool {
+	return strings.EqualFold(revision.Annotations[serving.RevisionPreservedAnnotationKey], "true")
+}
@@ -0,0 +1,126 @@
+package knative
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestPrune ensures that all but the most recent of the revisions not serving
+// are deleted, and that those routed, latest, or preserved are never deleted.
+func TestPrune(t *testing.T) {
+	cases := []struct {
+		Keep     int
+		Expected []string
+	}{
+		{0, []string{"f-00005", "f-00004", "f-00001"}},
+		{1, []string{"f-00004", "f-00001"}},
+		{3, nil},
+		{10, nil},
+	}
+
+	for _, c := range cases {
+		client := prunableClient()
+		deployer := &Deployer{client: client}
+
+		pruned, err := deployer.Prune("f", c.Keep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pruned, c.Expected) {
+			t.Fatalf("keeping %v: expected %v pruned, got %v", c.Keep, c.Expected, pruned)
+		}
+		if !reflect.DeepEqual(client.DeletedRevisions, c.Expected) {
+			t.Fatalf("keeping %v: expected %v deleted, got %v", c.Keep, c.Expected, client.DeletedRevisions)
+		}
+		for _, name := range []string{"f-00002", "f-00003", "f-00006", "g-00001"} {
+			if _, ok := client.Revisions[name]; !ok {
+				t.Fatalf("keeping %v: expected revision %v to be preserved", c.Keep, name)
+			}
+		}
+	}
+}
+
+// TestPruneErrors ensures that pruning errors for a negative keep count and
+// for a Function not deployed, and that failures retain their category.
+func TestPruneErrors(t *testing.T) {
+	deployer := &Deployer{client: prunableClient()}
+	if _, err := deployer.Prune("f", -1); err == nil {
+		t.Fatal("expected a negative keep count to error")
+	}
+	if _, err := deployer.Prune("h", 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected pruning a function not deployed to be not found, got '%v'", err)
+	}
+
+	deployer = &Deployer{client: unlistableClient{prunableClient()}}
+	if _, err := deployer.Prune("f", 1); !errors.Is(err, ErrClusterUnreachable) {
+		t.Fatalf("expected a failure to list the revisions to retain its category, got '%v'", err)
+	}
+}
+
+// unlistableClient is a serving client whose revisions can not be listed, the
+// cluster being unavailable.
+type unlistableClient struct {
+	*knativetest.ServingClient
+}
+
+func (unlistableClient) ListRevisionsOfService(string) (*servingv1.RevisionList, error) {
+	return nil, apierrors.NewServiceUnavailable("unavailable")
+}
+
+// TestRevisions ensures that the revisions of a Function are listed most
+// recent first, excluding those of other Functions.
+func TestRevisions(t *testing.T) {
+	deployer := &Deployer{client: prunableClient()}
+	revisions, err := deployer.Revisions("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range revisions {
+		names = append(names, r.Name)
+	}
+	expected := []string{"f-00006", "f-00005", "f-00004", "f-00003", "f-00002", "f-00001"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected revisions %v, got %v", expected, names)
+	}
+}
+
+// prunableClient of a Service f with six revisions, the second of which has
+// been rolled back to, the third of which is preserved, and the sixth of which
+// is the latest.  A revision of another Service g is also present.
+func prunableClient() *knativetest.ServingClient {
+	service := &servingv1.Service{}
+	service.Name = "f"
+	service.Status.LatestCreatedRevisionName = "f-00006"
+	service.Status.LatestReadyRevisionName = "f-00006"
+	percent := int64(100)
+	service.Spec.Traffic = []servingv1.TrafficTarget{{RevisionName: "f-00002", Percent: &percent}}
+	service.Status.Traffic = service.Spec.Traffic
+
+	client := knativetest.NewServingClient(service)
+	created := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 6; i++ {
+		r := &servingv1.Revision{}
+		r.Name = fmt.Sprintf("f-%05d", i)
+		r.Labels = map[string]string{serving.ServiceLabelKey: "f"}
+		r.CreationTimestamp = metav1.NewTime(created.Add(time.Duration(i) * time.Hour))
+		client.Revisions[r.Name] = r
+	}
+	client.Revisions["f-00003"].Annotations = map[string]string{serving.RevisionPreservedAnnotationKey: "true"}
+
+	other := &servingv1.Revision{}
+	other.Name = "g-00001"
+	other.Labels = map[string]string{serving.ServiceLabelKey: "g"}
+	client.Revisions[other.Name] = other
+	return client
+}
@@ -0,0 +1,132 @@
+package knative
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	network "knative.dev/networking/pkg"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+)
+
+// routePathAnnotation of the Service records the path under which the
+// Function is routed on a shared host.  It is honoured by no ingress, the
+// path being routed by the annotation of the Function's ingress class.
+const routePathAnnotation = "boson.dev/route-path"
+
+// routePathRule is the annotation by which an ingress class routes a path,
+// and the format of its value given the path.
+type routePathRule struct {
+	annotation string
+	format     string
+}
+
+// routePathAnnotations are those by which the ingress classes of third party
+// networking layers known to route by path do so, by class.
+var routePathAnnotations = map[string]routePathRule{
+	"skipper": {"zalando.org/skipper-predicate", `PathSubtree("%v")`},
+}
+
+// defaultIngressClass of Knative Serving, where its networking ConfigMap
+// names none.
+const defaultIngressClass = "istio.ingress.networking.knative.dev"
+
+// routePath is an absolute URL path of one or more segments, without a query
+// or fragment.
+var routePath = regexp.MustCompile(`^(/[-a-zA-Z0-9._~!$&'()*+,;=:@%]+)+/?$`)
+
+// updateRoutePath annotates the Service with the path under which it is
+// routed, by the annotation of the given ingress class where it has one,
+// removing those of other classes unless configured as annotations of the
+// Function, and those of the path altogether if there is none.
+func updateRoutePath(service *servingv1.Service, class string, f faas.Function) error {
+	if err := validateRoutePath(f.RoutePath); err != nil {
+		return err
+	}
+	rule, routed := routePathAnnotations[class]
+	configured := scopedMetadata(f.Annotations, f.ServiceAnnotations)
+	for c, r := range routePathAnnotations {
+		if _, ok := configured[r.annotation]; (c != class || f.RoutePath == "") && !ok {
+			delete(service.Annotations, r.annotation)
+		}
+	}
+	if f.RoutePath == "" {
+		delete(service.Annotations, routePathAnnotation)
+		return nil
+	}
+	if service.Annotations == nil {
+		service.Annotations = map[string]string{}
+	}
+	service.Annotations[routePathAnnotation] = f.RoutePath
+	if routed {
+		service.Annotations[rule.annotation] = fmt.Sprintf(rule.format, f.RoutePath)
+	}
+	return nil
+}
+
+// validateRoutePath ensures the path, if any, is absolute and of at least one
+// segment.
+func validateRoutePath(path string) error {
+	if path != "" && !routePath.MatchString(path) {
+		return fmt.Errorf("invalid route path '%v': must be an absolute path, such as /api/greeter, without a query or fragment", path)
+	}
+	return nil
+}
+
+// checkRoutePath ensures the ingress class through which a Function with a
+// route path is exposed is one known to route by path, those of Knative
+// routing by host alone.  An unknown class, being empty, can not be checked,
+// so is passed through with a warning.
+func (d *Deployer) checkRoutePath(serviceName string, f faas.Function, class string) error {
+	if f.RoutePath == "" {
+		return nil
+	}
+	if class == "" {
+		d.warn(serviceName, "the ingress class of the function could not be determined, so its route path '%v' may not be routed", f.RoutePath)
+		return nil
+	}
+	if _, ok := routePathAnnotations[class]; ok {
+		return nil
+	}
+	if knownIngressClasses[class] {
+		return &DeployError{Kind: ErrServiceInvalid, Err: fmt.Errorf("route path '%v' is not supported by the ingress '%v', which routes by host alone; an ingress class which routes by path (%v) is required", f.RoutePath, class, strings.Join(routePathClasses(), ", "))}
+	}
+	return &DeployError{Kind: ErrServiceInvalid, Err: fmt.Errorf("route path '%v' is not supported by the ingress '%v', which is not known to route by path; an ingress class which routes by path (%v) is required", f.RoutePath, class, strings.Join(routePathClasses(), ", "))}
+}
+
+// routePathClasses are the ingress classes known to route by path, sorted.
+func routePathClasses() (classes []string) {
+	for class := range routePathAnnotations {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return
+}
+
+// ingressClass through which the Function is exposed: its own, or otherwise
+// the cluster default.  It is empty if the default can not be determined, such
+// as if the networking ConfigMap can not be read or preflight is skipped.
+func (d *Deployer) ingressClass(f faas.Function) string {
+	if f.IngressClass != "" {
+		return f.IngressClass
+	}
+	if d.SkipPreflight {
+		return ""
+	}
+	client, err := d.kubernetesClient()
+	if err != nil {
+		return ""
+	}
+	config, err := client.CoreV1().ConfigMaps(servingSystemNamespace).Get(network.ConfigName, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	if class := config.Data[network.DefaultIngressClassKey]; class != "" {
+		return class
+	}
+	return defaultIngressClass
+}
@@ -0,0 +1,109 @@
+package knative
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	network "knative.dev/networking/pkg"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestGenerateNewServiceRoutePath ensures that the route path is applied to
+// the Service, removed when no longer configured, and validated.
+func TestGenerateNewServiceRoutePath(t *testing.T) {
+	f := faas.Function{Image: "quay.io/alice/f:latest", RoutePath: "/api/greeter"}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, routePathAnnotation, "/api/greeter")
+	assertAnnotation(t, service.Spec.Template.Annotations, routePathAnnotation, "")
+
+	assertAnnotation(t, service.Annotations, routePathAnnotations["skipper"].annotation, "")
+
+	// The path is routed by the annotation of an ingress class which has one.
+	f.IngressClass = "skipper"
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, routePathAnnotations["skipper"].annotation, `PathSubtree("/api/greeter")`)
+
+	f.RoutePath = ""
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	assertAnnotation(t, service.Annotations, routePathAnnotation, "")
+	assertAnnotation(t, service.Annotations, routePathAnnotations["skipper"].annotation, "")
+
+	for _, path := range []string{"api/greeter", "/", "/api//greeter", "/api?x=1", "/api#top", "/a b"} {
+		f.RoutePath = path
+		if _, err := generateNewService("f", f, nil); err == nil {
+			t.Fatalf("expected route path '%v' to be rejected", path)
+		}
+	}
+}
+
+// TestDeployRoutePathIngress ensures that a route path is rejected prior to
+// deployment where the Function is exposed through an ingress not known to
+// route by path, such as those of Knative, which route by host alone, whether
+// configured or the cluster default, and that one which can not be determined
+// is warned of.
+func TestDeployRoutePathIngress(t *testing.T) {
+	networkConfig := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: servingSystemNamespace, Name: network.ConfigName},
+		Data:       map[string]string{network.DefaultIngressClassKey: "kourier.ingress.networking.knative.dev"},
+	}
+	skipper := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: servingSystemNamespace, Name: network.ConfigName},
+		Data:       map[string]string{network.DefaultIngressClassKey: "skipper"},
+	}
+	cases := []struct {
+		name       string
+		class      string
+		coreClient *fake.Clientset
+		predicate  string // of the valid, if any
+		warning    string // of the valid, if any
+		error      string // of the invalid
+	}{
+		{"cluster default", "", fake.NewSimpleClientset(networkConfig), "", "", "routes by host alone"},
+		{"implicit default", "", fake.NewSimpleClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: servingSystemNamespace, Name: network.ConfigName},
+		}), "", "", "routes by host alone"},
+		{"knative ingress", "contour.ingress.networking.knative.dev", fake.NewSimpleClientset(), "", "", "routes by host alone"},
+		{"unknown ingress", "gateway.example.com", fake.NewSimpleClientset(networkConfig), "", "", "not known to route by path"},
+		{"path ingress", "skipper", fake.NewSimpleClientset(networkConfig), `PathSubtree("/api/greeter")`, "", ""},
+		{"path ingress default", "", fake.NewSimpleClientset(skipper), `PathSubtree("/api/greeter")`, "", ""},
+		{"unreadable default", "", fake.NewSimpleClientset(), "", "could not be determined", ""},
+	}
+	for _, c := range cases {
+		client := knativetest.NewServingClient()
+		var warnings bytes.Buffer
+		deployer := &Deployer{client: client, coreClient: c.coreClient, Warnings: &warnings}
+		_, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", IngressClass: c.class, RoutePath: "/api/greeter"})
+		if c.error == "" {
+			if err != nil {
+				t.Fatalf("%v: %v", c.name, err)
+			}
+			annotations := client.Services["f"].Annotations
+			assertAnnotation(t, annotations, routePathAnnotation, "/api/greeter")
+			assertAnnotation(t, annotations, routePathAnnotations["skipper"].annotation, c.predicate)
+			if !strings.Contains(warnings.String(), c.warning) {
+				t.Fatalf("%v: expected a warning '%v', got %q", c.name, c.warning, warnings.String())
+			}
+			continue
+		}
+		if !errors.Is(err, ErrServiceInvalid) || !strings.Contains(err.Error(), c.error) {
+			t.Fatalf("%v: expected the route path to be unsupported, got '%v'", c.name, err)
+		}
+		if client.Creates != 0 {
+			t.Fatalf("%v: expected no service to be created", c.name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package knative
+
+import (
+	"fmt"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsourcesv1alpha2 "knative.dev/client/pkg/sources/v1alpha2"
+	sourcesv1alpha2 "knative.dev/eventing/pkg/apis/sources/v1alpha2"
+	sourcesclientv1alpha2 "knative.dev/eventing/pkg/client/clientset/versioned/typed/sources/v1alpha2"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+// pingSourceClient is the subset of the Knative PingSource client used to
+// invoke Functions on a schedule.
+type pingSourceClient interface {
+	GetPingSource(name string) (*sourcesv1alpha2.PingSource, error)
+	CreatePingSource(pingSource *sourcesv1alpha2.PingSource) error
+	UpdatePingSource(pingSource *sourcesv1alpha2.PingSource) error
+	DeletePingSource(name string) error
+	ListFunctionPingSources(serviceName string) (*sourcesv1alpha2.PingSourceList, error)
+}
+
+// knPingSourceClient adapts the Knative PingSource client to a
+// pingSourceClient, listing the PingSources of a Function by label, which it
+// does not support, with the typed client.
+type knPingSourceClient struct {
+	clientsourcesv1alpha2.KnPingSourcesClient
+	pingSources sourcesclientv1alpha2.PingSourceInterface
+}
+
+// newPingSourceClient of the namespace.
+func newPingSourceClient(namespace string, options ...ClientOption) (pingSourceClient, error) {
+	restConfig, err := getClientConfig(options...).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new sources client: %v", err)
+	}
+	client, err := sourcesclientv1alpha2.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new sources client: %v", err)
+	}
+	return knPingSourceClient{
+		KnPingSourcesClient: clientsourcesv1alpha2.NewKnSourcesClient(client, namespace).PingSourcesClient(),
+		pingSources:         client.PingSources(namespace),
+	}, nil
+}
+
+// ListFunctionPingSources labeled as belonging to the Function of the given
+// Service name.
+func (c knPingSourceClient) ListFunctionPingSources(serviceName string) (*sourcesv1alpha2.PingSourceList, error) {
+	return c.pingSources.List(metav1.ListOptions{LabelSelector: functionSelector(serviceName)})
+}
+
+// validateSchedule ensures the schedule, if any, is a standard cron
+// expression, as required of a PingSource.
+func validateSchedule(schedule string) error {
+	if schedule == "" {
+		return nil
+	}
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return fmt.Errorf("invalid schedule '%v': %v", schedule, err)
+	}
+	return nil
+}
+
+// pingSourceName of the Function's schedule.
+func pingSourceName(serviceName string) string {
+	return serviceName + "-schedule"
+}
+
+// generatePingSource returns the PingSource invoking the Function's Service on
+// the given schedule, labeled as belonging to the Function.
+func generatePingSource(namespace, serviceName, schedule string) *sourcesv1alpha2.PingSource {
+	return &sourcesv1alpha2.PingSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pingSourceName(serviceName),
+			Namespace: namespace,
+			Labels: map[string]string{
+				labelKey:             labelValue,
+				functionNameLabelKey: serviceName,
+			},
+		},
+		Spec: sourcesv1alpha2.PingSourceSpec{
+			SourceSpec: duckv1.SourceSpec{Sink: serviceDestination(serviceName)},
+			Schedule:   schedule,
+		},
+	}
+}
+
+// reconcileSchedule creates or updates the PingSource of the Function's
+// schedule, or deletes it if there is no longer a schedule.  A PingSource of
+// the same name which does not belong to the Function is not modified.
+// Without a schedule, a PingSource which is unavailable is taken to be none.
+func reconcileSchedule(client pingSourceClient, namespace, serviceName, schedule string) error {
+	name := pingSourceName(serviceName)
+	current, err := client.GetPingSource(name)
+	if err != nil && schedule == "" && unavailable(err) {
+		return nil
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return newDeployError("knative deployer failed to get the ping source", err)
+	}
+	found := err == nil
+	if found && !ownedBy(current.Labels, serviceName) {
+		return &DeployError{Kind: ErrServiceNotOwned, Err: fmt.Errorf("knative deployer failed to reconcile the schedule: ping source '%v' does not belong to the function", name)}
+	}
+
+	switch {
+	case schedule == "" && found:
+		if err = client.DeletePingSource(name); err != nil && !errors.IsNotFound(err) {
+			return newDeployError("knative deployer failed to delete the ping source", err)
+		}
+	case schedule != "" && found:
+		desired := generatePingSource(namespace, serviceName, schedule)
+		current.Labels = desired.Labels
+		current.Spec = desired.Spec
+		if err = client.UpdatePingSource(current); err != nil {
+			return newDeployError("knative deployer failed to update the ping source", err)
+		}
+	case schedule != "":
+		if err = client.CreatePingSource(generatePingSource(namespace, serviceName, schedule)); err != nil {
+			return newDeployError("knative deployer failed to create the ping source", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package knative
+
+import (
+	"errors"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	sourcesv1alpha2 "knative.dev/eventing/pkg/apis/sources/v1alpha2"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDeploySchedule ensures that a PingSource invoking the Function's Service
+// is created for its schedule, updated when the schedule changes, and deleted
+// when the schedule is removed.
+func TestDeploySchedule(t *testing.T) {
+	pingSources := knativetest.NewPingSourceClient()
+	deployer := &Deployer{Namespace: "ns", client: knativetest.NewServingClient(), pingSources: pingSources}
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Schedule: "*/5 * * * *"}
+
+	// Create
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	source, ok := pingSources.PingSources["f-schedule"]
+	if !ok {
+		t.Fatal("expected ping source f-schedule to be created")
+	}
+	if source.Spec.Schedule != "*/5 * * * *" {
+		t.Fatalf("expected schedule '*/5 * * * *', got '%v'", source.Spec.Schedule)
+	}
+	if ref := source.Spec.Sink.Ref; ref == nil || ref.Kind != "Service" || ref.Name != "f" {
+		t.Fatalf("expected the sink to be service f, got %+v", ref)
+	}
+	if source.Namespace != "ns" || !ownedBy(source.Labels, "f") {
+		t.Fatalf("expected the ping source to belong to the function in ns, got %v %v", source.Namespace, source.Labels)
+	}
+
+	// Schedule change
+	f.Schedule = "0 * * * *"
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if s := pingSources.PingSources["f-schedule"].Spec.Schedule; s != "0 * * * *" {
+		t.Fatalf("expected the schedule to be updated to '0 * * * *', got '%v'", s)
+	}
+
+	// Removal
+	f.Schedule = ""
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pingSources.PingSources["f-schedule"]; ok {
+		t.Fatal("expected the ping source to be deleted")
+	}
+}
+
+// TestDeployScheduleInvalid ensures that an invalid cron expression is
+// rejected before anything is deployed.
+func TestDeployScheduleInvalid(t *testing.T) {
+	serving := knativetest.NewServingClient()
+	pingSources := knativetest.NewPingSourceClient()
+	deployer := &Deployer{client: serving, pingSources: pingSources}
+
+	for _, schedule := range []string{"every minute", "* * * *", "61 * * * *"} {
+		_, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Schedule: schedule})
+		if !errors.Is(err, ErrServiceInvalid) {
+			t.Fatalf("expected schedule '%v' to be invalid, got '%v'", schedule, err)
+		}
+	}
+	if len(serving.Services) != 0 || len(pingSources.PingSources) != 0 {
+		t.Fatal("expected nothing to be deployed")
+	}
+}
+
+// TestDeployScheduleConflict ensures that a PingSource of the same name which
+// does not belong to the Function is not modified.
+func TestDeployScheduleConflict(t *testing.T) {
+	other := &sourcesv1alpha2.PingSource{ObjectMeta: metav1.ObjectMeta{Name: "f-schedule"}}
+	pingSources := knativetest.NewPingSourceClient(other)
+	deployer := &Deployer{client: knativetest.NewServingClient(), pingSources: pingSources}
+
+	if _, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Schedule: "* * * * *"}); !errors.Is(err, ErrServiceNotOwned) {
+		t.Fatalf("expected a ping source not belonging to the function to be an error, got '%v'", err)
+	}
+	if pingSources.PingSources["f-schedule"].Spec.Schedule != "" {
+		t.Fatal("expected the unrelated ping source not to be modified")
+	}
+}
+
+// TestUndeploySchedule ensures that the PingSource of the Function is removed
+// on undeploy.
+func TestUndeploySchedule(t *testing.T) {
+	serving := knativetest.NewServingClient()
+	pingSources := knativetest.NewPingSourceClient()
+	deployer := &Deployer{client: serving, pingSources: pingSources}
+
+	if _, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Schedule: "* * * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := deployer.Undeploy("f"); err != nil {
+		t.Fatal(err)
+	}
+	if len(pingSources.Deleted) != 1 || pingSources.Deleted[0] != "f-schedule" {
+		t.Fatalf("expected ping source f-schedule to be deleted, got %v", pingSources.Deleted)
+	}
+}
@@ -0,0 +1,143 @@
+package knative
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+)
+
+// updateScheduling sets the node selector, tolerations and node affinity of
+// the revision template to exactly those of the Function, such that those
+// removed are reconciled.
+func updateScheduling(template *servingv1.RevisionTemplateSpec, f faas.Function) error {
+	if err := validateNodeSelector(f.NodeSelector); err != nil {
+		return err
+	}
+	tolerations, err := tolerations(f.Tolerations)
+	if err != nil {
+		return err
+	}
+	affinity, err := nodeAffinity(f.NodeAffinity)
+	if err != nil {
+		return err
+	}
+	template.Spec.NodeSelector = f.NodeSelector
+	template.Spec.Tolerations = tolerations
+	template.Spec.Affinity = affinity
+	return nil
+}
+
+// validateScheduling ensures the scheduling constraints of the Function are
+// well formed.
+func validateScheduling(f faas.Function) error {
+	if err := validateNodeSelector(f.NodeSelector); err != nil {
+		return err
+	}
+	if _, err := tolerations(f.Tolerations); err != nil {
+		return err
+	}
+	_, err := nodeAffinity(f.NodeAffinity)
+	return err
+}
+
+// validateNodeSelector ensures each entry is a valid label.
+func validateNodeSelector(selector map[string]string) error {
+	for key, value := range selector {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid node selector key '%v': %v", key, strings.Join(errs, ","))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid node selector value '%v': %v", value, strings.Join(errs, ","))
+		}
+	}
+	return nil
+}
+
+// tolerations converts the Function's tolerations to their Kubernetes
+// equivalents, validating each as does Kubernetes.
+func tolerations(tt []faas.Toleration) (tolerations []corev1.Toleration, err error) {
+	for _, t := range tt {
+		if t.Key != "" {
+			if errs := validation.IsQualifiedName(t.Key); len(errs) > 0 {
+				return nil, fmt.Errorf("invalid toleration key '%v': %v", t.Key, strings.Join(errs, ","))
+			}
+		}
+		switch corev1.TolerationOperator(t.Operator) {
+		case "", corev1.TolerationOpEqual:
+			if t.Key == "" {
+				return nil, fmt.Errorf("toleration with operator Equal requires a key")
+			}
+		case corev1.TolerationOpExists:
+			if t.Value != "" {
+				return nil, fmt.Errorf("toleration of '%v' with operator Exists must not have a value", t.Key)
+			}
+		default:
+			return nil, fmt.Errorf("invalid toleration operator '%v': must be Equal or Exists", t.Operator)
+		}
+		switch corev1.TaintEffect(t.Effect) {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, fmt.Errorf("invalid toleration effect '%v': must be NoSchedule, PreferNoSchedule or NoExecute", t.Effect)
+		}
+		if t.Seconds != nil && corev1.TaintEffect(t.Effect) != corev1.TaintEffectNoExecute {
+			return nil, fmt.Errorf("toleration of '%v' may have seconds only with the effect NoExecute", t.Key)
+		}
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:               t.Key,
+			Operator:          corev1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            corev1.TaintEffect(t.Effect),
+			TolerationSeconds: t.Seconds,
+		})
+	}
+	return
+}
+
+// nodeAffinity converts the Function's node requirements to an affinity
+// requiring that the node meet all of them, or nil if there are none.
+func nodeAffinity(requirements []faas.NodeRequirement) (*corev1.Affinity, error) {
+	if len(requirements) == 0 {
+		return nil, nil
+	}
+	term := corev1.NodeSelectorTerm{}
+	for _, r := range requirements {
+		if errs := validation.IsQualifiedName(r.Key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid node affinity key '%v': %v", r.Key, strings.Join(errs, ","))
+		}
+		switch op := corev1.NodeSelectorOperator(r.Operator); op {
+		case corev1.NodeSelectorOpIn, corev1.NodeSelectorOpNotIn:
+			if len(r.Values) == 0 {
+				return nil, fmt.Errorf("node affinity of '%v' with operator %v requires values", r.Key, op)
+			}
+		case corev1.NodeSelectorOpExists, corev1.NodeSelectorOpDoesNotExist:
+			if len(r.Values) > 0 {
+				return nil, fmt.Errorf("node affinity of '%v' with operator %v must not have values", r.Key, op)
+			}
+		case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+			if len(r.Values) != 1 {
+				return nil, fmt.Errorf("node affinity of '%v' with operator %v requires a single value", r.Key, op)
+			}
+			if _, err := strconv.ParseInt(r.Values[0], 10, 64); err != nil {
+				return nil, fmt.Errorf("node affinity of '%v' with operator %v requires an integer value, got '%v'", r.Key, op, r.Values[0])
+			}
+		default:
+			return nil, fmt.Errorf("invalid node affinity operator '%v': must be In, NotIn, Exists, DoesNotExist, Gt or Lt", r.Operator)
+		}
+		term.MatchExpressions = append(term.MatchExpressions, corev1.NodeSelectorRequirement{
+			Key:      r.Key,
+			Operator: corev1.NodeSelectorOperator(r.Operator),
+			Values:   r.Values,
+		})
+	}
+	return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{term},
+		},
+	}}, nil
+}
@@ -0,0 +1,117 @@
+package knative
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestGenerateNewServiceScheduling ensures that the node selector,
+// tolerations and node affinity of the Function reach the PodSpec, and are
+// removed on update.
+func TestGenerateNewServiceScheduling(t *testing.T) {
+	seconds := int64(30)
+	f := faas.Function{
+		Image:        "quay.io/alice/f:latest",
+		NodeSelector: map[string]string{"pool": "gpu"},
+		Tolerations: []faas.Toleration{
+			{Key: "dedicated", Value: "gpu", Effect: "NoSchedule"},
+			{Key: "node.kubernetes.io/unreachable", Operator: "Exists", Effect: "NoExecute", Seconds: &seconds},
+		},
+		NodeAffinity: []faas.NodeRequirement{{Key: "accelerator", Operator: "In", Values: []string{"nvidia-t4", "nvidia-a100"}}},
+	}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := service.Spec.Template.Spec
+	if spec.NodeSelector["pool"] != "gpu" {
+		t.Fatalf("expected node selector pool=gpu, got %v", spec.NodeSelector)
+	}
+	if len(spec.Tolerations) != 2 || spec.Tolerations[0].Effect != corev1.TaintEffectNoSchedule ||
+		spec.Tolerations[1].Operator != corev1.TolerationOpExists || *spec.Tolerations[1].TolerationSeconds != 30 {
+		t.Fatalf("unexpected tolerations %+v", spec.Tolerations)
+	}
+	terms := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchExpressions) != 1 || terms[0].MatchExpressions[0].Operator != corev1.NodeSelectorOpIn ||
+		len(terms[0].MatchExpressions[0].Values) != 2 {
+		t.Fatalf("unexpected node affinity %+v", terms)
+	}
+
+	// Removed on update
+	f.NodeSelector, f.Tolerations, f.NodeAffinity = nil, nil, nil
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	spec = service.Spec.Template.Spec
+	if spec.NodeSelector != nil || spec.Tolerations != nil || spec.Affinity != nil {
+		t.Fatalf("expected scheduling constraints to be removed, got %+v", spec)
+	}
+}
+
+// TestValidateScheduling ensures that malformed scheduling constraints are
+// rejected.
+func TestValidateScheduling(t *testing.T) {
+	seconds := int64(30)
+	cases := []struct {
+		name     string
+		function faas.Function
+	}{
+		{"selector key", faas.Function{NodeSelector: map[string]string{"-pool": "gpu"}}},
+		{"selector value", faas.Function{NodeSelector: map[string]string{"pool": "g p u"}}},
+		{"toleration operator", faas.Function{Tolerations: []faas.Toleration{{Key: "k", Operator: "Matches"}}}},
+		{"toleration without key", faas.Function{Tolerations: []faas.Toleration{{Value: "v"}}}},
+		{"toleration exists value", faas.Function{Tolerations: []faas.Toleration{{Key: "k", Operator: "Exists", Value: "v"}}}},
+		{"toleration effect", faas.Function{Tolerations: []faas.Toleration{{Key: "k", Effect: "NoRun"}}}},
+		{"toleration seconds", faas.Function{Tolerations: []faas.Toleration{{Key: "k", Effect: "NoSchedule", Seconds: &seconds}}}},
+		{"affinity operator", faas.Function{NodeAffinity: []faas.NodeRequirement{{Key: "k", Operator: "Is"}}}},
+		{"affinity values", faas.Function{NodeAffinity: []faas.NodeRequirement{{Key: "k", Operator: "In"}}}},
+		{"affinity integer", faas.Function{NodeAffinity: []faas.NodeRequirement{{Key: "k", Operator: "Gt", Values: []string{"many"}}}}},
+	}
+	for _, c := range cases {
+		if err := validateScheduling(c.function); err == nil {
+			t.Fatalf("%v: expected scheduling constraints to be invalid", c.name)
+		}
+	}
+}
+
+// TestDeploySchedulingFeatures ensures that scheduling constraints whose
+// Knative Serving feature is disabled on the cluster are rejected prior to
+// deployment with an error naming the flag, and are deployed once enabled.
+func TestDeploySchedulingFeatures(t *testing.T) {
+	features := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: servingSystemNamespace, Name: featuresConfigMap},
+		Data:       map[string]string{tolerationsFeature.flag: "Enabled"},
+	}
+	coreClient := fake.NewSimpleClientset(features)
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client, coreClient: coreClient}
+
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", NodeSelector: map[string]string{"pool": "gpu"}}
+	_, err := deployer.Deploy(f)
+	if !errors.Is(err, ErrServiceInvalid) {
+		t.Fatalf("expected the disabled node selector to be invalid, got '%v'", err)
+	}
+	if !strings.Contains(err.Error(), nodeSelectorFeature.flag) {
+		t.Fatalf("expected the error to name the feature '%v', got '%v'", nodeSelectorFeature.flag, err)
+	}
+	if client.Creates != 0 {
+		t.Fatal("expected no service to be created")
+	}
+
+	f.NodeSelector = nil
+	f.Tolerations = []faas.Toleration{{Key: "dedicated", Operator: "Exists"}}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatalf("expected enabled tolerations to deploy, got '%v'", err)
+	}
+	if len(client.Services["f"].Spec.Template.Spec.Tolerations) != 1 {
+		t.Fatal("expected the toleration to be deployed")
+	}
+}
@@ -0,0 +1,55 @@
+package knative
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	servinglib "knative.dev/client/pkg/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+)
+
+// updateSecurityContext sets the security context of the Function's
+// container to exactly that of the Function, such that fields removed are
+// reconciled.
+func updateSecurityContext(template *servingv1.RevisionTemplateSpec, sc *faas.SecurityContext) error {
+	securityContext, err := securityContext(sc)
+	if err != nil {
+		return err
+	}
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return err
+	}
+	container.SecurityContext = securityContext
+	return nil
+}
+
+// securityContext of the container, or nil if the Function has none.
+func securityContext(sc *faas.SecurityContext) (*corev1.SecurityContext, error) {
+	if sc == nil {
+		return nil, nil
+	}
+	if sc.RunAsUser != nil && *sc.RunAsUser < 0 {
+		return nil, fmt.Errorf("invalid security context: runAsUser %v must not be negative", *sc.RunAsUser)
+	}
+	if sc.RunAsNonRoot != nil && *sc.RunAsNonRoot && sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+		return nil, fmt.Errorf("invalid security context: runAsNonRoot requires a runAsUser other than 0 (root)")
+	}
+	securityContext := &corev1.SecurityContext{
+		RunAsNonRoot:           sc.RunAsNonRoot,
+		RunAsUser:              sc.RunAsUser,
+		ReadOnlyRootFilesystem: sc.ReadOnlyRootFilesystem,
+	}
+	if len(sc.DropCapabilities) > 0 {
+		securityContext.Capabilities = &corev1.Capabilities{}
+		for _, c := range sc.DropCapabilities {
+			if c == "" {
+				return nil, fmt.Errorf("invalid security context: dropped capabilities must be named")
+			}
+			securityContext.Capabilities.Drop = append(securityContext.Capabilities.Drop, corev1.Capability(c))
+		}
+	}
+	return securityContext, nil
+}
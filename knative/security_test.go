@@ -0,0 +1,106 @@
+package knative
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestGenerateNewServiceSecurityContext ensures that the Function's security
+// context is set on its container, that its removal is reconciled, and that
+// invalid contexts are rejected.
+func TestGenerateNewServiceSecurityContext(t *testing.T) {
+	nonRoot, readOnly, user, root := true, true, int64(1000), int64(0)
+	f := faas.Function{Image: "quay.io/alice/f:latest", SecurityContext: &faas.SecurityContext{
+		RunAsNonRoot:           &nonRoot,
+		RunAsUser:              &user,
+		ReadOnlyRootFilesystem: &readOnly,
+		DropCapabilities:       []string{"ALL"},
+	}}
+	service, err := generateNewService("f", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := service.Spec.Template.Spec.Containers[0].SecurityContext
+	if sc == nil || !*sc.RunAsNonRoot || *sc.RunAsUser != 1000 || !*sc.ReadOnlyRootFilesystem {
+		t.Fatalf("expected the security context to be set, got %+v", sc)
+	}
+	if sc.Capabilities == nil || len(sc.Capabilities.Drop) != 1 || sc.Capabilities.Drop[0] != corev1.Capability("ALL") {
+		t.Fatalf("expected capabilities to be dropped, got %+v", sc.Capabilities)
+	}
+
+	f.SecurityContext = nil
+	if service, err = updateService(f, nil)(service); err != nil {
+		t.Fatal(err)
+	}
+	if sc := service.Spec.Template.Spec.Containers[0].SecurityContext; sc != nil {
+		t.Fatalf("expected the security context to be removed, got %+v", sc)
+	}
+
+	cases := []*faas.SecurityContext{
+		{RunAsUser: &[]int64{-1}[0]},
+		{RunAsNonRoot: &nonRoot, RunAsUser: &root},
+		{DropCapabilities: []string{""}},
+	}
+	for _, c := range cases {
+		f.SecurityContext = c
+		if _, err := generateNewService("f", f, nil); err == nil {
+			t.Fatalf("expected security context %+v to be rejected", c)
+		}
+	}
+}
+
+// TestDeploySecurityContextFeatures ensures that fields of the security
+// context not permitted by the cluster fail with an error naming that which
+// would permit them.
+func TestDeploySecurityContextFeatures(t *testing.T) {
+	nonRoot, readOnly := true, true
+	features := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: servingSystemNamespace, Name: featuresConfigMap},
+		Data:       map[string]string{},
+	}
+	client := knativetest.NewServingClient()
+	deployer := &Deployer{client: client, coreClient: fake.NewSimpleClientset(features)}
+
+	f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", SecurityContext: &faas.SecurityContext{RunAsNonRoot: &nonRoot}}
+	_, err := deployer.Deploy(f)
+	if !errors.Is(err, ErrServiceInvalid) {
+		t.Fatalf("expected runAsNonRoot to be invalid, got '%v'", err)
+	}
+	if !strings.Contains(err.Error(), runAsNonRootFeature.flag) {
+		t.Fatalf("expected the error to name the feature '%v', got '%v'", runAsNonRootFeature.flag, err)
+	}
+	if client.Creates != 0 {
+		t.Fatal("expected no service to be created")
+	}
+
+	// Fields permitted by no flag are explained upon their rejection.
+	client.CreateErrs = []error{&apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    400,
+		Message: "admission webhook \"validation.webhook.serving.knative.dev\" denied the request: validation failed: must not set the field(s): spec.template.spec.containers[0].securityContext.readOnlyRootFilesystem",
+	}}}
+	f.SecurityContext = &faas.SecurityContext{ReadOnlyRootFilesystem: &readOnly}
+	_, err = deployer.Deploy(f)
+	if !errors.Is(err, ErrServiceInvalid) {
+		t.Fatalf("expected readOnlyRootFilesystem to be invalid, got '%v'", err)
+	}
+	if !strings.Contains(err.Error(), "not permitted by the cluster's version of Knative Serving") {
+		t.Fatalf("expected the rejection to be explained, got '%v'", err)
+	}
+
+	features.Data[runAsNonRootFeature.flag] = "enabled"
+	deployer.coreClient = fake.NewSimpleClientset(features)
+	f.SecurityContext = &faas.SecurityContext{RunAsNonRoot: &nonRoot}
+	if _, err = deployer.Deploy(f); err != nil {
+		t.Fatalf("expected runAsNonRoot to deploy once enabled, got '%v'", err)
+	}
+}
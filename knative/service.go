@@ -0,0 +1,141 @@
+package knative
+
+import (
+	"fmt"
+	"strconv"
+
+	servinglib "knative.dev/client/pkg/serving"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/k8s"
+)
+
+// FunctionFromService reconstructs the Function deployed as the Service, the
+// inverse of its generation, such as for detecting drift of the Service from
+// the Function's configuration.  Recovered are its name and namespace, image,
+// runtime, environment, sink, scaling bounds and durations, limits, container
+// and routing.  Environment variables managed by the deployer, being those of
+// the baseline, the legacy BUILT and K_SINK where set from the Function's sink,
+// are omitted, as are those whose values are
+// neither literal nor of a Secret or ConfigMap.  That which deployment merges
+// with the Service's existing state, such as its labels and annotations, or
+// which is not recorded on the Service, such as the Function's Root, is not
+// recovered.
+func FunctionFromService(service *servingv1.Service) (f faas.Function, err error) {
+	if f.Name, err = k8s.FromK8sAllowedName(service.Name); err != nil {
+		return f, fmt.Errorf("invalid name of service '%v': %v", service.Name, err)
+	}
+	f.Namespace = service.Namespace
+	f.Runtime = service.Labels[runtimeLabelKey]
+	f.ClusterLocal = service.Labels[serving.VisibilityLabelKey] == serving.VisibilityClusterLocal
+	f.IngressClass = service.Annotations[networking.IngressClassAnnotationKey]
+	f.RoutePath = service.Annotations[routePathAnnotation]
+
+	template := &service.Spec.Template
+	container, err := servinglib.ContainerOfRevisionTemplate(template)
+	if err != nil {
+		return
+	}
+	f.Image = container.Image
+	f.Command = container.Command
+	f.Args = container.Args
+	f.WorkingDir = container.WorkingDir
+	f.ImagePullPolicy = string(container.ImagePullPolicy)
+	if len(container.Ports) == 1 {
+		f.Port = container.Ports[0].ContainerPort
+		f.Protocol = container.Ports[0].Name
+	}
+	if f.EnvVars, err = envVarsOf(service); err != nil {
+		return
+	}
+	for _, source := range container.EnvFrom {
+		if source.SecretRef != nil {
+			f.EnvFrom = append(f.EnvFrom, "secret:"+source.SecretRef.Name)
+		} else if source.ConfigMapRef != nil {
+			f.EnvFrom = append(f.EnvFrom, "configMap:"+source.ConfigMapRef.Name)
+		}
+	}
+
+	scaling, err := servinglib.ScalingInfo(&template.ObjectMeta)
+	if err != nil {
+		return f, fmt.Errorf("invalid scale of service '%v': %v", service.Name, err)
+	}
+	if scaling.Min != nil {
+		f.MinScale = *scaling.Min
+	}
+	if scaling.Max != nil {
+		f.MaxScale = *scaling.Max
+	}
+	if value, ok := template.Annotations[autoscaling.InitialScaleAnnotationKey]; ok {
+		scale, err := strconv.Atoi(value)
+		if err != nil {
+			return f, fmt.Errorf("invalid initial scale of service '%v': %v", service.Name, err)
+		}
+		f.InitialScale = &scale
+	}
+	// The default class is applied explicitly, so is that of no class.
+	if class := template.Annotations[autoscaling.ClassAnnotationKey]; class != autoscaling.KPA {
+		f.ScaleClass = class
+	}
+	f.ScaleDownDelay = template.Annotations[scaleDownDelayAnnotationKey]
+	f.ScaleWindow = template.Annotations[autoscaling.WindowAnnotationKey]
+	f.ProgressDeadline = template.Annotations[progressDeadlineAnnotationKey]
+	f.Sink = template.Annotations[sinkAnnotation]
+
+	if template.Spec.ContainerConcurrency != nil {
+		f.Concurrency = *template.Spec.ContainerConcurrency
+	}
+	if template.Spec.TimeoutSeconds != nil {
+		f.Timeout = *template.Spec.TimeoutSeconds
+	}
+	if period := template.Spec.TerminationGracePeriodSeconds; period != nil {
+		seconds := *period
+		f.TerminationGracePeriod = &seconds
+	}
+	f.ServiceAccountName = template.Spec.ServiceAccountName
+	return
+}
+
+// envVarsOf the Service's container, less those managed by the deployer.
+// References to the keys of Secrets and ConfigMaps are of the form with which
+// they are configured, such as {{ secret:name:key }}.
+func envVarsOf(service *servingv1.Service) (map[string]string, error) {
+	container, err := servinglib.ContainerOfRevisionTemplate(&service.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	managed := map[string]bool{legacyBuiltEnvVarName: true}
+	for _, name := range managedKeys(service.Annotations[managedBaselineEnvAnnotation]) {
+		managed[name] = true
+	}
+	if _, ok := service.Spec.Template.Annotations[sinkAnnotation]; ok {
+		managed[sinkEnvVarName] = true
+	}
+
+	var envVars map[string]string
+	for _, e := range container.Env {
+		if managed[e.Name] {
+			continue
+		}
+		value := e.Value
+		if source := e.ValueFrom; source != nil {
+			switch {
+			case source.SecretKeyRef != nil:
+				value = faas.EnvVarReference{Kind: "secret", Name: source.SecretKeyRef.Name, Key: source.SecretKeyRef.Key}.String()
+			case source.ConfigMapKeyRef != nil:
+				value = faas.EnvVarReference{Kind: "configMap", Name: source.ConfigMapKeyRef.Name, Key: source.ConfigMapKeyRef.Key}.String()
+			default:
+				continue
+			}
+		}
+		if envVars == nil {
+			envVars = map[string]string{}
+		}
+		envVars[e.Name] = value
+	}
+	return envVars, nil
+}
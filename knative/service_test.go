@@ -0,0 +1,120 @@
+package knative
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/boson-project/faas"
+)
+
+// TestFunctionFromService ensures that a Function is recovered from the
+// Service generated of it, less the environment variables managed by the
+// deployer.
+func TestFunctionFromService(t *testing.T) {
+	grace := int64(45)
+	initialScale := 3
+	cases := []struct {
+		name string
+		f    faas.Function
+	}{
+		{"minimal", faas.Function{Name: "f", Namespace: "ns", Image: "quay.io/alice/f:latest"}},
+		{"configured", faas.Function{
+			Name:      "www.example.com",
+			Namespace: "ns",
+			Runtime:   "go",
+			Image:     "quay.io/alice/www@sha256:" + testDigest,
+			EnvVars: map[string]string{
+				"A":     "1",
+				"TOKEN": "{{ secret:tokens:api }}",
+				"MODE":  "{{ configMap:settings:mode }}",
+			},
+			EnvFrom:                []string{"secret:credentials", "configMap:defaults"},
+			MinScale:               1,
+			MaxScale:               10,
+			InitialScale:           &initialScale,
+			ScaleDownDelay:         "5m",
+			ScaleWindow:            "2m",
+			ScaleClass:             "hpa.autoscaling.knative.dev",
+			Concurrency:            20,
+			Timeout:                120,
+			ProgressDeadline:       "15m",
+			TerminationGracePeriod: &grace,
+			Port:                   9000,
+			Protocol:               "h2c",
+			Command:                []string{"/bin/www"},
+			Args:                   []string{"--verbose"},
+			WorkingDir:             "/srv",
+			ImagePullPolicy:        "Always",
+			ServiceAccountName:     "www",
+			IngressClass:           "gateway.example.com",
+			RoutePath:              "/api/www",
+			ClusterLocal:           true,
+		}},
+	}
+	for _, c := range cases {
+		deployer := &Deployer{Namespace: "ns", Verbose: true}
+		service, err := deployer.renderService(c.f)
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		// The environment variable replaced by the built annotation may
+		// remain on Services deployed previously.
+		container := &service.Spec.Template.Spec.Containers[0]
+		container.Env = append(container.Env, corev1.EnvVar{Name: legacyBuiltEnvVarName, Value: "20200101T000000"})
+
+		f, err := FunctionFromService(service)
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(f, c.f) {
+			t.Fatalf("%v: expected function\n%+v\ngot\n%+v", c.name, c.f, f)
+		}
+	}
+}
+
+// TestFunctionFromServiceEnvVars ensures that environment variables not of
+// the Function's configuration, other than those managed by the deployer, are
+// recovered, such that drift of the Service from the Function is detected.
+func TestFunctionFromServiceEnvVars(t *testing.T) {
+	service, err := generateNewService("f", faas.Function{Image: "quay.io/alice/f:latest", EnvVars: map[string]string{"A": "1"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := &service.Spec.Template.Spec.Containers[0]
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "INJECTED", Value: "by a webhook"},
+		corev1.EnvVar{Name: "POD", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}})
+
+	f, err := FunctionFromService(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"A": "1", "INJECTED": "by a webhook"}
+	if !reflect.DeepEqual(f.EnvVars, expected) {
+		t.Fatalf("expected env vars %v, got %v", expected, f.EnvVars)
+	}
+
+	// K_SINK set from the Function's sink is recovered as the sink, while
+	// that injected otherwise remains an env var.
+	if f.Sink != "" {
+		t.Fatalf("expected no sink, got '%v'", f.Sink)
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: sinkEnvVarName, Value: "http://broker.example.com"})
+	if f, err = FunctionFromService(service); err != nil {
+		t.Fatal(err)
+	}
+	if f.EnvVars[sinkEnvVarName] != "http://broker.example.com" || f.Sink != "" {
+		t.Fatalf("expected an injected K_SINK to remain an env var, got %v and sink '%v'", f.EnvVars, f.Sink)
+	}
+	if err = updateSink(&service.Spec.Template, "broker:default", "http://broker.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = FunctionFromService(service); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.EnvVars, expected) || f.Sink != "broker:default" {
+		t.Fatalf("expected env vars %v and sink 'broker:default', got %v and sink '%v'", expected, f.EnvVars, f.Sink)
+	}
+}
@@ -0,0 +1,106 @@
+package knative
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+const (
+	// sinkEnvVarName is that from which a Function reads the URL to which to
+	// send the CloudEvents it emits.
+	sinkEnvVarName = "K_SINK"
+
+	// sinkAnnotation records the Sink from which the deployer set K_SINK,
+	// distinguishing it from one injected otherwise, such as by a SinkBinding.
+	sinkAnnotation = "boson.dev/sink"
+)
+
+// parseSink returns the kind and name of a sink of the form 'broker:name' or
+// 'service:name', or a kind of "url" for an absolute URL.
+func parseSink(sink string) (kind, name string, err error) {
+	if u, err := url.Parse(sink); err == nil && u.IsAbs() && u.Host != "" {
+		return "url", sink, nil
+	}
+	tokens := strings.SplitN(sink, ":", 2)
+	if len(tokens) != 2 || tokens[1] == "" || (tokens[0] != "broker" && tokens[0] != "service") {
+		return "", "", fmt.Errorf("invalid sink '%v', expected 'broker:name', 'service:name' or an absolute URL", sink)
+	}
+	return tokens[0], tokens[1], nil
+}
+
+// validateSink ensures the sink, if any, is of a supported form.
+func validateSink(sink string) error {
+	if sink == "" {
+		return nil
+	}
+	_, _, err := parseSink(sink)
+	return err
+}
+
+// resolveSink returns the URL at which the sink receives events, verifying
+// that the Broker or Service to which it refers exists and is addressable.
+func (d *Deployer) resolveSink(ctx context.Context, client ServingClient, sink string) (string, error) {
+	kind, name, err := parseSink(sink)
+	if err != nil {
+		return "", err
+	}
+	switch kind {
+	case "broker":
+		eventing, err := d.eventingClient()
+		if err != nil {
+			return "", err
+		}
+		broker, err := eventing.GetBroker(name)
+		if err != nil {
+			return "", sinkError(kind, name, err)
+		}
+		if broker.Status.Address.URL == nil {
+			return "", fmt.Errorf("knative deployer failed to resolve the sink: broker '%v' is not addressable", name)
+		}
+		return broker.Status.Address.URL.String(), nil
+	case "service":
+		service, err := client.GetService(ctx, name)
+		if err != nil {
+			return "", sinkError(kind, name, err)
+		}
+		return serviceAddress(service, name)
+	}
+	return name, nil
+}
+
+// serviceAddress returns the URL at which the Service is addressable within
+// the cluster.
+func serviceAddress(service *servingv1.Service, name string) (string, error) {
+	if service.Status.Address == nil || service.Status.Address.URL == nil {
+		return "", fmt.Errorf("knative deployer failed to resolve the sink: service '%v' is not addressable", name)
+	}
+	return service.Status.Address.URL.String(), nil
+}
+
+func sinkError(kind, name string, err error) error {
+	if errors.IsNotFound(err) {
+		err = fmt.Errorf("%v '%v' does not exist", kind, name)
+		return &DeployError{Kind: ErrNotFound, Op: "knative deployer failed to resolve the sink", Err: err}
+	}
+	return newDeployError("knative deployer failed to resolve the sink", err)
+}
+
+// updateSink sets K_SINK to the resolved URL of the Function's sink, or
+// removes it if the sink has since been removed.  K_SINK set other than from
+// the Function's sink is left as is.
+func updateSink(template *servingv1.RevisionTemplateSpec, sink, sinkURL string) error {
+	if sink == "" {
+		if _, ok := template.Annotations[sinkAnnotation]; !ok {
+			return nil
+		}
+		delete(template.Annotations, sinkAnnotation)
+		return updateEnv(template, nil, []string{sinkEnvVarName})
+	}
+	setAnnotation(template, sinkAnnotation, sink)
+	return updateEnv(template, map[string]string{sinkEnvVarName: sinkURL}, nil)
+}
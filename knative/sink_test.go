@@ -0,0 +1,83 @@
+package knative
+
+import (
+	"errors"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDeploySink ensures that Service, Broker and URL sinks are resolved to
+// the URL provided to the Function in K_SINK, and that K_SINK is removed along
+// with the sink.
+func TestDeploySink(t *testing.T) {
+	target := &servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "g"}}
+	target.Status.Address = &duckv1.Addressable{URL: &apis.URL{Scheme: "http", Host: "g.ns.svc.cluster.local"}}
+	broker := &v1beta1.Broker{}
+	broker.Status.Address.URL = &apis.URL{Scheme: "http", Host: "broker-ingress.knative-eventing.svc.cluster.local", Path: "/ns/default"}
+
+	cases := []struct {
+		sink     string
+		expected string
+	}{
+		{"service:g", "http://g.ns.svc.cluster.local"},
+		{"broker:default", "http://broker-ingress.knative-eventing.svc.cluster.local/ns/default"},
+		{"https://events.example.com/in", "https://events.example.com/in"},
+	}
+	for _, c := range cases {
+		serving := knativetest.NewServingClient(target.DeepCopy())
+		eventing := knativetest.NewEventingClient()
+		eventing.Brokers["default"] = broker
+		deployer := &Deployer{client: serving, eventing: eventing}
+		f := faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Sink: c.sink}
+
+		for i := 0; i < 2; i++ { // create, then update
+			if _, err := deployer.Deploy(f); err != nil {
+				t.Fatalf("%v: %v", c.sink, err)
+			}
+			assertEnvVar(t, serving.Services["f"].Spec.Template.Spec.Containers[0].Env, sinkEnvVarName, c.expected)
+		}
+
+		f.Sink = ""
+		if _, err := deployer.Deploy(f); err != nil {
+			t.Fatal(err)
+		}
+		assertEnvVar(t, serving.Services["f"].Spec.Template.Spec.Containers[0].Env, sinkEnvVarName, "")
+	}
+}
+
+// TestDeploySinkNotFound ensures that a sink referring to a Broker or Service
+// which does not exist is an error, and that nothing is deployed.
+func TestDeploySinkNotFound(t *testing.T) {
+	for _, sink := range []string{"service:missing", "broker:missing"} {
+		serving := knativetest.NewServingClient()
+		deployer := &Deployer{client: serving, eventing: knativetest.NewEventingClient()}
+
+		_, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Sink: sink})
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%v: expected the sink not to be found, got '%v'", sink, err)
+		}
+		if len(serving.Services) != 0 {
+			t.Fatalf("%v: expected the service not to be created", sink)
+		}
+	}
+}
+
+// TestDeploySinkInvalid ensures that sinks of an unsupported form are
+// rejected.
+func TestDeploySinkInvalid(t *testing.T) {
+	deployer := &Deployer{client: knativetest.NewServingClient()}
+	for _, sink := range []string{"g", "channel:c", "service:", "/relative"} {
+		_, err := deployer.Deploy(faas.Function{Name: "f", Image: "quay.io/alice/f:latest", Sink: sink})
+		if !errors.Is(err, ErrServiceInvalid) {
+			t.Fatalf("expected sink '%v' to be invalid, got '%v'", sink, err)
+		}
+	}
+}
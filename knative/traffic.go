@@ -0,0 +1,135 @@
+package knative
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/k8s"
+)
+
+// Promote the latest revision of the named Function to receive all traffic,
+// completing a gradual rollout.
+func (d *Deployer) Promote(name string) error {
+	return d.PromoteContext(context.Background(), name)
+}
+
+// PromoteContext promotes the latest revision as does Promote, abandoning the
+// update once the context is done.
+func (d *Deployer) PromoteContext(ctx context.Context, name string) (err error) {
+	serviceName, err := k8s.ToK8sAllowedName(name)
+	if err != nil {
+		return
+	}
+
+	client, err := d.servingClient()
+	if err != nil {
+		return
+	}
+
+	err = client.UpdateServiceWithRetry(ctx, serviceName, func(service *servingv1.Service) (*servingv1.Service, error) {
+		service.Spec.Traffic = latestTraffic()
+		return service, nil
+	}, 3)
+	if err != nil {
+		err = newDeployError("knative deployer failed to promote the latest revision", err)
+	}
+	return
+}
+
+// RouteTo routes all traffic of the named Function to the given revision, for
+// example to roll back to a known good revision without rebuilding.  Errors,
+// of Kind ErrNotFound, if the revision does not exist or does not belong to
+// the Function.  The routing lasts only until the Function is next deployed,
+// which rolls forward, routing traffic to the revision it creates as
+// configured by the Function's Traffic.
+func (d *Deployer) RouteTo(name, revisionName string) error {
+	return d.RouteToContext(context.Background(), name, revisionName)
+}
+
+// RouteToContext routes all traffic to the revision as does RouteTo,
+// abandoning the update once the context is done.
+func (d *Deployer) RouteToContext(ctx context.Context, name, revisionName string) (err error) {
+	serviceName, err := k8s.ToK8sAllowedName(name)
+	if err != nil {
+		return
+	}
+
+	client, err := d.servingClient()
+	if err != nil {
+		return
+	}
+
+	revision, err := client.GetRevision(revisionName)
+	if errors.IsNotFound(err) {
+		err = &DeployError{Kind: ErrNotFound, Err: fmt.Errorf("revision '%v' of the function '%v' not found", revisionName, name)}
+		return
+	}
+	if err != nil {
+		err = newDeployError(fmt.Sprintf("knative deployer failed to get the revision '%v'", revisionName), err)
+		return
+	}
+	if revision.Labels[serving.ServiceLabelKey] != serviceName {
+		err = &DeployError{Kind: ErrNotFound, Err: fmt.Errorf("revision '%v' does not belong to the function '%v'", revisionName, name)}
+		return
+	}
+
+	err = client.UpdateServiceWithRetry(ctx, serviceName, func(service *servingv1.Service) (*servingv1.Service, error) {
+		latest := false
+		percent := int64(100)
+		service.Spec.Traffic = []servingv1.TrafficTarget{{RevisionName: revisionName, LatestRevision: &latest, Percent: &percent}}
+		return service, nil
+	}, 3)
+	if err != nil {
+		err = newDeployError(fmt.Sprintf("knative deployer failed to route traffic to revision '%v'", revisionName), err)
+	}
+	return
+}
+
+// updateTraffic splits traffic between the revision about to be created and
+// the latest ready revision of the Service as it stands, or routes all traffic
+// to the latest revision if no split is configured.  Traffic routed to a given
+// revision, as by RouteTo, is thereby replaced: a deployment rolls forward.
+func updateTraffic(service *servingv1.Service, traffic faas.Traffic) error {
+	if err := validateTraffic(traffic); err != nil {
+		return err
+	}
+
+	previous := service.Status.LatestReadyRevisionName
+	if traffic.Previous == 0 || previous == "" {
+		service.Spec.Traffic = latestTraffic()
+		return nil
+	}
+
+	latest := true
+	service.Spec.Traffic = []servingv1.TrafficTarget{
+		{LatestRevision: &latest, Percent: &traffic.Latest},
+		{RevisionName: previous, Percent: &traffic.Previous},
+	}
+	return nil
+}
+
+// validateTraffic ensures a configured traffic split totals 100 percent.
+func validateTraffic(traffic faas.Traffic) error {
+	if traffic == (faas.Traffic{}) {
+		return nil
+	}
+	if traffic.Latest < 0 || traffic.Previous < 0 {
+		return fmt.Errorf("traffic percentages must not be negative (latest %v, previous %v)", traffic.Latest, traffic.Previous)
+	}
+	if traffic.Latest+traffic.Previous != 100 {
+		return fmt.Errorf("traffic percentages must sum to 100 (latest %v, previous %v)", traffic.Latest, traffic.Previous)
+	}
+	return nil
+}
+
+// latestTraffic routes all traffic to the latest revision.
+func latestTraffic() []servingv1.TrafficTarget {
+	latest := true
+	percent := int64(100)
+	return []servingv1.TrafficTarget{{LatestRevision: &latest, Percent: &percent}}
+}
@@ -0,0 +1,146 @@
+package knative
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestUpdateTrafficCanary ensures that a configured split routes the given
+// percentages to the latest revision and to the previously ready revision.
+func TestUpdateTrafficCanary(t *testing.T) {
+	service := &servingv1.Service{}
+	service.Status.LatestReadyRevisionName = "f-00001"
+
+	if err := updateTraffic(service, faas.Traffic{Latest: 10, Previous: 90}); err != nil {
+		t.Fatal(err)
+	}
+	traffic := service.Spec.Traffic
+	if len(traffic) != 2 {
+		t.Fatalf("expected two traffic targets, got %v", traffic)
+	}
+	if traffic[0].LatestRevision == nil || !*traffic[0].LatestRevision || *traffic[0].Percent != 10 {
+		t.Fatalf("expected 10%% to the latest revision, got %v", traffic[0])
+	}
+	if traffic[1].RevisionName != "f-00001" || *traffic[1].Percent != 90 {
+		t.Fatalf("expected 90%% to revision f-00001, got %v", traffic[1])
+	}
+}
+
+// TestUpdateTrafficLatest ensures that with no split configured, or with no
+// previous revision, all traffic is routed to the latest revision.
+func TestUpdateTrafficLatest(t *testing.T) {
+	cases := []struct {
+		Previous string
+		Traffic  faas.Traffic
+	}{
+		{"f-00001", faas.Traffic{}},
+		{"f-00001", faas.Traffic{Latest: 100}},
+		{"", faas.Traffic{Latest: 10, Previous: 90}},
+	}
+	for _, c := range cases {
+		service := &servingv1.Service{}
+		service.Status.LatestReadyRevisionName = c.Previous
+		if err := updateTraffic(service, c.Traffic); err != nil {
+			t.Fatal(err)
+		}
+		assertLatestTraffic(t, service)
+	}
+}
+
+// TestValidateTraffic ensures that splits must total 100 percent.
+func TestValidateTraffic(t *testing.T) {
+	cases := []struct {
+		Traffic faas.Traffic
+		Err     bool
+	}{
+		{faas.Traffic{}, false},
+		{faas.Traffic{Latest: 10, Previous: 90}, false},
+		{faas.Traffic{Latest: 100}, false},
+		{faas.Traffic{Latest: 10, Previous: 80}, true},
+		{faas.Traffic{Latest: -10, Previous: 110}, true},
+	}
+	for _, c := range cases {
+		err := validateTraffic(c.Traffic)
+		if err != nil && !c.Err {
+			t.Fatalf("unexpected error for %v: %v", c.Traffic, err)
+		}
+		if err == nil && c.Err {
+			t.Fatalf("expected error for %v", c.Traffic)
+		}
+	}
+}
+
+// TestPromote ensures that promoting routes all traffic to the latest revision.
+func TestPromote(t *testing.T) {
+	service := &servingv1.Service{}
+	service.Name = "f"
+	service.Status.LatestReadyRevisionName = "f-00001"
+	if err := updateTraffic(service, faas.Traffic{Latest: 10, Previous: 90}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := knativetest.NewServingClient(service)
+	deployer := &Deployer{client: client}
+	if err := deployer.Promote("f"); err != nil {
+		t.Fatal(err)
+	}
+	assertLatestTraffic(t, client.Services["f"])
+
+	if err := deployer.Promote("g"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected promoting a missing function not to find it, got '%v'", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := deployer.PromoteContext(ctx, "f"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled context to abort the promotion, got '%v'", err)
+	}
+}
+
+// TestRouteTo ensures that all traffic is routed to the named revision, and
+// that revisions which do not exist or belong to another service are refused.
+func TestRouteTo(t *testing.T) {
+	service := &servingv1.Service{}
+	service.Name = "f"
+	client := knativetest.NewServingClient(service)
+	client.Revisions["f-00001"] = &servingv1.Revision{}
+	client.Revisions["f-00001"].Labels = map[string]string{serving.ServiceLabelKey: "f"}
+	client.Revisions["g-00001"] = &servingv1.Revision{}
+	client.Revisions["g-00001"].Labels = map[string]string{serving.ServiceLabelKey: "g"}
+
+	deployer := &Deployer{client: client}
+	if err := deployer.RouteTo("f", "f-00001"); err != nil {
+		t.Fatal(err)
+	}
+	traffic := client.Services["f"].Spec.Traffic
+	if len(traffic) != 1 || traffic[0].RevisionName != "f-00001" || *traffic[0].Percent != 100 || *traffic[0].LatestRevision {
+		t.Fatalf("expected 100%% to revision f-00001, got %v", traffic)
+	}
+
+	if err := deployer.RouteTo("f", "g-00001"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected routing to a revision of another service not to find it, got '%v'", err)
+	}
+	if err := deployer.RouteTo("f", "f-00002"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected routing to a nonexistent revision not to find it, got '%v'", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := deployer.RouteToContext(ctx, "f", "f-00001"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled context to abort the routing, got '%v'", err)
+	}
+}
+
+func assertLatestTraffic(t *testing.T, service *servingv1.Service) {
+	t.Helper()
+	traffic := service.Spec.Traffic
+	if len(traffic) != 1 || traffic[0].LatestRevision == nil || !*traffic[0].LatestRevision || *traffic[0].Percent != 100 {
+		t.Fatalf("expected 100%% to the latest revision, got %v", traffic)
+	}
+}
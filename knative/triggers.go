@@ -0,0 +1,198 @@
+package knative
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	clienteventingv1beta1 "knative.dev/client/pkg/eventing/v1beta1"
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+	eventingclientv1beta1 "knative.dev/eventing/pkg/client/clientset/versioned/typed/eventing/v1beta1"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/boson-project/faas"
+)
+
+// defaultBroker receives the events of Subscriptions which name no Broker.
+const defaultBroker = "default"
+
+// attributeName of a CloudEvents context attribute, including extensions,
+// which are of lower-case letters and digits.
+var attributeName = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// validateSubscriptions ensures the Brokers named by the Subscriptions are
+// valid names, and that the attributes filtered upon are valid CloudEvents
+// attribute names other than those of the type and source, which are
+// filtered upon by their own fields.
+func validateSubscriptions(subscriptions []faas.Subscription) error {
+	for _, s := range subscriptions {
+		for name := range s.Attributes {
+			if !attributeName.MatchString(name) {
+				return fmt.Errorf("invalid attribute '%v': CloudEvents attribute names must consist of lower-case letters and digits", name)
+			}
+			if name == "type" || name == "source" {
+				return fmt.Errorf("attribute '%v' of subscriptions is filtered upon by their %v, not their attributes", name, name)
+			}
+		}
+		if s.Broker == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(s.Broker); len(errs) > 0 {
+			return fmt.Errorf("invalid broker '%v': %v", s.Broker, strings.Join(errs, ","))
+		}
+	}
+	return nil
+}
+
+// validateBrokers ensures that the Brokers of the Subscriptions exist.
+func validateBrokers(client eventingClient, subscriptions []faas.Subscription) error {
+	checked := map[string]bool{}
+	for _, s := range subscriptions {
+		broker := brokerOf(s)
+		if checked[broker] {
+			continue
+		}
+		checked[broker] = true
+		if _, err := client.GetBroker(broker); err != nil {
+			if errors.IsNotFound(err) {
+				err = fmt.Errorf("broker '%v' does not exist", broker)
+				return &DeployError{Kind: ErrNotFound, Op: "knative deployer failed to subscribe the function", Err: err}
+			}
+			return newDeployError("knative deployer failed to get the broker", err)
+		}
+	}
+	return nil
+}
+
+func brokerOf(s faas.Subscription) string {
+	if s.Broker == "" {
+		return defaultBroker
+	}
+	return s.Broker
+}
+
+// knEventingClient adapts the Knative eventing client to an eventingClient,
+// listing the Triggers of a Function by label, which it does not support,
+// with the typed client.
+type knEventingClient struct {
+	clienteventingv1beta1.KnEventingClient
+	triggers eventingclientv1beta1.TriggerInterface
+}
+
+// newEventingClient of the namespace.
+func newEventingClient(namespace string, options ...ClientOption) (eventingClient, error) {
+	restConfig, err := getClientConfig(options...).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new eventing client: %v", err)
+	}
+	client, err := eventingclientv1beta1.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new eventing client: %v", err)
+	}
+	return knEventingClient{
+		KnEventingClient: clienteventingv1beta1.NewKnEventingClient(client, namespace),
+		triggers:         client.Triggers(namespace),
+	}, nil
+}
+
+// ListFunctionTriggers labeled as belonging to the Function of the given
+// Service name.
+func (c knEventingClient) ListFunctionTriggers(serviceName string) (*v1beta1.TriggerList, error) {
+	return c.triggers.List(metav1.ListOptions{LabelSelector: functionSelector(serviceName)})
+}
+
+// triggerName of the Function's i'th Subscription.
+func triggerName(serviceName string, i int) string {
+	return fmt.Sprintf("%v-trigger-%v", serviceName, i)
+}
+
+// generateTrigger returns the Trigger of the Function's i'th Subscription,
+// whose subscriber is the Function's Service.  The Trigger is labeled as
+// belonging to the Function such that it is removed along with it.
+func generateTrigger(namespace, serviceName string, i int, s faas.Subscription) *v1beta1.Trigger {
+	attributes := v1beta1.TriggerFilterAttributes{}
+	for name, value := range s.Attributes {
+		attributes[name] = value
+	}
+	if s.Type != "" {
+		attributes["type"] = s.Type
+	}
+	if s.Source != "" {
+		attributes["source"] = s.Source
+	}
+	return &v1beta1.Trigger{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      triggerName(serviceName, i),
+			Namespace: namespace,
+			Labels: map[string]string{
+				labelKey:             labelValue,
+				functionNameLabelKey: serviceName,
+			},
+		},
+		Spec: v1beta1.TriggerSpec{
+			Broker:     brokerOf(s),
+			Filter:     &v1beta1.TriggerFilter{Attributes: attributes},
+			Subscriber: serviceDestination(serviceName),
+		},
+	}
+}
+
+// serviceDestination of events addressed to the Function's Service.
+func serviceDestination(serviceName string) duckv1.Destination {
+	return duckv1.Destination{
+		Ref: &duckv1.KReference{
+			APIVersion: servingv1.SchemeGroupVersion.String(),
+			Kind:       "Service",
+			Name:       serviceName,
+		},
+	}
+}
+
+// reconcileTriggers creates or updates the Triggers of the Function's
+// Subscriptions, and deletes those of Subscriptions since removed.  Without
+// Subscriptions, Triggers which are unavailable are taken to be none.
+func reconcileTriggers(client eventingClient, namespace, serviceName string, subscriptions []faas.Subscription) error {
+	existing := map[string]*v1beta1.Trigger{}
+	triggers, err := client.ListFunctionTriggers(serviceName)
+	if err != nil && len(subscriptions) == 0 && unavailable(err) {
+		return nil
+	}
+	// IsNotFound -- Eventing is probably not installed on the cluster
+	if err != nil && !errors.IsNotFound(err) {
+		return newDeployError("knative deployer failed to list the triggers", err)
+	}
+	if err == nil {
+		for i := range triggers.Items {
+			if ownedBy(triggers.Items[i].Labels, serviceName) {
+				existing[triggers.Items[i].Name] = &triggers.Items[i]
+			}
+		}
+	}
+
+	for i, s := range subscriptions {
+		trigger := generateTrigger(namespace, serviceName, i, s)
+		if current, ok := existing[trigger.Name]; ok {
+			delete(existing, trigger.Name)
+			current.Labels = trigger.Labels
+			current.Spec = trigger.Spec
+			if err := client.UpdateTrigger(current); err != nil {
+				return newDeployError("knative deployer failed to update the trigger", err)
+			}
+			continue
+		}
+		if err := client.CreateTrigger(trigger); err != nil {
+			return newDeployError("knative deployer failed to create the trigger", err)
+		}
+	}
+
+	for name := range existing {
+		if err := client.DeleteTrigger(name); err != nil && !errors.IsNotFound(err) {
+			return newDeployError("knative deployer failed to delete the trigger", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package knative
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"knative.dev/eventing/pkg/apis/eventing/v1beta1"
+
+	"github.com/boson-project/faas"
+	"github.com/boson-project/faas/knative/knativetest"
+)
+
+// TestDeploySubscriptions ensures that a Trigger is created for each of the
+// Function's Subscriptions, subscribing the Function's Service, and that those
+// of removed Subscriptions are deleted on update.
+func TestDeploySubscriptions(t *testing.T) {
+	serving := knativetest.NewServingClient()
+	eventing := knativetest.NewEventingClient()
+	eventing.Brokers["default"] = &v1beta1.Broker{}
+	eventing.Brokers["orders"] = &v1beta1.Broker{}
+	deployer := &Deployer{Namespace: "ns", client: serving, eventing: eventing}
+
+	f := faas.Function{
+		Name:  "f.example.com",
+		Image: "quay.io/alice/f:latest",
+		Subscriptions: []faas.Subscription{
+			{Type: "com.example.order.created", Source: "/orders"},
+			{Broker: "orders", Type: "com.example.order.shipped"},
+		},
+	}
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(eventing.Triggers) != 2 {
+		t.Fatalf("expected 2 triggers, got %v", len(eventing.Triggers))
+	}
+
+	created := eventing.Triggers[0]
+	if created.Name != "f-example-com-trigger-0" || created.Namespace != "ns" {
+		t.Fatalf("unexpected trigger %v/%v", created.Namespace, created.Name)
+	}
+	if !ownedBy(created.Labels, "f-example-com") {
+		t.Fatalf("expected the trigger to be labeled as belonging to the function, got %v", created.Labels)
+	}
+	ref := created.Spec.Subscriber.Ref
+	if ref == nil || ref.Kind != "Service" || ref.APIVersion != "serving.knative.dev/v1" || ref.Name != "f-example-com" {
+		t.Fatalf("expected the subscriber to be service f-example-com, got %+v", ref)
+	}
+	if created.Spec.Broker != "default" {
+		t.Fatalf("expected the default broker, got '%v'", created.Spec.Broker)
+	}
+	attributes := created.Spec.Filter.Attributes
+	if attributes["type"] != "com.example.order.created" || attributes["source"] != "/orders" {
+		t.Fatalf("unexpected filter attributes %v", attributes)
+	}
+	if _, ok := eventing.Triggers[1].Spec.Filter.Attributes["source"]; ok {
+		t.Fatal("expected an empty source not to be filtered upon")
+	}
+
+	// Update, removing the first Subscription.
+	f.Subscriptions = f.Subscriptions[1:]
+	if _, err := deployer.Deploy(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(eventing.Triggers) != 1 || eventing.Triggers[0].Spec.Broker != "orders" {
+		t.Fatalf("expected only the trigger of broker orders to remain, got %+v", eventing.Triggers)
+	}
+	if len(eventing.Deleted) != 1 || eventing.Deleted[0] != "f-example-com-trigger-1" {
+		t.Fatalf("expected the stale trigger to be deleted, got %v", eventing.Deleted)
+	}
+}
+
+// TestDeploySubscriptionBrokerNotFound ensures that a Function subscribing to
+// a Broker which does not exist is not deployed.
+func TestDeploySubscriptionBrokerNotFound(t *testing.T) {
+	serving := knativetest.NewServingClient()
+	deployer := &Deployer{client: serving, eventing: knativetest.NewEventingClient()}
+
+	_, err := deployer.Deploy(faas.Function{
+		Name:          "f",
+		Image:         "quay.io/alice/f:latest",
+		Subscriptions: []faas.Subscription{{Broker: "missing"}},
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the broker not to be found, got '%v'", err)
+	}
+	if len(serving.Services) != 0 {
+		t.Fatal("expected the service not to be created")
+	}
+}
+
+// TestGenerateTriggerAttributes ensures that the attributes of a Subscription
+// are filtered upon by its Trigger alongside its type and source, and that
+// attributes which are not valid CloudEvents attribute names, or which are
+// filtered upon by their own fields, are rejected.
+func TestGenerateTriggerAttributes(t *testing.T) {
+	s := faas.Subscription{
+		Type:       "com.example.order.created",
+		Source:     "/orders",
+		Attributes: map[string]string{"region": "eu-west", "tier": "gold", "datacontenttype": "application/json"},
+	}
+	if err := validateSubscriptions([]faas.Subscription{s}); err != nil {
+		t.Fatal(err)
+	}
+	attributes := generateTrigger("ns", "f", 0, s).Spec.Filter.Attributes
+	expected := v1beta1.TriggerFilterAttributes{
+		"type":            "com.example.order.created",
+		"source":          "/orders",
+		"region":          "eu-west",
+		"tier":            "gold",
+		"datacontenttype": "application/json",
+	}
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Fatalf("expected filter attributes %v, got %v", expected, attributes)
+	}
+
+	for _, name := range []string{"Region", "data-region", "region_name", "", "type", "source"} {
+		s := faas.Subscription{Attributes: map[string]string{name: "x"}}
+		if err := validateSubscriptions([]faas.Subscription{s}); err == nil {
+			t.Fatalf("expected attribute '%v' to be invalid", name)
+		}
+	}
+}
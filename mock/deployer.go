@@ -4,16 +4,16 @@ import "github.com/boson-project/faas"
 
 type Deployer struct {
 	DeployInvoked bool
-	DeployFn      func(faas.Function) error
+	DeployFn      func(faas.Function) (faas.DeploymentResult, error)
 }
 
 func NewDeployer() *Deployer {
 	return &Deployer{
-		DeployFn: func(faas.Function) error { return nil },
+		DeployFn: func(faas.Function) (faas.DeploymentResult, error) { return faas.DeploymentResult{}, nil },
 	}
 }
 
-func (i *Deployer) Deploy(f faas.Function) error {
+func (i *Deployer) Deploy(f faas.Function) (faas.DeploymentResult, error) {
 	i.DeployInvoked = true
 	return i.DeployFn(f)
 }
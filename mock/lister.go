@@ -1,17 +1,19 @@
 package mock
 
+import "github.com/boson-project/faas"
+
 type Lister struct {
 	ListInvoked bool
-	ListFn      func() ([]string, error)
+	ListFn      func() ([]faas.ListItem, error)
 }
 
 func NewLister() *Lister {
 	return &Lister{
-		ListFn: func() ([]string, error) { return []string{}, nil },
+		ListFn: func() ([]faas.ListItem, error) { return []faas.ListItem{}, nil },
 	}
 }
 
-func (l *Lister) List() ([]string, error) {
+func (l *Lister) List() ([]faas.ListItem, error) {
 	l.ListInvoked = true
 	return l.ListFn()
 }
@@ -0,0 +1,169 @@
+package faas
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boson-project/faas/k8s"
+)
+
+// ValidationError lists every problem found with a Function by Validate.
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid function: %v", strings.Join(msgs, "; "))
+}
+
+var (
+	// envVarReferencePrefix identifies values which are intended to be
+	// references to the key of a Secret or ConfigMap.
+	envVarReferencePrefix = regexp.MustCompile(`^{{\s*(secret|configMap)\s*:`)
+
+	// envVarReference is a well-formed reference, ex:
+	// {{ secret:my-secret:my-key }}
+	envVarReference = regexp.MustCompile(`^{{\s*(secret|configMap)\s*:\s*([-._a-zA-Z0-9]+)\s*:\s*([-._a-zA-Z0-9]+)\s*}}$`)
+)
+
+// EnvVarReference is the value of an environment variable which refers to the
+// key of a Secret or ConfigMap, of the form {{ secret:name:key }} or
+// {{ configMap:name:key }}.
+type EnvVarReference struct {
+	// Kind of the resource referred to, "secret" or "configMap".
+	Kind string
+	// Name of the Secret or ConfigMap.
+	Name string
+	// Key of the value within the Secret or ConfigMap.
+	Key string
+}
+
+// String is the reference in the form in which it is configured.
+func (r EnvVarReference) String() string {
+	return fmt.Sprintf("{{ %v:%v:%v }}", r.Kind, r.Name, r.Key)
+}
+
+// ParseEnvVarReference parses the value of an environment variable, returning
+// the reference to the key of a Secret or ConfigMap of which it is of the
+// form, or nil for a literal value.  A value which begins as such a reference
+// but is malformed is an error.
+func ParseEnvVarReference(value string) (*EnvVarReference, error) {
+	if !envVarReferencePrefix.MatchString(value) {
+		return nil, nil
+	}
+	m := envVarReference.FindStringSubmatch(value)
+	if m == nil {
+		return nil, fmt.Errorf("'%v' is not of the form {{ secret:name:key }} or {{ configMap:name:key }}", value)
+	}
+	return &EnvVarReference{Kind: m[1], Name: m[2], Key: m[3]}, nil
+}
+
+// Validate the Function, independent of the platform to which it is
+// deployed: that it has a valid name and an image, that its scaling is
+// consistent, and that its durations, limits and env var references are
+// well-formed.  Each problem found is reported, as a ValidationError, rather
+// than only the first.  Deployers validate further that which is specific to
+// their platform.
+func (f Function) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, err := k8s.ToK8sAllowedName(f.Name); err != nil {
+		errs = append(errs, err)
+	}
+	if f.Image == "" {
+		errs = append(errs, errors.New("function has no image. Has it been built?"))
+	}
+
+	if f.MinScale < 0 {
+		fail("minScale (%v) must not be negative", f.MinScale)
+	}
+	if f.MaxScale < 0 {
+		fail("maxScale (%v) must not be negative", f.MaxScale)
+	}
+	if f.MaxScale > 0 && f.MinScale > f.MaxScale {
+		fail("minScale (%v) must not be greater than maxScale (%v)", f.MinScale, f.MaxScale)
+	}
+	if f.InitialScale != nil && *f.InitialScale < 0 {
+		fail("initial scale (%v) must not be negative", *f.InitialScale)
+	}
+	if f.ScaleTarget < 0 {
+		fail("scale target (%v) must be positive", f.ScaleTarget)
+	}
+	if f.TargetRPS < 0 {
+		fail("target rps (%v) must be greater than zero", f.TargetRPS)
+	}
+	if f.TargetRPS != 0 && ((f.ScaleMetric != "" && f.ScaleMetric != "rps") || f.ScaleTarget != 0) {
+		fail("target rps can not be combined with a scale metric or target")
+	}
+	if f.TargetUtilization < 0 || f.TargetUtilization > 100 {
+		fail("target utilization (%v) must be between 1 and 100 percent", f.TargetUtilization)
+	}
+	if f.Concurrency < 0 {
+		fail("concurrency (%v) must not be negative", f.Concurrency)
+	}
+	if f.Timeout < 0 {
+		fail("timeout (%v) must not be negative", f.Timeout)
+	}
+	if f.TerminationGracePeriod != nil && *f.TerminationGracePeriod < 0 {
+		fail("termination grace period (%v) must not be negative", *f.TerminationGracePeriod)
+	}
+
+	for _, d := range []struct {
+		name     string
+		value    string
+		positive bool
+	}{
+		{"scale down delay", f.ScaleDownDelay, false},
+		{"scale window", f.ScaleWindow, false},
+		{"progress deadline", f.ProgressDeadline, true},
+	} {
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil {
+			fail("invalid %v '%v': %v", d.name, d.value, err)
+		} else if duration < 0 || (d.positive && duration == 0) {
+			fail("%v '%v' must be positive", d.name, d.value)
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		port int32
+	}{
+		{"port", f.Port},
+		{"metrics port", f.MetricsPort},
+	} {
+		if p.port < 0 || p.port > 65535 {
+			fail("%v (%v) must be between 1 and 65535", p.name, p.port)
+		}
+	}
+
+	names := make([]string, 0, len(f.EnvVars))
+	for name := range f.EnvVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := ParseEnvVarReference(f.EnvVars[name]); err != nil {
+			fail("invalid value for env var '%v': %v", name, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errs: errs}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package faas
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestValidate ensures that a valid Function passes validation, and that each
+// of several simultaneous problems with an invalid Function is reported.
+func TestValidate(t *testing.T) {
+	valid := Function{
+		Name:     "www.example.com",
+		Image:    "quay.io/alice/www:latest",
+		MinScale: 1,
+		MaxScale: 3,
+		EnvVars:  map[string]string{"TOKEN": "{{ secret:tokens:api }}"},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid function, got %v", err)
+	}
+
+	negative := int64(-1)
+	initialScale := -2
+	invalid := Function{
+		Name:                   "WWW",
+		MinScale:               5,
+		MaxScale:               2,
+		InitialScale:           &initialScale,
+		ScaleWindow:            "a while",
+		ProgressDeadline:       "0s",
+		TerminationGracePeriod: &negative,
+		EnvVars: map[string]string{
+			"B": "{{ configMap:config }}",
+			"A": "{{ secret:tokens }}",
+		},
+	}
+	err := invalid.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expected := []string{
+		"invalid character 'W'",
+		"function has no image",
+		"minScale (5) must not be greater than maxScale (2)",
+		"initial scale (-2) must not be negative",
+		"termination grace period (-1) must not be negative",
+		"invalid scale window 'a while'",
+		"progress deadline '0s' must be positive",
+		"invalid value for env var 'A'",
+		"invalid value for env var 'B'",
+	}
+	if len(validationErr.Errs) != len(expected) {
+		t.Fatalf("expected %v errors, got %v: %v", len(expected), len(validationErr.Errs), err)
+	}
+	for i, e := range expected {
+		if !strings.Contains(validationErr.Errs[i].Error(), e) {
+			t.Fatalf("expected error %v to contain '%v', got '%v'", i, e, validationErr.Errs[i])
+		}
+		if !strings.Contains(err.Error(), e) {
+			t.Fatalf("expected '%v' in the error reported, got '%v'", e, err)
+		}
+	}
+}
+
+// TestParseEnvVarReference ensures that references to the keys of Secrets
+// and ConfigMaps are parsed and rendered, that literal values are not
+// references, and that malformed references error.
+func TestParseEnvVarReference(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected *EnvVarReference
+		err      bool
+	}{
+		{"plain", nil, false},
+		{"{{ not a reference }}", nil, false},
+		{"{{ secret:db:password }}", &EnvVarReference{Kind: "secret", Name: "db", Key: "password"}, false},
+		{"{{configMap:app-config:log.level}}", &EnvVarReference{Kind: "configMap", Name: "app-config", Key: "log.level"}, false},
+		{"{{ secret:db }}", nil, true},
+		{"{{ configMap::key }}", nil, true},
+	}
+	for _, c := range cases {
+		ref, err := ParseEnvVarReference(c.value)
+		if c.err {
+			if err == nil {
+				t.Fatalf("expected '%v' to be malformed", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error parsing '%v': %v", c.value, err)
+		}
+		if !reflect.DeepEqual(ref, c.expected) {
+			t.Fatalf("expected '%v' to parse as %+v, got %+v", c.value, c.expected, ref)
+		}
+		if ref == nil {
+			continue
+		}
+		if rendered, _ := ParseEnvVarReference(ref.String()); !reflect.DeepEqual(rendered, ref) {
+			t.Fatalf("expected reference '%v' to round-trip, got '%v'", c.value, ref)
+		}
+	}
+}